@@ -5,15 +5,19 @@ import (
 	"git.ianfross.com/ifross/expensetracker/env"
 	"git.ianfross.com/ifross/expensetracker/handlers"
 	"git.ianfross.com/ifross/expensetracker/models"
+	"git.ianfross.com/ifross/expensetracker/models/memstore"
 	"git.ianfross.com/ifross/expensetracker/models/postgrestore"
+	"git.ianfross.com/ifross/expensetracker/models/sqlitestore"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/julienschmidt/httprouter"
 	"github.com/namsral/flag"
 
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -43,6 +47,18 @@ var (
 	dbPw   = flag.String("db_pw", "", "user's database password")
 	dbHost = flag.String("db_host", "localhost", "host the database is running on")
 	dbPort = flag.Int("db_port", 5432, "port the database is listening on")
+	dbFile = flag.String("db_file", "expensetracker.db", "SQLite database file, used with the sqlite store")
+
+	storeType = flag.String("store", "postgres", "storage backend to use. Available: [postgres, sqlite, memory]. Nothing is saved with memory")
+
+	ratesFile = flag.String("rates_file", "", "CSV or JSON file of exchange rates used to convert foreign currency expenses")
+
+	receiptsDir = flag.String("receipts_dir", "receipts", "directory to keep the receipts attached to expenses in. Receipts cannot be attached if empty")
+
+	smtpAddr = flag.String("smtp_addr", "", "host:port of the SMTP server to send budget alerts through. Alerts are not sent if empty")
+	smtpUser = flag.String("smtp_user", "", "user to authenticate with the SMTP server as, if any")
+	smtpPw   = flag.String("smtp_pw", "", "password of the SMTP user")
+	mailFrom = flag.String("mail_from", "expensetracker@localhost", "address emails are sent from")
 
 	port   = flag.Int("port", 8181, "HTTP port to listen on")
 	action = flag.String("action", "start", "action to perform. Available: "+actions.available())
@@ -50,6 +66,16 @@ var (
 	adminName  = flag.String("admin_name", "", "Name of admin to add")
 	adminEmail = flag.String("admin_email", "", "Email of admin to add")
 	adminPw    = flag.String("admin_pw", "", "Password of admin to add")
+
+	groupID = flag.Int64("group_id", 0, "ID of the group to export with export_ledger, or to import into")
+	outFile = flag.String("out", "", "file to write the export to. Defaults to stdout")
+
+	importFile   = flag.String("import_file", "", "bank statement to import with the import action")
+	importFormat = flag.String("import_format", "", "format of the statement to import. Available: [csv, ofx, qif]. Defaults to the file's extension")
+	csvMapping   = flag.String("csv_mapping", `{"date": 0, "description": 1, "amount": 2}`, "JSON mapping of the columns of a CSV statement. Its dateFormat is also used for QIF statements")
+	payerID      = flag.Int64("payer_id", 0, "ID of the member who paid the imported expenses")
+	categoryID   = flag.Int64("category_id", 0, "ID of the category of the imported expenses")
+	dryRun       = flag.Bool("dry_run", false, "only preview the import, without saving anything")
 )
 
 func DBConn() (*sqlx.DB, error) {
@@ -58,25 +84,104 @@ func DBConn() (*sqlx.DB, error) {
 			*dbUser, *dbName, *dbPw, *dbHost, *dbPort))
 }
 
+// storer is the storage needed by the models and users.
+type storer interface {
+	models.Storer
+	auth.Storer
+}
+
+// openStore connects to the storage backend chosen with the store flag.
+func openStore() (storer, error) {
+	switch *storeType {
+	case "memory":
+		return memstore.New(), nil
+	case "postgres":
+		db, err := DBConn()
+		if err != nil {
+			return nil, err
+		}
+
+		store := postgrestore.MustCreate(db)
+		store.MustPrepareStmts()
+		return store, nil
+	case "sqlite":
+		db, err := sqlitestore.Open(*dbFile)
+		if err != nil {
+			return nil, err
+		}
+
+		store := sqlitestore.MustCreate(db)
+		store.MustPrepareStmts()
+		return store, nil
+	}
+
+	return nil, fmt.Errorf("unknown store %q", *storeType)
+}
+
+// loadRates loads the exchange rates from the rates file, if one is given.
+func loadRates() (models.RateStore, error) {
+	if *ratesFile == "" {
+		return nil, nil
+	}
+
+	return models.LoadRatesFile(*ratesFile)
+}
+
+// openReceipts opens the directory given by the receipts_dir flag, if one is
+// given.
+func openReceipts() (models.BlobStore, error) {
+	if *receiptsDir == "" {
+		return nil, nil
+	}
+
+	return models.NewDirBlobStore(*receiptsDir)
+}
+
+// newNotifier creates a notifier that emails budget alerts through the
+// server given by the smtp_addr flag, if one is given.
+func newNotifier() models.Notifier {
+	if *smtpAddr == "" {
+		return nil
+	}
+
+	return models.NewMailNotifier(models.NewSMTPMailer(*smtpAddr, *mailFrom, *smtpUser, *smtpPw))
+}
+
 func start() error {
-	db, err := DBConn()
+	store, err := openStore()
 	if err != nil {
 		return err
 	}
 
-	store := postgrestore.MustCreate(db)
-	store.MustPrepareStmts()
 	sessionStore := auth.NewCookieSessionStore(
 		[]byte("newauthenticatio"),
 		[]byte("newencryptionkey"))
 
 	um := auth.NewUserManager(nil, store, nil, sessionStore)
-	m := models.NewManager(store)
+	rates, err := loadRates()
+	if err != nil {
+		return err
+	}
+
+	receipts, err := openReceipts()
+	if err != nil {
+		return err
+	}
+
+	m := models.NewManager(store, rates, newNotifier(), receipts)
+
+	// Without a database there is no other way to create the first user.
+	if *storeType == "memory" && *adminEmail != "" {
+		err = insertAdmin(um)
+		if err != nil {
+			return err
+		}
+	}
 
 	e := &env.Env{
-		m,
-		um,
-		env.Config{
+		Manager:     m,
+		UserManager: um,
+		Conf: env.Config{
 			Port: *port,
 		},
 	}
@@ -94,54 +199,229 @@ func start() error {
 	router.GET("/admin/users", CreateHandlerWithEnv(e, handlers.CreateAdminUsersGETHandler))
 	router.POST("/admin/user", CreateHandlerWithEnv(e, handlers.CreateAdminUsersPOSTHandler))
 	router.DELETE("/admin/user/:user_id", CreateHandlerWithEnv(e, handlers.CreateAdminUserDELETEHandler))
+	router.GET("/admin/groups", CreateHandlerWithEnv(e, handlers.CreateAdminGroupsGETHandler))
+	router.POST("/admin/group", CreateHandlerWithEnv(e, handlers.CreateAdminGroupPOSTHandler))
+	router.DELETE("/admin/group", CreateHandlerWithEnv(e, handlers.CreateAdminGroupDELETEHandler))
 
 	router.POST("/auth/login", CreateHandlerWithEnv(e, handlers.CreateLoginHandler))
 	router.GET("/auth/logout", CreateHandlerWithEnv(e, handlers.CreateLogoutHandler))
 	router.POST("/auth/change_password", CreateHandlerWithEnv(e, handlers.CreateChangePasswordHandler))
 
+	// Group routes
+	router.GET("/groups", CreateHandlerWithEnv(e, handlers.CreateUserGroupsGETHandler))
+	router.GET("/groups/:group_id", CreateHandlerWithEnv(e, handlers.CreateGroupGETHandler))
+	router.POST("/groups/:group_id/leave", CreateHandlerWithEnv(e, handlers.CreateGroupLeavePOSTHandler))
+	router.GET("/groups/:group_id/settle_up", CreateHandlerWithEnv(e, handlers.CreateSettleUpGETHandler))
+	router.POST("/groups/:group_id/settle_up", CreateHandlerWithEnv(e, handlers.CreateSettleUpPOSTHandler))
+
+	// Expense routes
+	router.GET("/groups/:group_id/expenses", CreateHandlerWithEnv(e, handlers.CreateExpensesGETHandler))
+	router.POST("/groups/:group_id/expenses", CreateHandlerWithEnv(e, handlers.CreateExpensePOSTHandler))
+	router.GET("/groups/:group_id/expenses/:expense_id", CreateHandlerWithEnv(e, handlers.CreateExpenseGETHandler))
+	router.PUT("/groups/:group_id/expenses/:expense_id", CreateHandlerWithEnv(e, handlers.CreateExpensePUTHandler))
+	router.DELETE("/groups/:group_id/expenses/:expense_id", CreateHandlerWithEnv(e, handlers.CreateExpenseDELETEHandler))
+	router.GET("/groups/:group_id/search", CreateHandlerWithEnv(e, handlers.CreateSearchGETHandler))
+	router.GET("/groups/:group_id/expenses/:expense_id/attachments", CreateHandlerWithEnv(e, handlers.CreateAttachmentsGETHandler))
+	router.POST("/groups/:group_id/expenses/:expense_id/attachments", CreateHandlerWithEnv(e, handlers.CreateAttachmentPOSTHandler))
+	router.GET("/groups/:group_id/expenses/:expense_id/attachments/:attachment_id", CreateHandlerWithEnv(e, handlers.CreateAttachmentGETHandler))
+	router.DELETE("/groups/:group_id/expenses/:expense_id/attachments/:attachment_id", CreateHandlerWithEnv(e, handlers.CreateAttachmentDELETEHandler))
+
+	// Category routes
+	router.GET("/groups/:group_id/categories", CreateHandlerWithEnv(e, handlers.CreateCategoriesGETHandler))
+	router.POST("/groups/:group_id/categories", CreateHandlerWithEnv(e, handlers.CreateCategoryPOSTHandler))
+	router.PUT("/groups/:group_id/categories/:category_id", CreateHandlerWithEnv(e, handlers.CreateCategoryPUTHandler))
+	router.DELETE("/groups/:group_id/categories/:category_id", CreateHandlerWithEnv(e, handlers.CreateCategoryDELETEHandler))
+	router.GET("/groups/:group_id/budgets", CreateHandlerWithEnv(e, handlers.CreateBudgetsGETHandler))
+	router.GET("/groups/:group_id/reports", CreateHandlerWithEnv(e, handlers.CreateReportGETHandler))
+	router.GET("/groups/:group_id/ledger.csv", CreateHandlerWithEnv(e, handlers.CreateLedgerGETHandler))
+	router.POST("/groups/:group_id/imports/:format/preview", CreateHandlerWithEnv(e, handlers.CreateStatementPreviewPOSTHandler))
+	router.POST("/groups/:group_id/imports/:format", CreateHandlerWithEnv(e, handlers.CreateStatementImportPOSTHandler))
+	router.POST("/groups/:group_id/splitwise", CreateHandlerWithEnv(e, handlers.CreateSplitwiseImportPOSTHandler))
+
+	// Payment routes
+	router.GET("/groups/:group_id/payments", CreateHandlerWithEnv(e, handlers.CreatePaymentsGETHandler))
+	router.POST("/groups/:group_id/payments", CreateHandlerWithEnv(e, handlers.CreatePaymentPOSTHandler))
+	router.GET("/groups/:group_id/payments/:payment_id", CreateHandlerWithEnv(e, handlers.CreatePaymentGETHandler))
+	router.PUT("/groups/:group_id/payments/:payment_id", CreateHandlerWithEnv(e, handlers.CreatePaymentPUTHandler))
+	router.DELETE("/groups/:group_id/payments/:payment_id", CreateHandlerWithEnv(e, handlers.CreatePaymentDELETEHandler))
+
 	fmt.Println("Server started on port", e.Conf.Port)
 	return http.ListenAndServe(fmt.Sprintf(":%d", e.Conf.Port), router)
 }
 
-func createSchema() error {
-	db, err := DBConn()
+// migrate brings the schema of the database up to date. It is safe to run
+// every time the app is deployed.
+func migrate() error {
+	type migrator interface {
+		Migrate() (int, int, error)
+	}
+
+	var m migrator
+	switch *storeType {
+	case "sqlite":
+		db, err := sqlitestore.Open(*dbFile)
+		if err != nil {
+			return err
+		}
+		m = sqlitestore.MustCreate(db)
+	case "postgres":
+		db, err := DBConn()
+		if err != nil {
+			return err
+		}
+		m = postgrestore.MustCreate(db)
+	default:
+		return fmt.Errorf("store %q does not need migrating", *storeType)
+	}
+
+	from, to, err := m.Migrate()
 	if err != nil {
 		return err
 	}
-	store := postgrestore.MustCreate(db)
 
-	store.MustCreateTypes()
-	store.MustCreateTables()
+	if from == to {
+		fmt.Printf("Schema is up to date at version %d\n", to)
+	} else {
+		fmt.Printf("Migrated schema from version %d to %d\n", from, to)
+	}
 	return nil
 }
 
-func dropSchema() error {
-	db, err := DBConn()
+// generateRecurring creates any recurring expenses that have fallen due. This
+// is safe to run as often as required, e.g. daily from cron.
+func generateRecurring() error {
+	store, err := openStore()
 	if err != nil {
 		return err
 	}
 
-	store := postgrestore.MustCreate(db)
+	rates, err := loadRates()
+	if err != nil {
+		return err
+	}
 
-	store.MustDropTables()
-	store.MustDropTypes()
+	m := models.NewManager(store, rates, newNotifier(), nil)
+	es, err := m.GenerateRecurringExpenses(time.Now().UTC())
+	fmt.Printf("Created %d recurring expenses\n", len(es))
+	return err
+}
+
+// exportLedger writes the ledger of the group given by the group_id flag as
+// CSV to the out flag, or to stdout.
+func exportLedger() error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	m := models.NewManager(store, nil, nil, nil)
+	g, err := m.GroupByID(*groupID)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *outFile != "" {
+		out, err = os.Create(*outFile)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	return m.ExportLedger(g, out)
+}
+
+// importStatement imports the bank statement given by the import_file flag
+// into the group given by the group_id flag. The expenses are split equally
+// between all of the members of the group.
+func importStatement() error {
+	format := *importFormat
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(*importFile), ".")
+	}
+
+	f, err := models.ParseStatementFormat(format)
+	if err != nil {
+		return err
+	}
+
+	var mapping models.StatementMapping
+	err = json.Unmarshal([]byte(*csvMapping), &mapping)
+	if err != nil {
+		return fmt.Errorf("invalid csv_mapping: %v", err)
+	}
+
+	file, err := os.Open(*importFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	m := models.NewManager(store, nil, nil, nil)
+	g, err := m.GroupByID(*groupID)
+	if err != nil {
+		return err
+	}
+
+	var rows []*models.StatementRow
+	var es []*models.Expense
+	if *dryRun {
+		rows, err = m.PreviewStatement(g, file, f, mapping)
+	} else {
+		var members []*models.Member
+		members, err = m.GroupMembers(g)
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		for _, member := range members {
+			ids = append(ids, member.ID)
+		}
+
+		rows, es, err = m.ImportStatement(g, file, f, mapping, *payerID, *categoryID, models.EqualSplit(ids))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		status := "new"
+		switch {
+		case row.Error != "":
+			status = "skipped: " + row.Error
+		case row.Duplicate:
+			status = "duplicate"
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", row.Line, row.Date.Format(models.LedgerDateFormat),
+			row.Description, models.Money{Amount: int64(row.Amount), Currency: g.BaseCurrency()}, status)
+	}
+
+	fmt.Printf("Imported %d of %d transactions\n", len(es), len(rows))
 	return nil
 }
 
 func addAdmin() error {
-	// TODO: Parameter checking
-	db, err := DBConn()
+	store, err := openStore()
 	if err != nil {
 		return err
 	}
-	store := postgrestore.MustCreate(db)
-	store.MustPrepareStmts()
 	sessionStore := auth.NewCookieSessionStore(
 		[]byte("new-authentication-key"),
 		[]byte("new-encryption-key"))
 
-	um := auth.NewUserManager(nil, store, nil, sessionStore)
+	return insertAdmin(auth.NewUserManager(nil, store, nil, sessionStore))
+}
 
+// insertAdmin creates the admin user given by the admin flags.
+func insertAdmin(um *auth.UserManager) error {
+	// TODO: Parameter checking
 	user, err := um.New(*adminName, *adminEmail, *adminPw, *adminPw, true, true)
 	if err != nil {
 		return err
@@ -155,10 +435,12 @@ func addAdmin() error {
 }
 
 var actions = actionsMap{
-	"start":         start,
-	"create_schema": createSchema,
-	"drop_schema":   dropSchema,
-	"add_admin":     addAdmin,
+	"start":              start,
+	"migrate":            migrate,
+	"add_admin":          addAdmin,
+	"generate_recurring": generateRecurring,
+	"export_ledger":      exportLedger,
+	"import":             importStatement,
 }
 
 func main() {
@@ -150,7 +150,8 @@ func (h adminUserDELETEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = h.env.DeleteUserById(int64(uid))
+	// Receipts of the expenses deleted with the user are removed by models
+	err = h.env.Manager.DeleteUser(&auth.User{ID: int64(uid)}, h.env.UserManager.DeleteUser)
 	if err != nil {
 		jsonError(w, http.StatusInternalServerError, err.Error(), errors.Trace(err))
 		return
@@ -213,8 +214,9 @@ func (h adminGroupPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	newGroup := struct {
-		Name   string   `json:"name"`
-		Emails []string `json:"emails"`
+		Name     string   `json:"name"`
+		Currency string   `json:"currency"`
+		Emails   []string `json:"emails"`
 	}{}
 
 	err = json.NewDecoder(r.Body).Decode(&newGroup)
@@ -235,8 +237,11 @@ func (h adminGroupPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		users = append(users, u)
 	}
 
-	g, err := h.env.NewGroup(newGroup.Name)
-	if err != nil {
+	g, err := h.env.NewGroup(newGroup.Name, newGroup.Currency)
+	if errors.Cause(err) == models.ErrUnknownCurrency {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	} else if err != nil {
 		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
 		return
 	}
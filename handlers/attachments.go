@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// attachmentFormField is the field of the multipart form that holds an
+// uploaded receipt.
+const attachmentFormField = "file"
+
+// attachmentStatus returns the status code to respond with when a receipt
+// could not be attached.
+func attachmentStatus(err error) int {
+	switch errors.Cause(err) {
+	case models.ErrAttachmentTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case models.ErrAttachmentType:
+		return http.StatusUnsupportedMediaType
+	case models.ErrNoBlobStore:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// sessionAttachment retrieves the expense given by the expense_id route
+// parameter, as sessionExpense does, along with the attachment given by the
+// attachment_id route parameter. The attachment must belong to the expense.
+func (h *HandlerVars) sessionAttachment(w http.ResponseWriter, r *http.Request) (*models.Expense, *models.Attachment, int, error) {
+	_, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		return nil, nil, code, errors.Trace(err)
+	}
+
+	id, err := strconv.ParseInt(h.ps.ByName("attachment_id"), 10, 64)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.Trace(err)
+	}
+
+	a, err := h.env.AttachmentByID(id)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.Trace(err)
+	}
+
+	if a.ExpenseID != e.ID {
+		return nil, nil, http.StatusNotFound, errors.Errorf("attachment %d not on expense %d", a.ID, e.ID)
+	}
+
+	return e, a, http.StatusOK, nil
+}
+
+type attachmentsGETHandler struct {
+	*HandlerVars
+}
+
+func CreateAttachmentsGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return attachmentsGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the attachments of the expense, without their
+// contents.
+func (h attachmentsGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	as, err := h.env.ExpenseAttachments(e)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, as)
+}
+
+type attachmentPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateAttachmentPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return attachmentPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP attaches the receipt uploaded in the file field of a multipart
+// form to the expense. The form is read as it arrives, so that a file that is
+// too large is turned away without reading all of it.
+func (h attachmentPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			msg := "The receipt must be uploaded in the " + attachmentFormField + " field"
+			jsonError(w, http.StatusBadRequest, msg, errors.New(msg))
+			return
+		}
+
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+			return
+		}
+
+		if part.FormName() != attachmentFormField {
+			continue
+		}
+
+		a, err := h.env.AttachReceipt(e, part.FileName(), part)
+		if err != nil {
+			jsonError(w, attachmentStatus(err), err.Error(), errors.Trace(err))
+			return
+		}
+
+		jsonSuccess(w, a)
+		return
+	}
+}
+
+type attachmentGETHandler struct {
+	*HandlerVars
+}
+
+func CreateAttachmentGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return attachmentGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP sends the contents of the attachment, which only members of the
+// group can download. The type sent is the one found when the receipt was
+// attached, and browsers are told not to guess another.
+func (h attachmentGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, a, code, err := h.sessionAttachment(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	rc, err := h.env.OpenAttachment(a)
+	if err != nil {
+		code := attachmentStatus(err)
+		if errors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		glog.Errorf("Error sending attachment %d: %v", a.ID, errors.ErrorStack(err))
+	}
+}
+
+type attachmentDELETEHandler struct {
+	*HandlerVars
+}
+
+func CreateAttachmentDELETEHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return attachmentDELETEHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP removes the attachment from the expense, along with its contents.
+func (h attachmentDELETEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, a, code, err := h.sessionAttachment(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	err = h.env.DeleteAttachment(a)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, nil)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/julienschmidt/httprouter"
+
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+// upload attaches the data as a file in the form field given, as the user
+// given.
+func (te *testEnv) upload(ps httprouter.Params, u *auth.User, field string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	fw, err := mw.CreateFormFile(field, "receipt.png")
+	if err == nil {
+		_, err = fw.Write(data)
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		te.t.Fatalf("Error writing form: %v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return te.serveRequest(CreateAttachmentPOSTHandler, r, ps, u)
+}
+
+// newExpense creates an expense in the test group, returning its route
+// parameters.
+func (te *testEnv) newExpense() httprouter.Params {
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), te.members[0], te.newExpenseInfo("12.50"))
+	expectStatus(te.t, "creating expense", w, http.StatusOK)
+
+	var e models.Expense
+	decodeData(te.t, w, &e)
+	return te.groupParams("expense_id", strconv.FormatInt(e.ID, 10))
+}
+
+// attachmentParams returns the route parameters of the expense followed by
+// the attachment ID given.
+func attachmentParams(ps httprouter.Params, id string) httprouter.Params {
+	return append(append(httprouter.Params{}, ps...), httprouter.Param{Key: "attachment_id", Value: id})
+}
+
+func TestAttachmentWithoutBlobStore(t *testing.T) {
+	te := newTestEnv(t)
+	ps := te.newExpense()
+
+	w := te.upload(ps, te.members[0], attachmentFormField, testPNG)
+	expectStatus(t, "attaching receipt without a blob store", w, http.StatusNotImplemented)
+}
+
+func TestAttachment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "receipts")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	blobs, err := models.NewDirBlobStore(dir)
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+
+	te := newTestEnv(t)
+	te.setBlobStore(blobs)
+	u := te.members[0]
+	ps := te.newExpense()
+
+	w := te.serve(CreateAttachmentPOSTHandler, "POST", ps, u, "receipt")
+	expectStatus(t, "attaching receipt without a form", w, http.StatusBadRequest)
+
+	w = te.upload(ps, u, "other", testPNG)
+	expectStatus(t, "attaching receipt in the wrong field", w, http.StatusBadRequest)
+
+	w = te.upload(ps, u, attachmentFormField, []byte("plain text"))
+	expectStatus(t, "attaching text", w, http.StatusUnsupportedMediaType)
+
+	w = te.upload(ps, u, attachmentFormField, testPNG)
+	expectStatus(t, "attaching receipt", w, http.StatusOK)
+
+	var a models.Attachment
+	decodeData(t, w, &a)
+	id := strconv.FormatInt(a.ID, 10)
+
+	w = te.serve(CreateAttachmentGETHandler, "GET", attachmentParams(ps, id), u, nil)
+	expectStatus(t, "getting attachment", w, http.StatusOK)
+
+	if !bytes.Equal(w.Body.Bytes(), testPNG) {
+		t.Fatalf("Expected the receipt to be sent, got %q", w.Body.String())
+	}
+
+	// The attachment can only be reached through its own expense
+	other := attachmentParams(te.newExpense(), id)
+	w = te.serve(CreateAttachmentGETHandler, "GET", other, u, nil)
+	expectStatus(t, "getting attachment through another expense", w, http.StatusNotFound)
+
+	w = te.serve(CreateAttachmentDELETEHandler, "DELETE", other, u, nil)
+	expectStatus(t, "deleting attachment through another expense", w, http.StatusNotFound)
+
+	_, e := te.otherGroup()
+	w = te.serve(CreateAttachmentsGETHandler, "GET", te.groupParams("expense_id", strconv.FormatInt(e.ID, 10)), u, nil)
+	expectStatus(t, "listing attachments of another group's expense", w, http.StatusNotFound)
+
+	w = te.serve(CreateAttachmentGETHandler, "GET", attachmentParams(ps, "abc"), u, nil)
+	expectStatus(t, "getting attachment with invalid ID", w, http.StatusBadRequest)
+
+	w = te.serve(CreateAttachmentGETHandler, "GET", attachmentParams(ps, strconv.FormatInt(a.ID+1000, 10)), u, nil)
+	expectStatus(t, "getting unknown attachment", w, http.StatusNotFound)
+
+	w = te.serve(CreateAttachmentDELETEHandler, "DELETE", attachmentParams(ps, id), u, nil)
+	expectStatus(t, "deleting attachment", w, http.StatusOK)
+}
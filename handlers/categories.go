@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// categoryInfo is the body of a request to create or update a category. If
+// the colour is empty then the default colour is used. The budget is a string
+// in the major units of the group's currency e.g. "400.00", and an empty
+// budget means the category has no budget.
+type categoryInfo struct {
+	Name   string `json:"name"`
+	Colour string `json:"colour"`
+	Icon   string `json:"icon"`
+	Budget string `json:"budget"`
+}
+
+// decodeCategoryInfo reads the category from the request body, returning the
+// budget that it describes.
+func decodeCategoryInfo(r *http.Request, g *models.Group) (*categoryInfo, models.Pence, error) {
+	var info categoryInfo
+	err := json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+
+	if info.Budget == "" {
+		return &info, 0, nil
+	}
+
+	budget, err := models.MoneyFromString(info.Budget, g.BaseCurrency())
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+
+	return &info, budget.Pence(), nil
+}
+
+// categoryStatus returns the status code to respond with when a category
+// could not be saved or deleted.
+func categoryStatus(err error) int {
+	switch errors.Cause(err) {
+	case models.ErrInvalidCategoryName,
+		models.ErrInvalidColour,
+		models.ErrInvalidIcon,
+		models.ErrNegativePence:
+		return http.StatusBadRequest
+	case models.ErrDuplicateCategory,
+		models.ErrCategoryInUse:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// sessionCategory retrieves the group of the user logged in, along with the
+// category given by the category_id route parameter. The category must belong
+// to the group.
+func (h *HandlerVars) sessionCategory(w http.ResponseWriter, r *http.Request) (*models.Group, *models.Category, int, error) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		return nil, nil, code, errors.Trace(err)
+	}
+
+	id, err := strconv.ParseInt(h.ps.ByName("category_id"), 10, 64)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.Trace(err)
+	}
+
+	c, err := h.env.CategoryByID(id)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.Trace(err)
+	}
+
+	if c.GroupID != g.ID {
+		return nil, nil, http.StatusNotFound, errors.Errorf("category %d not in group %d", c.ID, g.ID)
+	}
+
+	return g, c, http.StatusOK, nil
+}
+
+type categoriesGETHandler struct {
+	*HandlerVars
+}
+
+func CreateCategoriesGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return categoriesGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the categories of the group, ordered by name.
+func (h categoriesGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	cs, err := h.env.GroupCategories(g)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, cs)
+}
+
+type categoryPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateCategoryPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return categoryPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP creates a new category in the group.
+func (h categoryPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, budget, err := decodeCategoryInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	c, err := h.env.NewCategory(g, info.Name, info.Colour, info.Icon, budget)
+	if err != nil {
+		jsonError(w, categoryStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, c)
+}
+
+type categoryPUTHandler struct {
+	*HandlerVars
+}
+
+func CreateCategoryPUTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return categoryPUTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP replaces the name, colour, icon and budget of a category. The
+// expenses in the category are unchanged.
+func (h categoryPUTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g, c, code, err := h.sessionCategory(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, budget, err := decodeCategoryInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	c.Name = info.Name
+	c.Colour = info.Colour
+	c.Icon = info.Icon
+	c.Budget = budget
+
+	err = h.env.UpdateCategory(c)
+	if err != nil {
+		jsonError(w, categoryStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, c)
+}
+
+type categoryDELETEHandler struct {
+	*HandlerVars
+}
+
+func CreateCategoryDELETEHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return categoryDELETEHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP removes a category from the group. Categories that are still used
+// by expenses or recurring expenses cannot be removed.
+func (h categoryDELETEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, c, code, err := h.sessionCategory(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	err = h.env.DeleteCategory(c)
+	if err != nil {
+		jsonError(w, categoryStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, nil)
+}
+
+type budgetsGETHandler struct {
+	*HandlerVars
+}
+
+func CreateBudgetsGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return budgetsGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the spending against the budget of each category of
+// the group that has one. The month is given by the month query parameter in
+// the form 2006-01, defaulting to the current month. The level of each status
+// is "warning" once 80% of the budget is spent and "over" once it is exceeded.
+func (h budgetsGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	month := time.Now().UTC()
+	if s := r.URL.Query().Get("month"); s != "" {
+		month, err = time.Parse("2006-01", s)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "month must be of the form YYYY-MM", errors.Trace(err))
+			return
+		}
+	}
+
+	bs, err := h.env.BudgetStatuses(g, month)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, bs)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCategoryValidation(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateCategoryPOSTHandler, "POST", te.groupParams(), u, categoryInfo{Name: "Travel", Budget: "100.00"})
+	expectStatus(t, "creating category", w, http.StatusOK)
+
+	var c models.Category
+	decodeData(t, w, &c)
+	ps := te.groupParams("category_id", strconv.FormatInt(c.ID, 10))
+
+	invalid := []struct {
+		name string
+		body interface{}
+	}{
+		{"invalid JSON", "{"},
+		{"empty name", categoryInfo{Name: ""}},
+		{"long name", categoryInfo{Name: strings.Repeat("a", 65)}},
+		{"invalid colour", categoryInfo{Name: "Travel", Colour: "red"}},
+		{"long icon", categoryInfo{Name: "Travel", Icon: strings.Repeat("a", 65)}},
+		{"invalid budget", categoryInfo{Name: "Travel", Budget: "abc"}},
+		{"negative budget", categoryInfo{Name: "Travel", Budget: "-5"}},
+	}
+
+	for _, test := range invalid {
+		w = te.serve(CreateCategoryPOSTHandler, "POST", te.groupParams(), u, test.body)
+		expectStatus(t, "creating category with "+test.name, w, http.StatusBadRequest)
+
+		w = te.serve(CreateCategoryPUTHandler, "PUT", ps, u, test.body)
+		expectStatus(t, "updating category with "+test.name, w, http.StatusBadRequest)
+	}
+
+	w = te.serve(CreateCategoryPOSTHandler, "POST", te.groupParams(), u, categoryInfo{Name: "Groceries"})
+	expectStatus(t, "creating duplicate category", w, http.StatusConflict)
+
+	w = te.serve(CreateCategoryPUTHandler, "PUT", ps, u, categoryInfo{Name: "Groceries"})
+	expectStatus(t, "renaming category to a duplicate", w, http.StatusConflict)
+}
+
+func TestCategoryNotInGroup(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	_, e := te.otherGroup()
+
+	ps := te.groupParams("category_id", strconv.FormatInt(e.CategoryID, 10))
+	w := te.serve(CreateCategoryPUTHandler, "PUT", ps, u, categoryInfo{Name: "Mine"})
+	expectStatus(t, "updating category of another group", w, http.StatusNotFound)
+
+	w = te.serve(CreateCategoryDELETEHandler, "DELETE", ps, u, nil)
+	expectStatus(t, "deleting category of another group", w, http.StatusNotFound)
+
+	w = te.serve(CreateCategoryDELETEHandler, "DELETE", te.groupParams("category_id", "abc"), u, nil)
+	expectStatus(t, "deleting category with invalid ID", w, http.StatusBadRequest)
+
+	w = te.serve(CreateCategoryDELETEHandler, "DELETE", te.groupParams("category_id", strconv.FormatInt(e.CategoryID+1000, 10)), u, nil)
+	expectStatus(t, "deleting unknown category", w, http.StatusNotFound)
+}
+
+func TestCategoryInUse(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("12.50"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	ps := te.groupParams("category_id", strconv.FormatInt(te.categoryID("Groceries"), 10))
+	w = te.serve(CreateCategoryDELETEHandler, "DELETE", ps, u, nil)
+	expectStatus(t, "deleting category in use", w, http.StatusConflict)
+}
+
+func TestBudgets(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serveURL(CreateBudgetsGETHandler, "GET", "/?month=2016-03", te.groupParams(), u, nil)
+	expectStatus(t, "getting budgets", w, http.StatusOK)
+
+	for _, month := range []string{"2016-13", "March", "2016-03-01"} {
+		w = te.serveURL(CreateBudgetsGETHandler, "GET", "/?month="+month, te.groupParams(), u, nil)
+		expectStatus(t, "getting budgets for "+month, w, http.StatusBadRequest)
+	}
+}
@@ -1,13 +1,270 @@
 package handlers
 
 import (
-	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/env"
 	"git.ianfross.com/ifross/expensetracker/models"
-	"git.ianfross.com/ifross/expensetracker/routeindex"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"encoding/json"
+	"net/http"
+	"strconv"
 )
 
-type ExpenseGetHandler struct {
-	Um    *auth.UserManager
-	M     *models.Manager
-	index routeindex.Interface
+// expenseInfo is the body of a request to create or update an expense. The
+// amount is a string in the major units of the currency e.g. "12.50". If the
+// currency is empty then the group's currency is used.
+type expenseInfo struct {
+	Amount      string       `json:"amount"`
+	Currency    string       `json:"currency"`
+	PayerID     int64        `json:"payerId"`
+	CategoryID  int64        `json:"categoryId"`
+	Description string       `json:"description"`
+	Split       models.Split `json:"split"`
+}
+
+// decodeExpenseInfo reads the expense from the request body, returning the
+// amount that it describes.
+func decodeExpenseInfo(r *http.Request, g *models.Group) (*expenseInfo, models.Money, error) {
+	var info expenseInfo
+	err := json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		return nil, models.Money{}, errors.Trace(err)
+	}
+
+	if info.Currency == "" {
+		info.Currency = g.BaseCurrency()
+	}
+
+	amount, err := models.MoneyFromString(info.Amount, info.Currency)
+	if err != nil {
+		return nil, models.Money{}, errors.Trace(err)
+	}
+
+	return &info, amount, nil
+}
+
+// expenseStatus returns the status code to respond with when an expense could
+// not be saved.
+func expenseStatus(err error) int {
+	switch errors.Cause(err) {
+	case models.ErrNotMember,
+		models.ErrCategoryNotInGroup,
+		models.ErrNegativePence,
+		models.ErrInvalidMoneyStr,
+		models.ErrMustAssignToUsers,
+		models.ErrNonPositiveWeight,
+		models.ErrWeightTooLarge,
+		models.ErrNegativeShareAmount,
+		models.ErrSplitAmountMismatch,
+		models.ErrSplitPercentageTotal,
+		models.ErrUnknownSplitMode,
+		models.ErrUnknownRemainder,
+		models.ErrUnknownCurrency,
+		models.ErrNoRate:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// sessionExpense retrieves the group of the user logged in, along with the
+// expense given by the expense_id route parameter. The expense must belong to
+// the group.
+func (h *HandlerVars) sessionExpense(w http.ResponseWriter, r *http.Request) (*models.Group, *models.Expense, int, error) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		return nil, nil, code, errors.Trace(err)
+	}
+
+	id, err := strconv.ParseInt(h.ps.ByName("expense_id"), 10, 64)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.Trace(err)
+	}
+
+	e, err := h.env.ExpenseByID(id)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.Trace(err)
+	}
+
+	if e.GroupID != g.ID {
+		return nil, nil, http.StatusNotFound, errors.Errorf("expense %d not in group %d", e.ID, g.ID)
+	}
+
+	return g, e, http.StatusOK, nil
+}
+
+type expensesGETHandler struct {
+	*HandlerVars
+}
+
+func CreateExpensesGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return expensesGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with a page of the expenses of the group, the most
+// recent first unless the order parameter is oldest. The from, to,
+// category_id, payer_id and participant_id parameters narrow the expenses
+// listed, and limit sets the size of the page. The page includes a next
+// cursor when there are more expenses, which is given as the cursor parameter
+// to get the following page with the same parameters.
+func (h expensesGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	query, msg, err := decodeExpenseQuery(r.URL.Query())
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, msg, errors.Trace(err))
+		return
+	}
+
+	page, err := h.env.ListExpenses(g, query)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Cause(err) == models.ErrInvalidQuery {
+			code = http.StatusBadRequest
+		}
+		jsonError(w, code, err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, page)
+}
+
+type expensePOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateExpensePOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return expensePOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP creates a new expense in the group. The payer and everybody in
+// the split must be members of the group, and the category must belong to
+// the group.
+func (h expensePOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, amount, err := decodeExpenseInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	e, err := h.env.NewExpense(g, amount, info.PayerID, info.CategoryID, info.Description, info.Split)
+	if err != nil {
+		jsonError(w, expenseStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, e)
+}
+
+type expenseGETHandler struct {
+	*HandlerVars
+}
+
+func CreateExpenseGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return expenseGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with a single expense and its assignments.
+func (h expenseGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, e)
+}
+
+type expensePUTHandler struct {
+	*HandlerVars
+}
+
+func CreateExpensePUTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return expensePUTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP replaces the details of an expense and reassigns it using the
+// split given.
+func (h expensePUTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, amount, err := decodeExpenseInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	e.Amount = amount.Pence()
+	e.Currency = amount.Currency
+	e.PayerID = info.PayerID
+	e.CategoryID = info.CategoryID
+	e.Description = info.Description
+
+	err = h.env.UpdateExpense(e, info.Split)
+	if err != nil {
+		jsonError(w, expenseStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, e)
+}
+
+type expenseDELETEHandler struct {
+	*HandlerVars
+}
+
+func CreateExpenseDELETEHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return expenseDELETEHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP removes an expense and its assignments.
+func (h expenseDELETEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, e, code, err := h.sessionExpense(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	err = h.env.DeleteExpense(e)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, nil)
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// newExpenseInfo returns the body of a request to create an expense of the
+// amount given, paid by the first member and split equally between both.
+func (te *testEnv) newExpenseInfo(amount string) expenseInfo {
+	ids := []int64{te.members[0].ID, te.members[1].ID}
+	return expenseInfo{
+		Amount:      amount,
+		PayerID:     te.members[0].ID,
+		CategoryID:  te.categoryID("Groceries"),
+		Description: "Shopping",
+		Split:       models.EqualSplit(ids),
+	}
+}
+
+func TestExpenseNonPositiveAmount(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("12.50"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	var e models.Expense
+	decodeData(t, w, &e)
+	ps := te.groupParams("expense_id", strconv.FormatInt(e.ID, 10))
+
+	for _, amount := range []string{"0", "-5", "abc"} {
+		w = te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo(amount))
+		expectStatus(t, "creating expense of "+amount, w, http.StatusBadRequest)
+
+		w = te.serve(CreateExpensePUTHandler, "PUT", ps, u, te.newExpenseInfo(amount))
+		expectStatus(t, "updating expense to "+amount, w, http.StatusBadRequest)
+	}
+}
+
+func TestExpenseValidation(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	_, other := te.otherGroup()
+
+	payer := te.newExpenseInfo("12.50")
+	payer.PayerID = te.outsider.ID
+
+	assignee := te.newExpenseInfo("12.50")
+	assignee.Split = models.EqualSplit([]int64{te.members[0].ID, te.outsider.ID})
+
+	category := te.newExpenseInfo("12.50")
+	category.CategoryID = other.CategoryID
+
+	currency := te.newExpenseInfo("12.50")
+	currency.Currency = "XXX"
+
+	invalid := []struct {
+		name string
+		body interface{}
+	}{
+		{"invalid JSON", "{"},
+		{"payer outside group", payer},
+		{"assignee outside group", assignee},
+		{"category of another group", category},
+		{"unknown currency", currency},
+	}
+
+	for _, test := range invalid {
+		w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, test.body)
+		expectStatus(t, "creating expense with "+test.name, w, http.StatusBadRequest)
+	}
+}
+
+func TestExpenseNotInGroup(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	_, other := te.otherGroup()
+
+	id := strconv.FormatInt(other.ID, 10)
+	w := te.serve(CreateExpenseGETHandler, "GET", te.groupParams("expense_id", id), u, nil)
+	expectStatus(t, "getting expense of another group", w, http.StatusNotFound)
+
+	w = te.serve(CreateExpensePUTHandler, "PUT", te.groupParams("expense_id", id), u, te.newExpenseInfo("1.00"))
+	expectStatus(t, "updating expense of another group", w, http.StatusNotFound)
+
+	w = te.serve(CreateExpenseDELETEHandler, "DELETE", te.groupParams("expense_id", id), u, nil)
+	expectStatus(t, "deleting expense of another group", w, http.StatusNotFound)
+
+	w = te.serve(CreateExpenseGETHandler, "GET", te.groupParams("expense_id", strconv.FormatInt(other.ID+1000, 10)), u, nil)
+	expectStatus(t, "getting unknown expense", w, http.StatusNotFound)
+
+	w = te.serve(CreateExpenseGETHandler, "GET", te.groupParams("expense_id", "abc"), u, nil)
+	expectStatus(t, "getting expense with invalid ID", w, http.StatusBadRequest)
+
+	if _, err := te.ExpenseByID(other.ID); err != nil {
+		t.Fatalf("Expected expense of another group to be kept: %v", err)
+	}
+}
+
+func TestExpensesInvalidQuery(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serveURL(CreateExpensesGETHandler, "GET", "/?limit=10", te.groupParams(), u, nil)
+	expectStatus(t, "listing expenses", w, http.StatusOK)
+
+	for _, q := range []string{"from=yesterday", "category_id=abc", "order=random", "limit=0", "cursor=abc"} {
+		w = te.serveURL(CreateExpensesGETHandler, "GET", "/?"+q, te.groupParams(), u, nil)
+		expectStatus(t, "listing expenses with "+q, w, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"net/http"
+	"strconv"
+)
+
+// sessionGroup retrieves the user logged in and the group given by the
+// group_id route parameter. If the user is not a member of the group then an
+// error is returned, along with the status code that should be sent.
+func (h *HandlerVars) sessionGroup(w http.ResponseWriter, r *http.Request) (*auth.User, *models.Group, int, error) {
+	u, err := h.env.UserManager.FromSession(w, r)
+	if err != nil {
+		return nil, nil, http.StatusUnauthorized, errors.Trace(err)
+	}
+
+	gid, err := strconv.ParseInt(h.ps.ByName("group_id"), 10, 64)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.Trace(err)
+	}
+
+	groups, err := h.env.UserGroups(u)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.Trace(err)
+	}
+
+	for _, g := range groups {
+		if g.ID == gid {
+			return u, g, http.StatusOK, nil
+		}
+	}
+
+	return nil, nil, http.StatusNotFound, errors.Errorf("user %s not in group %d", u, gid)
+}
+
+type userGroupsGETHandler struct {
+	*HandlerVars
+}
+
+func CreateUserGroupsGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return userGroupsGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the groups that the user logged in is a member of.
+func (h userGroupsGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := h.env.UserManager.FromSession(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusUnauthorized, errors.Trace(err))
+		return
+	}
+
+	groups, err := h.env.UserGroups(u)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, groups)
+}
+
+type groupGETHandler struct {
+	*HandlerVars
+}
+
+func CreateGroupGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return groupGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with a group along with its members.
+func (h groupGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	members, err := h.env.GroupMembers(g)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, struct {
+		*models.Group
+		Members []*models.Member `json:"members"`
+	}{g, members})
+}
+
+type groupLeavePOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateGroupLeavePOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return groupLeavePOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP removes the user logged in from the group. The user must have
+// settled up first.
+func (h groupLeavePOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	err = h.env.LeaveGroup(g, u)
+	if errors.Cause(err) == models.ErrOutstandingBalance {
+		jsonError(w, http.StatusConflict, err.Error(), errors.Trace(err))
+		return
+	} else if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, nil)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/julienschmidt/httprouter"
+
+	"net/http"
+	"testing"
+)
+
+func TestSessionGroup(t *testing.T) {
+	te := newTestEnv(t)
+
+	handlers := []struct {
+		name   string
+		create handlerCreator
+		method string
+	}{
+		{"group", CreateGroupGETHandler, "GET"},
+		{"leave", CreateGroupLeavePOSTHandler, "POST"},
+		{"expenses", CreateExpensesGETHandler, "GET"},
+		{"new expense", CreateExpensePOSTHandler, "POST"},
+		{"expense", CreateExpenseGETHandler, "GET"},
+		{"update expense", CreateExpensePUTHandler, "PUT"},
+		{"delete expense", CreateExpenseDELETEHandler, "DELETE"},
+		{"payments", CreatePaymentsGETHandler, "GET"},
+		{"new payment", CreatePaymentPOSTHandler, "POST"},
+		{"payment", CreatePaymentGETHandler, "GET"},
+		{"update payment", CreatePaymentPUTHandler, "PUT"},
+		{"delete payment", CreatePaymentDELETEHandler, "DELETE"},
+		{"settle up plan", CreateSettleUpGETHandler, "GET"},
+		{"settle up", CreateSettleUpPOSTHandler, "POST"},
+		{"statement preview", CreateStatementPreviewPOSTHandler, "POST"},
+		{"statement import", CreateStatementImportPOSTHandler, "POST"},
+		{"attachments", CreateAttachmentsGETHandler, "GET"},
+		{"new attachment", CreateAttachmentPOSTHandler, "POST"},
+		{"attachment", CreateAttachmentGETHandler, "GET"},
+		{"delete attachment", CreateAttachmentDELETEHandler, "DELETE"},
+		{"report", CreateReportGETHandler, "GET"},
+		{"search", CreateSearchGETHandler, "GET"},
+		{"categories", CreateCategoriesGETHandler, "GET"},
+		{"new category", CreateCategoryPOSTHandler, "POST"},
+		{"update category", CreateCategoryPUTHandler, "PUT"},
+		{"delete category", CreateCategoryDELETEHandler, "DELETE"},
+		{"budgets", CreateBudgetsGETHandler, "GET"},
+	}
+
+	badGroup := httprouter.Params{{Key: "group_id", Value: "abc"}}
+
+	for _, h := range handlers {
+		w := te.serve(h.create, h.method, te.groupParams(), nil, nil)
+		expectStatus(t, h.name+" without a session", w, http.StatusUnauthorized)
+
+		w = te.serve(h.create, h.method, te.groupParams(), te.outsider, nil)
+		expectStatus(t, h.name+" by a user outside the group", w, http.StatusNotFound)
+
+		w = te.serve(h.create, h.method, badGroup, te.members[0], nil)
+		expectStatus(t, h.name+" with an invalid group", w, http.StatusBadRequest)
+	}
+}
+
+func TestGroupLeave(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("12.50"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	w = te.serve(CreateGroupLeavePOSTHandler, "POST", te.groupParams(), u, nil)
+	expectStatus(t, "leaving with an outstanding balance", w, http.StatusConflict)
+
+	w = te.serve(CreateSettleUpGETHandler, "GET", te.groupParams(), u, nil)
+	expectStatus(t, "getting settle up plan", w, http.StatusOK)
+
+	plan := struct {
+		Payments []*models.Payment `json:"payments"`
+	}{}
+	decodeData(t, w, &plan)
+
+	w = te.serve(CreateSettleUpPOSTHandler, "POST", te.groupParams(), u, plan)
+	expectStatus(t, "settling up", w, http.StatusOK)
+
+	w = te.serve(CreateGroupLeavePOSTHandler, "POST", te.groupParams(), u, nil)
+	expectStatus(t, "leaving after settling up", w, http.StatusOK)
+
+	w = te.serve(CreateGroupGETHandler, "GET", te.groupParams(), u, nil)
+	expectStatus(t, "getting group after leaving", w, http.StatusNotFound)
+}
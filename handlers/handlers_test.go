@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+	"git.ianfross.com/ifross/expensetracker/models/memstore"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// testUserHeader holds the email of the user a test request is made by.
+const testUserHeader = "X-Test-User"
+
+// testSession logs in the user given by the testUserHeader of the request, so
+// that tests do not need cookies.
+type testSession struct{}
+
+func (testSession) User(w http.ResponseWriter, r *http.Request, us auth.Storer) (*auth.User, error) {
+	email := r.Header.Get(testUserHeader)
+	if email == "" {
+		return nil, errors.Trace(auth.ErrNoSession)
+	}
+
+	u, err := us.UserByEmail(email)
+	return u, errors.Trace(err)
+}
+
+func (testSession) LogUserOut(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (testSession) LogUserIn(w http.ResponseWriter, r *http.Request, u *auth.User) error {
+	return nil
+}
+
+// testEnv is an environment backed by an in memory store, with a group of two
+// members and a user that is not in the group.
+type testEnv struct {
+	*env.Env
+	t        *testing.T
+	store    models.Storer
+	group    *models.Group
+	members  []*auth.User
+	outsider *auth.User
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	st := memstore.New()
+	te := &testEnv{
+		Env: &env.Env{
+			Manager:     models.NewManager(st, nil, nil, nil),
+			UserManager: auth.NewUserManager(nil, st, nil, testSession{}),
+		},
+		t:     t,
+		store: st,
+	}
+
+	g, err := te.NewGroup("Test group", models.GBP)
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+	te.group = g
+
+	for _, email := range []string{"a@example.com", "b@example.com", "outsider@example.com"} {
+		u := &auth.User{Email: email, Name: "TEST", Active: true}
+		err = st.Insert(u)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+		}
+
+		if email == "outsider@example.com" {
+			te.outsider = u
+			continue
+		}
+
+		err = te.AddUserToGroup(g, u, false)
+		if err != nil {
+			t.Fatalf("Error adding user to group: %v", err)
+		}
+		te.members = append(te.members, u)
+	}
+
+	return te
+}
+
+// setBlobStore replaces the manager with one that keeps attachments in the
+// blob store given.
+func (te *testEnv) setBlobStore(b models.BlobStore) {
+	te.Manager = models.NewManager(te.store, nil, nil, b)
+}
+
+// groupParams returns the route parameters of the test group, followed by the
+// extra parameters given as name, value pairs.
+func (te *testEnv) groupParams(extra ...string) httprouter.Params {
+	ps := httprouter.Params{{Key: "group_id", Value: strconv.FormatInt(te.group.ID, 10)}}
+	for i := 0; i+1 < len(extra); i += 2 {
+		ps = append(ps, httprouter.Param{Key: extra[i], Value: extra[i+1]})
+	}
+	return ps
+}
+
+// categoryID returns the ID of the test group's category with the name given.
+func (te *testEnv) categoryID(name string) int64 {
+	cs, err := te.GroupCategories(te.group)
+	if err != nil {
+		te.t.Fatalf("Error getting categories: %v", err)
+	}
+
+	for _, c := range cs {
+		if c.Name == name {
+			return c.ID
+		}
+	}
+
+	te.t.Fatalf("No category %s", name)
+	return 0
+}
+
+// otherGroup creates a group that only the outsider is a member of, along
+// with an expense in it, so that tests can refer to things outside the test
+// group.
+func (te *testEnv) otherGroup() (*models.Group, *models.Expense) {
+	g, err := te.NewGroup("Other group", models.GBP)
+	if err != nil {
+		te.t.Fatalf("Error creating group: %v", err)
+	}
+
+	err = te.AddUserToGroup(g, te.outsider, false)
+	if err != nil {
+		te.t.Fatalf("Error adding user to group: %v", err)
+	}
+
+	cs, err := te.GroupCategories(g)
+	if err != nil || len(cs) == 0 {
+		te.t.Fatalf("Error getting categories: %v", err)
+	}
+
+	e, err := te.NewExpense(g, models.Money{Amount: 1000, Currency: models.GBP}, te.outsider.ID, cs[0].ID,
+		"Elsewhere", models.EqualSplit([]int64{te.outsider.ID}))
+	if err != nil {
+		te.t.Fatalf("Error creating expense: %v", err)
+	}
+
+	return g, e
+}
+
+// handlerCreator is the signature shared by the handler constructors.
+type handlerCreator func(*env.Env, http.ResponseWriter, *http.Request, httprouter.Params) (http.Handler, int, error)
+
+// serve makes a request as the user given, who is not logged in if nil, to
+// the handler created by create with the route parameters given. A body that
+// is not a string is encoded as JSON.
+func (te *testEnv) serve(create handlerCreator, method string, ps httprouter.Params, u *auth.User, body interface{}) *httptest.ResponseRecorder {
+	return te.serveURL(create, method, "/", ps, u, body)
+}
+
+// serveURL makes a request to the target given, which may have a query, as
+// serve does.
+func (te *testEnv) serveURL(create handlerCreator, method, target string, ps httprouter.Params, u *auth.User, body interface{}) *httptest.ResponseRecorder {
+	var rd io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		rd = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			te.t.Fatalf("Error encoding body: %v", err)
+		}
+		rd = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, target, rd)
+	return te.serveRequest(create, r, ps, u)
+}
+
+// serveRequest makes the request as the user given, as serve does.
+func (te *testEnv) serveRequest(create handlerCreator, r *http.Request, ps httprouter.Params, u *auth.User) *httptest.ResponseRecorder {
+	if u != nil {
+		r.Header.Set(testUserHeader, u.Email)
+	}
+
+	w := httptest.NewRecorder()
+	h, _, err := create(te.Env, w, r, ps)
+	if err != nil {
+		te.t.Fatalf("Error creating handler: %v", err)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// expectStatus fails the test if the response does not have the status code
+// given.
+func expectStatus(t *testing.T, name string, w *httptest.ResponseRecorder, code int) {
+	if w.Code != code {
+		t.Fatalf("Expected %s to respond %d, got %d: %s", name, code, w.Code, w.Body.String())
+	}
+}
+
+// decodeData decodes the data of a successful response into v.
+func decodeData(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	resp := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err == nil {
+		err = json.Unmarshal(resp.Data, v)
+	}
+
+	if err != nil {
+		t.Fatalf("Error decoding response %s: %v", w.Body.String(), err)
+	}
+}
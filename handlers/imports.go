@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// statementInfo is the body of a request to preview or import a bank
+// statement. The statement is the contents of the file, and the mapping is
+// only needed for CSV statements. The payer, category and split are only
+// needed to import.
+type statementInfo struct {
+	Statement  string                  `json:"statement"`
+	Mapping    models.StatementMapping `json:"mapping"`
+	PayerID    int64                   `json:"payerId"`
+	CategoryID int64                   `json:"categoryId"`
+	Split      models.Split            `json:"split"`
+}
+
+// statementFormat returns the format given by the format route parameter.
+func (h *HandlerVars) statementFormat() (models.StatementFormat, error) {
+	f, err := models.ParseStatementFormat(h.ps.ByName("format"))
+	return f, errors.Trace(err)
+}
+
+// statementStatus returns the status code to respond with when a statement
+// could not be previewed or imported.
+func statementStatus(err error) int {
+	cause := errors.Cause(err)
+	if _, ok := cause.(*csv.ParseError); ok {
+		return http.StatusBadRequest
+	}
+
+	switch cause {
+	case models.ErrInvalidMapping,
+		models.ErrInvalidStatement,
+		models.ErrStatementCurrency:
+		return http.StatusBadRequest
+	}
+
+	return expenseStatus(err)
+}
+
+type statementPreviewPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateStatementPreviewPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return statementPreviewPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the rows read from the statement, in the format
+// given by the route, marking those that have already been imported. Nothing
+// is saved.
+func (h statementPreviewPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	f, err := h.statementFormat()
+	if err != nil {
+		jsonError(w, http.StatusNotFound, err.Error(), errors.Trace(err))
+		return
+	}
+
+	var info statementInfo
+	err = json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	rows, err := h.env.PreviewStatement(g, strings.NewReader(info.Statement), f, info.Mapping)
+	if err != nil {
+		jsonError(w, statementStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, rows)
+}
+
+type statementImportPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateStatementImportPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return statementImportPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP creates an expense for each row of the statement that can be read
+// and has not already been imported, all within one transaction. It responds
+// with the rows, as from the preview, and the expenses created.
+func (h statementImportPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	f, err := h.statementFormat()
+	if err != nil {
+		jsonError(w, http.StatusNotFound, err.Error(), errors.Trace(err))
+		return
+	}
+
+	var info statementInfo
+	err = json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	rows, es, err := h.env.ImportStatement(g, strings.NewReader(info.Statement), f, info.Mapping,
+		info.PayerID, info.CategoryID, info.Split)
+	if err != nil {
+		jsonError(w, statementStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, struct {
+		Rows     []*models.StatementRow `json:"rows"`
+		Expenses []*models.Expense      `json:"expenses"`
+	}{rows, es})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"net/http"
+	"testing"
+)
+
+// newStatementInfo returns the body of a request to import a CSV statement of
+// two purchases, paid by the first member and split equally between both.
+func (te *testEnv) newStatementInfo() statementInfo {
+	ids := []int64{te.members[0].ID, te.members[1].ID}
+	return statementInfo{
+		Statement:  "01/03/2016,Coffee,3.50\n02/03/2016,Lunch,8.00\n",
+		Mapping:    models.StatementMapping{Date: 0, Description: 1, Amount: 2},
+		PayerID:    te.members[0].ID,
+		CategoryID: te.categoryID("Groceries"),
+		Split:      models.EqualSplit(ids),
+	}
+}
+
+func TestStatementImport(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	ps := te.groupParams("format", "csv")
+
+	w := te.serve(CreateStatementPreviewPOSTHandler, "POST", ps, u, te.newStatementInfo())
+	expectStatus(t, "previewing statement", w, http.StatusOK)
+
+	result := struct {
+		Rows     []*models.StatementRow `json:"rows"`
+		Expenses []*models.Expense      `json:"expenses"`
+	}{}
+
+	w = te.serve(CreateStatementImportPOSTHandler, "POST", ps, u, te.newStatementInfo())
+	expectStatus(t, "importing statement", w, http.StatusOK)
+	decodeData(t, w, &result)
+
+	if len(result.Expenses) != 2 {
+		t.Fatalf("Expected 2 expenses imported, got %d", len(result.Expenses))
+	}
+
+	w = te.serve(CreateStatementImportPOSTHandler, "POST", ps, u, te.newStatementInfo())
+	expectStatus(t, "importing statement again", w, http.StatusOK)
+	decodeData(t, w, &result)
+
+	if len(result.Expenses) != 0 {
+		t.Fatalf("Expected no expenses imported twice, got %d", len(result.Expenses))
+	}
+
+	for _, row := range result.Rows {
+		if !row.Duplicate {
+			t.Fatalf("Expected row %d to be marked as a duplicate", row.Line)
+		}
+	}
+}
+
+func TestStatementValidation(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	ps := te.groupParams("format", "csv")
+	_, other := te.otherGroup()
+
+	w := te.serve(CreateStatementPreviewPOSTHandler, "POST", te.groupParams("format", "pdf"), u, te.newStatementInfo())
+	expectStatus(t, "previewing statement of unknown format", w, http.StatusNotFound)
+
+	w = te.serve(CreateStatementImportPOSTHandler, "POST", te.groupParams("format", "pdf"), u, te.newStatementInfo())
+	expectStatus(t, "importing statement of unknown format", w, http.StatusNotFound)
+
+	mapping := te.newStatementInfo()
+	mapping.Mapping.Amount = mapping.Mapping.Date
+
+	quotes := te.newStatementInfo()
+	quotes.Statement = "01/03/2016,\"Coffee,3.50\n"
+
+	payer := te.newStatementInfo()
+	payer.PayerID = te.outsider.ID
+
+	category := te.newStatementInfo()
+	category.CategoryID = other.CategoryID
+
+	invalid := []struct {
+		name    string
+		body    interface{}
+		preview bool
+	}{
+		{"invalid JSON", "{", true},
+		{"invalid mapping", mapping, true},
+		{"invalid CSV", quotes, true},
+		{"payer outside group", payer, false},
+		{"category of another group", category, false},
+	}
+
+	for _, test := range invalid {
+		if test.preview {
+			w = te.serve(CreateStatementPreviewPOSTHandler, "POST", ps, u, test.body)
+			expectStatus(t, "previewing statement with "+test.name, w, http.StatusBadRequest)
+		}
+
+		w = te.serve(CreateStatementImportPOSTHandler, "POST", ps, u, test.body)
+		expectStatus(t, "importing statement with "+test.name, w, http.StatusBadRequest)
+	}
+}
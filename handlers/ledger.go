@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"fmt"
+	"net/http"
+)
+
+type ledgerGETHandler struct {
+	*HandlerVars
+}
+
+func CreateLedgerGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return ledgerGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP streams the full ledger of the group as a CSV attachment. Once
+// the first row has been sent the status can no longer be changed, so later
+// errors are only logged and the download is cut short.
+func (h ledgerGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="group-%d-ledger.csv"`, g.ID))
+
+	err = h.env.ExportLedger(g, w)
+	if err != nil {
+		glog.Errorf("Error exporting ledger of group %d: %v", g.ID, errors.ErrorStack(err))
+	}
+}
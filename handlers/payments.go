@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type settleUpGETHandler struct {
+	*HandlerVars
+}
+
+func CreateSettleUpGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return settleUpGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the current balances of the group along with the
+// payments that would settle them.
+func (h settleUpGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	b, err := h.env.GroupBalances(g)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	payments, err := h.env.SettleUpPlan(g)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, struct {
+		Balances models.Balances   `json:"balances"`
+		Payments []*models.Payment `json:"payments"`
+	}{b, payments})
+}
+
+type settleUpPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateSettleUpPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return settleUpPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP records all of the payments needed to settle the group. The
+// request contains the plan that was shown to the user. If the plan no longer
+// matches the balances of the group then nothing is saved.
+func (h settleUpPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	confirmed := struct {
+		Payments []*models.Payment `json:"payments"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&confirmed)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "The settle up plan must be supplied", errors.Trace(err))
+		return
+	}
+
+	payments, err := h.env.SettleUp(g, confirmed.Payments)
+	if errors.Cause(err) == models.ErrPlanChanged {
+		jsonError(w, http.StatusConflict, models.ErrPlanChanged.Error(), errors.Trace(err))
+		return
+	} else if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, payments)
+}
+
+// paymentInfo is the body of a request to record or update a payment. The
+// amount is a string in the major units of the currency e.g. "12.50". If the
+// currency is empty then the group's currency is used.
+type paymentInfo struct {
+	Amount     string `json:"amount"`
+	Currency   string `json:"currency"`
+	GiverID    int64  `json:"giverId"`
+	ReceiverID int64  `json:"receiverId"`
+}
+
+// decodePaymentInfo reads the payment from the request body, returning the
+// amount that it describes.
+func decodePaymentInfo(r *http.Request, g *models.Group) (*paymentInfo, models.Money, error) {
+	var info paymentInfo
+	err := json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		return nil, models.Money{}, errors.Trace(err)
+	}
+
+	if info.Currency == "" {
+		info.Currency = g.BaseCurrency()
+	}
+
+	amount, err := models.MoneyFromString(info.Amount, info.Currency)
+	if err != nil {
+		return nil, models.Money{}, errors.Trace(err)
+	}
+
+	return &info, amount, nil
+}
+
+// paymentStatus returns the status code to respond with when a payment could
+// not be saved.
+func paymentStatus(err error) int {
+	switch errors.Cause(err) {
+	case models.ErrNotMember,
+		models.ErrPaymentToSelf,
+		models.ErrNegativePence,
+		models.ErrUnknownCurrency,
+		models.ErrNoRate:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// sessionPayment retrieves the group of the user logged in, along with the
+// payment given by the payment_id route parameter. The payment must belong to
+// the group.
+func (h *HandlerVars) sessionPayment(w http.ResponseWriter, r *http.Request) (*models.Group, *models.Payment, int, error) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		return nil, nil, code, errors.Trace(err)
+	}
+
+	id, err := strconv.ParseInt(h.ps.ByName("payment_id"), 10, 64)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.Trace(err)
+	}
+
+	p, err := h.env.PaymentByID(id)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.Trace(err)
+	}
+
+	if p.GroupID != g.ID {
+		return nil, nil, http.StatusNotFound, errors.Errorf("payment %d not in group %d", p.ID, g.ID)
+	}
+
+	return g, p, http.StatusOK, nil
+}
+
+type paymentsGETHandler struct {
+	*HandlerVars
+}
+
+func CreatePaymentsGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return paymentsGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with all of the payments made within the group.
+func (h paymentsGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	payments, err := h.env.GroupPayments(g)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, payments)
+}
+
+type paymentPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreatePaymentPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return paymentPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP records a payment between two members of the group.
+func (h paymentPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, amount, err := decodePaymentInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	p, err := h.env.InsertPayment(g, info.GiverID, info.ReceiverID, amount)
+	if err != nil {
+		jsonError(w, paymentStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, p)
+}
+
+type paymentGETHandler struct {
+	*HandlerVars
+}
+
+func CreatePaymentGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return paymentGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with a single payment.
+func (h paymentGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, p, code, err := h.sessionPayment(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, p)
+}
+
+type paymentPUTHandler struct {
+	*HandlerVars
+}
+
+func CreatePaymentPUTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return paymentPUTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP replaces the details of a payment.
+func (h paymentPUTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g, p, code, err := h.sessionPayment(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	info, amount, err := decodePaymentInfo(r, g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	p.Amount = amount.Pence()
+	p.Currency = amount.Currency
+	p.GiverID = info.GiverID
+	p.ReceiverID = info.ReceiverID
+
+	err = h.env.UpdatePayment(p)
+	if err != nil {
+		jsonError(w, paymentStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, p)
+}
+
+type paymentDELETEHandler struct {
+	*HandlerVars
+}
+
+func CreatePaymentDELETEHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return paymentDELETEHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP removes a payment.
+func (h paymentDELETEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, p, code, err := h.sessionPayment(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	err = h.env.DeletePayment(p)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, nil)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/julienschmidt/httprouter"
+
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPaymentValidation(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	giver, receiver := te.members[0].ID, te.members[1].ID
+
+	w := te.serve(CreatePaymentPOSTHandler, "POST", te.groupParams(), u, paymentInfo{Amount: "5.00", GiverID: giver, ReceiverID: receiver})
+	expectStatus(t, "creating payment", w, http.StatusOK)
+
+	var p models.Payment
+	decodeData(t, w, &p)
+	ps := te.groupParams("payment_id", strconv.FormatInt(p.ID, 10))
+
+	invalid := []struct {
+		name string
+		body interface{}
+	}{
+		{"invalid JSON", "{"},
+		{"invalid amount", paymentInfo{Amount: "abc", GiverID: giver, ReceiverID: receiver}},
+		{"negative amount", paymentInfo{Amount: "-5", GiverID: giver, ReceiverID: receiver}},
+		{"unknown currency", paymentInfo{Amount: "5.00", Currency: "XXX", GiverID: giver, ReceiverID: receiver}},
+		{"payment to self", paymentInfo{Amount: "5.00", GiverID: giver, ReceiverID: giver}},
+		{"giver outside group", paymentInfo{Amount: "5.00", GiverID: te.outsider.ID, ReceiverID: receiver}},
+	}
+
+	for _, test := range invalid {
+		w = te.serve(CreatePaymentPOSTHandler, "POST", te.groupParams(), u, test.body)
+		expectStatus(t, "creating payment with "+test.name, w, http.StatusBadRequest)
+
+		w = te.serve(CreatePaymentPUTHandler, "PUT", ps, u, test.body)
+		expectStatus(t, "updating payment with "+test.name, w, http.StatusBadRequest)
+	}
+}
+
+func TestPaymentNotInGroup(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+	g, _ := te.otherGroup()
+
+	w := te.serve(CreatePaymentPOSTHandler, "POST", te.groupParams(), u,
+		paymentInfo{Amount: "5.00", GiverID: te.members[0].ID, ReceiverID: te.members[1].ID})
+	expectStatus(t, "creating payment", w, http.StatusOK)
+
+	var p models.Payment
+	decodeData(t, w, &p)
+	id := strconv.FormatInt(p.ID, 10)
+
+	other := httprouter.Params{{Key: "group_id", Value: strconv.FormatInt(g.ID, 10)}, {Key: "payment_id", Value: id}}
+	w = te.serve(CreatePaymentGETHandler, "GET", other, te.outsider, nil)
+	expectStatus(t, "getting payment through another group", w, http.StatusNotFound)
+
+	w = te.serve(CreatePaymentDELETEHandler, "DELETE", other, te.outsider, nil)
+	expectStatus(t, "deleting payment through another group", w, http.StatusNotFound)
+
+	w = te.serve(CreatePaymentGETHandler, "GET", te.groupParams("payment_id", strconv.FormatInt(p.ID+1000, 10)), u, nil)
+	expectStatus(t, "getting unknown payment", w, http.StatusNotFound)
+
+	w = te.serve(CreatePaymentGETHandler, "GET", te.groupParams("payment_id", "abc"), u, nil)
+	expectStatus(t, "getting payment with invalid ID", w, http.StatusBadRequest)
+
+	w = te.serve(CreatePaymentGETHandler, "GET", te.groupParams("payment_id", id), u, nil)
+	expectStatus(t, "getting payment", w, http.StatusOK)
+}
+
+func TestSettleUpPlanChanged(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("12.50"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	w = te.serve(CreateSettleUpGETHandler, "GET", te.groupParams(), u, nil)
+	expectStatus(t, "getting settle up plan", w, http.StatusOK)
+
+	plan := struct {
+		Payments []*models.Payment `json:"payments"`
+	}{}
+	decodeData(t, w, &plan)
+
+	w = te.serve(CreateSettleUpPOSTHandler, "POST", te.groupParams(), u, "{")
+	expectStatus(t, "settling up with invalid JSON", w, http.StatusBadRequest)
+
+	// Another expense changes the balances after the plan was shown
+	w = te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("3.00"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	w = te.serve(CreateSettleUpPOSTHandler, "POST", te.groupParams(), u, plan)
+	expectStatus(t, "settling up with a stale plan", w, http.StatusConflict)
+
+	ps, err := te.GroupPayments(te.group)
+	if err != nil {
+		t.Fatalf("Error getting payments: %v", err)
+	}
+
+	if len(ps) != 0 {
+		t.Fatalf("Expected no payments after a stale plan, got %d", len(ps))
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeExpenseQuery reads a query for a page of expenses from the query
+// parameters. The from and to dates are of the form YYYY-MM-DD, and to is
+// exclusive. category_id may be repeated, or hold several IDs separated by
+// commas. An error message for the user is returned with any error.
+func decodeExpenseQuery(q url.Values) (models.ExpenseQuery, string, error) {
+	var query models.ExpenseQuery
+
+	dates := []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	}
+
+	for _, d := range dates {
+		s := q.Get(d.param)
+		if s == "" {
+			continue
+		}
+
+		t, err := time.Parse(reportDateFormat, s)
+		if err != nil {
+			return query, d.param + " must be of the form YYYY-MM-DD", errors.Trace(err)
+		}
+		*d.dest = t
+	}
+
+	for _, param := range q["category_id"] {
+		for _, s := range strings.Split(param, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return query, "category_id must be an ID", errors.Trace(err)
+			}
+			query.CategoryIDs = append(query.CategoryIDs, id)
+		}
+	}
+
+	ids := []struct {
+		param string
+		dest  *int64
+	}{
+		{"payer_id", &query.PayerID},
+		{"participant_id", &query.ParticipantID},
+	}
+
+	for _, id := range ids {
+		s := q.Get(id.param)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return query, id.param + " must be an ID", errors.Trace(err)
+		}
+		*id.dest = n
+	}
+
+	var err error
+	if s := q.Get("order"); s != "" {
+		query.Order, err = models.ParseExpenseOrder(s)
+		if err != nil {
+			return query, "order must be newest or oldest", errors.Trace(err)
+		}
+	}
+
+	if s := q.Get("limit"); s != "" {
+		query.Limit, err = strconv.Atoi(s)
+		if err != nil || query.Limit < 1 {
+			return query, "limit must be a positive number", errors.Errorf("invalid limit %q", s)
+		}
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		query.Cursor, err = models.ParseExpenseCursor(s)
+		if err != nil {
+			return query, "cursor is not valid", errors.Trace(err)
+		}
+	}
+
+	return query, "", nil
+}
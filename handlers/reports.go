@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"net/http"
+	"time"
+)
+
+// reportDateFormat is the format of the from and to query parameters.
+const reportDateFormat = "2006-01-02"
+
+type reportGETHandler struct {
+	*HandlerVars
+}
+
+func CreateReportGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return reportGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the spending of the group broken down by month,
+// category and user. The from and to query parameters are the first day of
+// the report and the day after the last, e.g. 2016-01-01, and default to the
+// last twelve months including this one. The by parameter is "payer" or
+// "assignee", defaulting to "payer".
+func (h reportGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(-1, 0, 0)
+
+	q := r.URL.Query()
+	if s := q.Get("from"); s != "" {
+		from, err = time.Parse(reportDateFormat, s)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "from must be of the form YYYY-MM-DD", errors.Trace(err))
+			return
+		}
+	}
+
+	if s := q.Get("to"); s != "" {
+		to, err = time.Parse(reportDateFormat, s)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "to must be of the form YYYY-MM-DD", errors.Trace(err))
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		jsonError(w, http.StatusBadRequest, "from must be before to", errors.Errorf("from %s after to %s", from, to))
+		return
+	}
+
+	by := models.ReportByPayer
+	if s := q.Get("by"); s != "" {
+		by, err = models.ParseReportBy(s)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+			return
+		}
+	}
+
+	report, err := h.env.SpendingReport(g, from, to, by)
+	if err != nil {
+		jsonErrorWithCodeText(w, http.StatusInternalServerError, errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, report)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReportInvalidQuery(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serveURL(CreateReportGETHandler, "GET", "/?from=2016-01-01&to=2017-01-01&by=assignee", te.groupParams(), u, nil)
+	expectStatus(t, "getting report", w, http.StatusOK)
+
+	queries := []string{
+		"from=2016-01",
+		"to=tomorrow",
+		"from=2017-01-01&to=2016-01-01",
+		"from=2016-01-01&to=2016-01-01",
+		"by=category",
+	}
+
+	for _, q := range queries {
+		w = te.serveURL(CreateReportGETHandler, "GET", "/?"+q, te.groupParams(), u, nil)
+		expectStatus(t, "getting report with "+q, w, http.StatusBadRequest)
+	}
+}
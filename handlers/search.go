@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// decodeExpenseSearch reads a search from the query parameters. The min and
+// max amounts are in the major units of the group's currency e.g. "12.50",
+// and the category and payer are IDs. An error message for the user is
+// returned with any error.
+func decodeExpenseSearch(q url.Values, g *models.Group) (models.ExpenseSearch, string, error) {
+	search := models.ExpenseSearch{Text: q.Get("q")}
+
+	amounts := []struct {
+		param string
+		dest  *models.Pence
+	}{
+		{"min", &search.MinAmount},
+		{"max", &search.MaxAmount},
+	}
+
+	for _, a := range amounts {
+		s := q.Get(a.param)
+		if s == "" {
+			continue
+		}
+
+		m, err := models.MoneyFromString(s, g.BaseCurrency())
+		if err != nil {
+			return search, a.param + " must be an amount e.g. 12.50", errors.Trace(err)
+		}
+		*a.dest = m.Pence()
+	}
+
+	ids := []struct {
+		param string
+		dest  *int64
+	}{
+		{"category_id", &search.CategoryID},
+		{"payer_id", &search.PayerID},
+	}
+
+	for _, id := range ids {
+		s := q.Get(id.param)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return search, id.param + " must be an ID", errors.Trace(err)
+		}
+		*id.dest = n
+	}
+
+	return search, "", nil
+}
+
+type searchGETHandler struct {
+	*HandlerVars
+}
+
+func CreateSearchGETHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return searchGETHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP responds with the expenses of the group matching the search, the
+// most recent first. The q query parameter is the text to look for in the
+// description, and the min, max, category_id and payer_id parameters narrow
+// the search further. All of them are optional.
+func (h searchGETHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	search, msg, err := decodeExpenseSearch(r.URL.Query(), g)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, msg, errors.Trace(err))
+		return
+	}
+
+	es, err := h.env.SearchExpenses(g, search)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Cause(err) == models.ErrInvalidSearch {
+			code = http.StatusBadRequest
+		}
+		jsonError(w, code, err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, es)
+}
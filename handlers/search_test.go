@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"net/http"
+	"testing"
+)
+
+func TestSearchInvalidQuery(t *testing.T) {
+	te := newTestEnv(t)
+	u := te.members[0]
+
+	w := te.serve(CreateExpensePOSTHandler, "POST", te.groupParams(), u, te.newExpenseInfo("12.50"))
+	expectStatus(t, "creating expense", w, http.StatusOK)
+
+	w = te.serveURL(CreateSearchGETHandler, "GET", "/?q=shopping&min=10&max=20", te.groupParams(), u, nil)
+	expectStatus(t, "searching", w, http.StatusOK)
+
+	var es []*models.Expense
+	decodeData(t, w, &es)
+	if len(es) != 1 {
+		t.Fatalf("Expected 1 expense found, got %d", len(es))
+	}
+
+	queries := []string{
+		"min=abc",
+		"max=12.5.0",
+		"min=-5",
+		"min=20&max=10",
+		"category_id=abc",
+		"payer_id=1.5",
+	}
+
+	for _, q := range queries {
+		w = te.serveURL(CreateSearchGETHandler, "GET", "/?"+q, te.groupParams(), u, nil)
+		expectStatus(t, "searching with "+q, w, http.StatusBadRequest)
+	}
+}
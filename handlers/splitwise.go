@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"git.ianfross.com/ifross/expensetracker/env"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// splitwiseInfo is the body of a request to import a Splitwise export. The
+// export is the contents of the file, in the format given, which is either
+// "csv" or "json". The emails map the name of each person in a CSV export to
+// their email, and are not needed for JSON. Expenses whose category is not in
+// the group are put in the category given.
+type splitwiseInfo struct {
+	Format     string            `json:"format"`
+	Export     string            `json:"export"`
+	Emails     map[string]string `json:"emails"`
+	CategoryID int64             `json:"categoryId"`
+}
+
+// splitwiseStatus returns the status code to respond with when a Splitwise
+// export could not be imported.
+func splitwiseStatus(err error) int {
+	cause := errors.Cause(err)
+	if _, ok := cause.(*csv.ParseError); ok {
+		return http.StatusBadRequest
+	}
+
+	switch cause {
+	case models.ErrInvalidSplitwise,
+		models.ErrUnknownSplitwiseUser,
+		models.ErrSplitwiseUnbalanced:
+		return http.StatusBadRequest
+	}
+
+	return expenseStatus(err)
+}
+
+type splitwiseImportPOSTHandler struct {
+	*HandlerVars
+}
+
+func CreateSplitwiseImportPOSTHandler(
+	e *env.Env,
+	w http.ResponseWriter,
+	r *http.Request,
+	ps httprouter.Params) (http.Handler, int, error) {
+	return splitwiseImportPOSTHandler{createHandlerVars(e, ps)}, http.StatusOK, nil
+}
+
+// ServeHTTP recreates the expenses and payments of a Splitwise export in the
+// group, all within one transaction, and responds with what was created.
+func (h splitwiseImportPOSTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, g, code, err := h.sessionGroup(w, r)
+	if err != nil {
+		jsonErrorWithCodeText(w, code, errors.Trace(err))
+		return
+	}
+
+	var info splitwiseInfo
+	err = json.NewDecoder(r.Body).Decode(&info)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error(), errors.Trace(err))
+		return
+	}
+
+	var ses []*models.SplitwiseExpense
+	switch strings.ToLower(info.Format) {
+	case "csv":
+		ses, err = models.ParseSplitwiseCSV(strings.NewReader(info.Export), info.Emails)
+	case "json":
+		ses, err = models.ParseSplitwiseJSON(strings.NewReader(info.Export))
+	default:
+		msg := fmt.Sprintf("Splitwise exports must be csv or json, not %q", info.Format)
+		jsonError(w, http.StatusBadRequest, msg, errors.New(msg))
+		return
+	}
+
+	if err != nil {
+		jsonError(w, splitwiseStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	es, ps, err := h.env.ImportSplitwise(g, ses, info.CategoryID)
+	if err != nil {
+		jsonError(w, splitwiseStatus(err), err.Error(), errors.Trace(err))
+		return
+	}
+
+	jsonSuccess(w, struct {
+		Expenses []*models.Expense `json:"expenses"`
+		Payments []*models.Payment `json:"payments"`
+	}{es, ps})
+}
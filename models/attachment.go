@@ -0,0 +1,199 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxAttachmentSize is the largest file, in bytes, that can be attached to
+// an expense.
+const MaxAttachmentSize = 10 << 20
+
+var (
+	// ErrNoBlobStore is returned when attaching a receipt to an expense
+	// without anywhere to store it
+	ErrNoBlobStore = errors.New("Receipts cannot be attached as no storage has been set up")
+
+	// ErrAttachmentTooLarge is returned when attaching a file larger than
+	// MaxAttachmentSize
+	ErrAttachmentTooLarge = errors.New("Receipts must be no larger than 10MB")
+
+	// ErrAttachmentType is returned when attaching a file that is not one of
+	// the AttachmentContentTypes
+	ErrAttachmentType = errors.New("Receipts must be a JPEG, PNG, GIF or WebP photo, or a PDF")
+)
+
+// AttachmentContentTypes are the types of file that can be attached to an
+// expense. The type is worked out from the contents of the file, rather than
+// trusting the type given by whoever uploaded it.
+var AttachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// Attachment is a file, such as a photo of a receipt, attached to an expense.
+// The contents are kept in the BlobStore of the Manager under the key, while
+// the attachment itself is kept with the expense, and deleted along with it.
+// The size is in bytes.
+type Attachment struct {
+	ID          int64     `db:"id" json:"id"`
+	ExpenseID   int64     `db:"expense_id" json:"expenseId"`
+	Filename    string    `db:"filename" json:"filename"`
+	ContentType string    `db:"content_type" json:"contentType"`
+	Size        int64     `db:"size" json:"size"`
+	Key         string    `db:"blob_key" json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// newBlobKey creates a random key for an attachment of the expense. Keys are
+// random so that they cannot be guessed, and are never reused.
+func newBlobKey(e *Expense) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Annotate(err, "Could not create blob key")
+	}
+
+	return fmt.Sprintf("receipts/%d/%s", e.GroupID, hex.EncodeToString(b)), nil
+}
+
+// attachmentFilename removes any directories from the name of an uploaded
+// file, which some browsers include.
+func attachmentFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.Replace(name, "\\", "/", -1)))
+	if name == "." || name == "/" || name == "" {
+		return "receipt"
+	}
+	return name
+}
+
+// AttachReceipt saves the file read from the reader as an attachment of the
+// expense. The file must be no larger than MaxAttachmentSize and be one of
+// the AttachmentContentTypes.
+func (m Manager) AttachReceipt(e *Expense, filename string, r io.Reader) (*Attachment, error) {
+	if m.blobs == nil {
+		return nil, errors.Trace(ErrNoBlobStore)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read receipt")
+	}
+
+	if len(data) > MaxAttachmentSize {
+		return nil, errors.Trace(ErrAttachmentTooLarge)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !AttachmentContentTypes[contentType] {
+		return nil, errors.Annotatef(ErrAttachmentType, "got %s", contentType)
+	}
+
+	key, err := newBlobKey(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = m.blobs.Put(key, bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not store receipt")
+	}
+
+	a := &Attachment{
+		ExpenseID:   e.ID,
+		Filename:    attachmentFilename(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Key:         key,
+	}
+
+	err = m.store.InsertAttachment(a)
+	if err != nil {
+		// Don't leave the receipt behind without its attachment
+		_ = m.blobs.Delete(key)
+		return nil, errors.Annotate(err, "Could not attach receipt")
+	}
+
+	return a, nil
+}
+
+// ExpenseAttachments returns the attachments of the expense, in the order
+// they were attached.
+func (m Manager) ExpenseAttachments(e *Expense) ([]*Attachment, error) {
+	as, err := m.store.AttachmentsByExpense(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if as == nil {
+		as = []*Attachment{}
+	}
+	return as, nil
+}
+
+// AttachmentByID retrieves an attachment, without its contents.
+func (m Manager) AttachmentByID(id int64) (*Attachment, error) {
+	a, err := m.store.AttachmentByID(id)
+	return a, errors.Trace(err)
+}
+
+// OpenAttachment opens the contents of the attachment, which the caller must
+// close.
+func (m Manager) OpenAttachment(a *Attachment) (io.ReadCloser, error) {
+	if m.blobs == nil {
+		return nil, errors.Trace(ErrNoBlobStore)
+	}
+
+	rc, err := m.blobs.Get(a.Key)
+	return rc, errors.Trace(err)
+}
+
+// DeleteAttachment removes the attachment from its expense, along with its
+// contents.
+func (m Manager) DeleteAttachment(a *Attachment) error {
+	key := a.Key
+	err := m.store.DeleteAttachment(a)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.deleteBlobs(key))
+}
+
+// attachmentKeys returns the keys of the contents of the attachments.
+func attachmentKeys(as []*Attachment) []string {
+	var keys []string
+	for _, a := range as {
+		keys = append(keys, a.Key)
+	}
+	return keys
+}
+
+// deleteBlobs removes the contents of attachments that have been deleted.
+func (m Manager) deleteBlobs(keys ...string) error {
+	if m.blobs == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		err := m.blobs.Delete(key)
+		if err != nil {
+			return errors.Annotate(err, "Could not delete receipt")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+	"git.ianfross.com/ifross/expensetracker/models/memstore"
+
+	"github.com/juju/errors"
+
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPNG starts with the signature of a PNG, which is all that is needed for
+// its type to be detected.
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func newTestBlobStore(t *testing.T) (*models.DirBlobStore, string) {
+	dir, err := ioutil.TempDir("", "receipts")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+
+	blobs, err := models.NewDirBlobStore(dir)
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+
+	return blobs, dir
+}
+
+func TestDirBlobStore(t *testing.T) {
+	blobs, dir := newTestBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	err := blobs.Put("a/b", strings.NewReader("receipt"))
+	if err != nil {
+		t.Fatalf("Error putting blob: %v", err)
+	}
+
+	rc, err := blobs.Get("a/b")
+	if err != nil {
+		t.Fatalf("Error getting blob: %v", err)
+	}
+
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "receipt" {
+		t.Fatalf("Expected blob to contain receipt, got %q (err=%v)", data, err)
+	}
+
+	for _, key := range []string{"", "../escaped", "/etc/passwd"} {
+		err = blobs.Put(key, strings.NewReader("receipt"))
+		if errors.Cause(err) != models.ErrInvalidBlobKey {
+			t.Fatalf("Expected ErrInvalidBlobKey putting %q, got %v", key, err)
+		}
+	}
+
+	err = blobs.Delete("a/b")
+	if err != nil {
+		t.Fatalf("Error deleting blob: %v", err)
+	}
+
+	_, err = blobs.Get("a/b")
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected not found getting deleted blob, got %v", err)
+	}
+
+	err = blobs.Delete("a/b")
+	if err != nil {
+		t.Fatalf("Expected no error deleting blob twice, got %v", err)
+	}
+}
+
+func TestAttachReceipt(t *testing.T) {
+	blobs, dir := newTestBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	m, g, us := newBlobTestGroup(t, 1, nil, blobs)
+	e, err := m.NewExpense(g, models.Money{Amount: 500, Currency: models.GBP}, us[0].ID, mustCategory(t, m, g, "Groceries"), "Shopping", models.EqualSplit([]int64{us[0].ID}))
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	a, err := m.AttachReceipt(e, `C:\Photos\receipt.png`, bytes.NewReader(testPNG))
+	if err != nil {
+		t.Fatalf("Error attaching receipt: %v", err)
+	}
+
+	if a.Filename != "receipt.png" || a.ContentType != "image/png" || a.Size != int64(len(testPNG)) {
+		t.Fatalf("Unexpected attachment %+v", a)
+	}
+
+	rc, err := m.OpenAttachment(a)
+	if err != nil {
+		t.Fatalf("Error opening attachment: %v", err)
+	}
+
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(data, testPNG) {
+		t.Fatalf("Expected attachment to contain the receipt (err=%v)", err)
+	}
+
+	_, err = m.AttachReceipt(e, "receipt.html", strings.NewReader("<html></html>"))
+	if errors.Cause(err) != models.ErrAttachmentType {
+		t.Fatalf("Expected ErrAttachmentType, got %v", err)
+	}
+
+	large := append(testPNG, make([]byte, models.MaxAttachmentSize)...)
+	_, err = m.AttachReceipt(e, "large.png", bytes.NewReader(large))
+	if errors.Cause(err) != models.ErrAttachmentTooLarge {
+		t.Fatalf("Expected ErrAttachmentTooLarge, got %v", err)
+	}
+
+	as, err := m.ExpenseAttachments(e)
+	if err != nil || len(as) != 1 {
+		t.Fatalf("Expected only the PNG to be attached, got %d (err=%v)", len(as), err)
+	}
+
+	// The receipt is removed along with the expense
+	err = m.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+	}
+
+	_, err = blobs.Get(a.Key)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected receipt to be deleted with expense, got %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "receipts", "*", "*"))
+	if len(files) != 0 {
+		t.Fatalf("Expected no receipts left behind, got %v", files)
+	}
+}
+
+func TestAttachReceiptWithoutBlobStore(t *testing.T) {
+	m, g, us := newTestGroup(t, 1)
+	e, err := m.NewExpense(g, models.Money{Amount: 500, Currency: models.GBP}, us[0].ID, mustCategory(t, m, g, "Groceries"), "Shopping", models.EqualSplit([]int64{us[0].ID}))
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	_, err = m.AttachReceipt(e, "receipt.png", bytes.NewReader(testPNG))
+	if errors.Cause(err) != models.ErrNoBlobStore {
+		t.Fatalf("Expected ErrNoBlobStore, got %v", err)
+	}
+}
+
+func TestDeleteGroupReceipts(t *testing.T) {
+	blobs, dir := newTestBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	// The store is needed to delete users
+	st := memstore.New()
+	m := models.NewManager(st, nil, nil, blobs)
+	g, err := m.NewGroup("Receipts group", "")
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	var us []*auth.User
+	var as []*models.Attachment
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		payer := &auth.User{Email: email, Name: "TEST"}
+		err = st.Insert(payer)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+		}
+
+		err = m.AddUserToGroup(g, payer, false)
+		if err != nil {
+			t.Fatalf("Error adding user to group: %v", err)
+		}
+		us = append(us, payer)
+
+		e, err := m.NewExpense(g, models.Money{Amount: 500, Currency: models.GBP}, payer.ID, mustCategory(t, m, g, "Groceries"), "Shopping", models.EqualSplit([]int64{payer.ID}))
+		if err != nil {
+			t.Fatalf("Error creating expense: %v", err)
+		}
+
+		a, err := m.AttachReceipt(e, "receipt.png", bytes.NewReader(testPNG))
+		if err != nil {
+			t.Fatalf("Error attaching receipt: %v", err)
+		}
+		as = append(as, a)
+	}
+
+	// Deleting a user deletes the expenses they paid
+	err = m.DeleteUser(us[0], st.Delete)
+	if err != nil {
+		t.Fatalf("Error deleting user: %v", err)
+	}
+
+	_, err = blobs.Get(as[0].Key)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected receipt to be deleted with payer, got %v", err)
+	}
+
+	err = m.DeleteGroup(g)
+	if err != nil {
+		t.Fatalf("Error deleting group: %v", err)
+	}
+
+	_, err = blobs.Get(as[1].Key)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected receipt to be deleted with group, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+// Balances maps the ID of a user to their net position within a group. A
+// positive balance means that the user is owed money by the rest of the
+// group, a negative balance means that the user owes money to the group.
+type Balances map[int64]Pence
+
+// Total returns the sum of all the balances. For a consistent group this
+// should always be zero, as every penny paid out is assigned to somebody.
+func (b Balances) Total() Pence {
+	var total Pence
+	for _, amount := range b {
+		total += amount
+	}
+	return total
+}
+
+// CalculateBalances works out the net position of each user given all of the
+// expenses and payments made within a group. The payer of an expense is
+// credited with the full amount, and each user the expense is assigned to is
+// debited their share. A payment credits the giver and debits the receiver.
+func CalculateBalances(es []*Expense, ps []*Payment) Balances {
+	b := make(Balances)
+	for _, e := range es {
+		b[e.PayerID] += e.Amount
+		for _, ea := range e.Assignments {
+			b[ea.UserID] -= ea.Amount
+		}
+	}
+
+	for _, p := range ps {
+		b[p.GiverID] += p.Amount
+		b[p.ReceiverID] -= p.Amount
+	}
+
+	return b
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestCalculateBalances(t *testing.T) {
+	es := []*Expense{
+		{
+			ID:      1,
+			Amount:  300,
+			PayerID: 1,
+			Assignments: []*ExpenseAssignment{
+				{UserID: 1, Amount: 100},
+				{UserID: 2, Amount: 100},
+				{UserID: 3, Amount: 100},
+			},
+		},
+		{
+			ID:      2,
+			Amount:  101,
+			PayerID: 2,
+			Assignments: []*ExpenseAssignment{
+				{UserID: 2, Amount: 51},
+				{UserID: 3, Amount: 50},
+			},
+		},
+	}
+
+	ps := []*Payment{
+		{GiverID: 3, ReceiverID: 1, Amount: 100},
+	}
+
+	b := CalculateBalances(es, ps)
+	expected := Balances{1: 100, 2: -50, 3: -50}
+	for id, amount := range expected {
+		if b[id] != amount {
+			t.Fatalf("Expected balance of %s for user %d, got %s", amount, id, b[id])
+			return
+		}
+	}
+
+	if b.Total() != 0 {
+		t.Fatalf("Expected balances to sum to zero, got %s", b.Total())
+		return
+	}
+}
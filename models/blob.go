@@ -0,0 +1,125 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrInvalidBlobKey is returned when a key would refer to a blob outside
+	// of the store
+	ErrInvalidBlobKey = errors.New("Invalid blob key")
+)
+
+// BlobStore stores the contents of files, such as receipts, by key. Keys are
+// paths separated by slashes, which are chosen by the Manager.
+type BlobStore interface {
+	// Put saves the contents read from the reader under the key,
+	// replacing anything already saved under it.
+	Put(key string, r io.Reader) error
+	// Get opens the contents saved under the key, which the caller must
+	// close. A not found error is returned if nothing is saved under it.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the contents saved under the key. It is not an error
+	// if nothing is saved under it.
+	Delete(key string) error
+}
+
+// DirBlobStore is a BlobStore that keeps each blob in a file within a
+// directory on the local filesystem.
+type DirBlobStore struct {
+	dir string
+}
+
+// NewDirBlobStore creates a blob store in the directory given, creating the
+// directory if it does not exist.
+func NewDirBlobStore(dir string) (*DirBlobStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Annotatef(err, "Could not create blob directory %s", dir)
+	}
+
+	return &DirBlobStore{dir: dir}, nil
+}
+
+// path returns the file that the blob with the key is kept in.
+func (s *DirBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.Annotatef(ErrInvalidBlobKey, "%q", key)
+	}
+
+	return filepath.Join(s.dir, clean), nil
+}
+
+// Put writes the blob to a temporary file first, so that a blob is never
+// left half written.
+func (s *DirBlobStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Annotate(err, "Could not create blob directory")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return errors.Annotate(err, "Could not create blob file")
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return errors.Annotatef(err, "Could not write blob %q", key)
+	}
+
+	return nil
+}
+
+func (s *DirBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFoundf("blob %q", key)
+	}
+
+	if err != nil {
+		return nil, errors.Annotatef(err, "Could not open blob %q", key)
+	}
+
+	return f, nil
+}
+
+func (s *DirBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Annotatef(err, "Could not delete blob %q", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BudgetWarningPercent is the percentage of a category's budget that must be
+// spent before the budget is flagged.
+const BudgetWarningPercent = 80
+
+// BudgetLevel is how much of its budget a category has spent in a month.
+type BudgetLevel int
+
+const (
+	// BudgetOK categories have spent less than BudgetWarningPercent of
+	// their budget.
+	BudgetOK BudgetLevel = iota
+	// BudgetWarning categories have spent at least BudgetWarningPercent of
+	// their budget, but not more than the budget.
+	BudgetWarning
+	// BudgetOver categories have spent more than their budget.
+	BudgetOver
+)
+
+var budgetLevelStrings = map[BudgetLevel]string{
+	BudgetOK:      "ok",
+	BudgetWarning: "warning",
+	BudgetOver:    "over",
+}
+
+func (l BudgetLevel) String() string {
+	s, ok := budgetLevelStrings[l]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (l BudgetLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// budgetLevel returns the level of a category that has spent the amount
+// given against its budget.
+func budgetLevel(spent, budget Pence) BudgetLevel {
+	switch {
+	case spent > budget:
+		return BudgetOver
+	case spent*100 >= budget*BudgetWarningPercent:
+		return BudgetWarning
+	}
+	return BudgetOK
+}
+
+// BudgetStatus compares the amount spent in a category during a month with
+// the category's budget. Amounts are in the currency of the group. Month is
+// midnight UTC on the first day of the month.
+type BudgetStatus struct {
+	CategoryID int64       `json:"categoryId"`
+	Month      time.Time   `json:"month"`
+	Budget     Pence       `json:"budget"`
+	Spent      Pence       `json:"spent"`
+	Level      BudgetLevel `json:"level"`
+}
+
+func newBudgetStatus(c *Category, month time.Time, spent Pence) *BudgetStatus {
+	return &BudgetStatus{
+		CategoryID: c.ID,
+		Month:      month,
+		Budget:     c.Budget,
+		Spent:      spent,
+		Level:      budgetLevel(spent, c.Budget),
+	}
+}
+
+// Notifier sends notifications to the members of a group.
+type Notifier interface {
+	// BudgetAlert tells a member that a category of their group has
+	// reached a higher BudgetLevel.
+	BudgetAlert(*Member, *Group, *Category, *BudgetStatus) error
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) BudgetAlert(*Member, *Group, *Category, *BudgetStatus) error {
+	return nil
+}
+
+// startOfMonth returns midnight UTC on the first day of the month containing
+// the time given.
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
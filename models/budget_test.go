@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestBudgetLevel(t *testing.T) {
+	tests := []struct {
+		spent, budget Pence
+		expected      BudgetLevel
+	}{
+		{0, 40000, BudgetOK},
+		{31999, 40000, BudgetOK},
+		{32000, 40000, BudgetWarning},
+		{40000, 40000, BudgetWarning},
+		{40001, 40000, BudgetOver},
+	}
+
+	for _, test := range tests {
+		if l := budgetLevel(test.spent, test.budget); l != test.expected {
+			t.Fatalf("Expected %s spending %s of %s, got %s", test.expected, test.spent, test.budget, l)
+			return
+		}
+	}
+}
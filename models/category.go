@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidCategoryName is returned when a category is saved with an
+	// empty or overly long name
+	ErrInvalidCategoryName = errors.New("The name of a category must be between 1 and 64 characters")
+
+	// ErrInvalidColour is returned when a category is saved with a colour
+	// that is not of the form #rrggbb
+	ErrInvalidColour = errors.New("The colour of a category must be of the form #rrggbb")
+
+	// ErrInvalidIcon is returned when the name of a category's icon is too
+	// long
+	ErrInvalidIcon = errors.New("The icon of a category must be at most 64 characters")
+
+	// ErrDuplicateCategory is returned when a group already has a category
+	// with the same name
+	ErrDuplicateCategory = errors.New("The group already has a category with that name")
+
+	// ErrCategoryNotInGroup is returned when an expense is given a category
+	// that belongs to a different group
+	ErrCategoryNotInGroup = errors.New("The category does not belong to the group")
+
+	// ErrCategoryInUse is returned when deleting a category that expenses or
+	// recurring expenses still use
+	ErrCategoryInUse = errors.New("The category is used by expenses and cannot be deleted")
+)
+
+// DefaultColour is the colour given to categories created without one.
+const DefaultColour = "#9e9e9e"
+
+const maxCategoryLength = 64
+
+var colourRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Category is used to group the expenses of a group. Each group has its own
+// set of categories. The colour is a hex colour of the form #rrggbb and the
+// icon is the name of an icon for the frontend to display. The budget is the
+// amount the group plans to spend in the category each month, in the
+// currency of the group, or 0 if the category has no budget.
+type Category struct {
+	ID      int64  `db:"id" json:"id"`
+	GroupID int64  `db:"group_id" json:"groupId"`
+	Name    string `db:"name" json:"name"`
+	Colour  string `db:"colour" json:"colour"`
+	Icon    string `db:"icon" json:"icon"`
+	Budget  Pence  `db:"budget" json:"budget"`
+}
+
+// DefaultCategories returns the categories that every new group starts with.
+// These were the fixed categories before groups could choose their own.
+func DefaultCategories() []Category {
+	return []Category{
+		{Name: "Groceries", Colour: "#4caf50", Icon: "shopping-cart"},
+		{Name: "Alcohol", Colour: "#9c27b0", Icon: "glass"},
+		{Name: "Drugs", Colour: "#f44336", Icon: "medkit"},
+		{Name: "Household Items", Colour: "#795548", Icon: "home"},
+		{Name: "Bills", Colour: "#2196f3", Icon: "file-text"},
+		{Name: "Presents", Colour: "#e91e63", Icon: "gift"},
+		{Name: "Tickets", Colour: "#ff9800", Icon: "ticket"},
+		{Name: "Misc", Colour: DefaultColour, Icon: "tag"},
+	}
+}
+
+// String returns the name of the category.
+func (c Category) String() string {
+	return c.Name
+}
+
+// normalise trims the name and icon, and fills in the default colour.
+func (c *Category) normalise() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Icon = strings.TrimSpace(c.Icon)
+	if c.Colour == "" {
+		c.Colour = DefaultColour
+	}
+}
+
+func (c Category) validate() error {
+	if c.Name == "" || len(c.Name) > maxCategoryLength {
+		return ErrInvalidCategoryName
+	}
+
+	if !colourRegexp.MatchString(c.Colour) {
+		return ErrInvalidColour
+	}
+
+	if len(c.Icon) > maxCategoryLength {
+		return ErrInvalidIcon
+	}
+
+	if c.Budget < 0 {
+		return ErrNegativePence
+	}
+
+	if c.GroupID <= 0 {
+		return errors.New("GroupId must be positive")
+	}
+
+	return nil
+}
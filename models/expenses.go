@@ -4,10 +4,6 @@ import (
 	"github.com/juju/errors"
 
 	"database/sql/driver"
-	"fmt"
-	"math/rand"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -25,21 +21,49 @@ var (
 	ErrStructNotSaved = errors.New("Invalid operation: struct must be saved first")
 
 	ErrMustAssignToUsers = errors.New("There must be a positive number of users to assign an expense")
+
+	// ErrNonPositiveWeight is returned when an expense is assigned with a
+	// share that does not have a positive weight
+	ErrNonPositiveWeight = errors.New("The weight of a share must be positive")
+
+	// ErrWeightTooLarge is returned when an expense is assigned with a share
+	// whose weight is more than MaxShareWeight
+	ErrWeightTooLarge = errors.New("The weight of a share must be at most 1000000")
+
+	// ErrNegativeShareAmount is returned when an exact share of an expense
+	// is negative
+	ErrNegativeShareAmount = errors.New("The amount of a share must not be negative")
+
+	// ErrSplitAmountMismatch is returned when the exact amounts of an
+	// expense's shares do not add up to the amount of the expense
+	ErrSplitAmountMismatch = errors.New("The amounts of the shares must add up to the amount of the expense")
+
+	// ErrSplitPercentageTotal is returned when the percentages of an
+	// expense's shares do not add up to 100%
+	ErrSplitPercentageTotal = errors.New("The percentages of the shares must add up to 100%")
+
+	// ErrUnknownSplitMode is returned when an expense is assigned using a
+	// split mode that does not exist
+	ErrUnknownSplitMode = errors.New("Unknown split mode")
+
+	// ErrUnknownRemainder is returned when an expense is assigned using a
+	// remainder policy that does not exist
+	ErrUnknownRemainder = errors.New("Unknown remainder policy")
 )
 
 // Pence is an amount of money used in Payments & Expenses. There are 100 Pence
-// in a Pound (Sterling)
+// in a Pound (Sterling). When an expense or payment is in another currency,
+// Pence is used for the minor units of that currency.
 type Pence int64
 
 // String formats pence into a human readable string
 func (p Pence) String() string {
-	var negativeString = ""
-	if p < 0 {
-		p = -p
-		negativeString = "-"
-	}
+	return Money{int64(p), GBP}.String()
+}
 
-	return fmt.Sprintf("%s£%01d.%02d", negativeString, p/100, p%100)
+// Money converts the pence into an amount of money in GBP.
+func (p Pence) Money() Money {
+	return Money{int64(p), GBP}
 }
 
 func (p *Pence) Scan(src interface{}) error {
@@ -70,204 +94,38 @@ func (p Pence) Validate() error {
 
 // PenceFromString parses a string and returns the amount of pence.
 func PenceFromString(s string) (Pence, error) {
-	//Quick check to see if there are any non-numerical digits
-	if strings.ToUpper(s) != strings.ToLower(s) {
-		return Pence(0), ErrInvalidMoneyStr
-	}
-
-	//Ensure there is, at most, 1 decimal point.
-	nDec := strings.Count(s, ".")
-	if nDec > 1 {
-		return Pence(0), ErrInvalidMoneyStr
-	} else if nDec == 0 {
-		return poundsStrToPence(s)
-	}
-
-	pIndex := strings.Index(s, ".")
-
-	if pIndex == -1 {
-		//No decimal place
-		return penceStrToPence(s)
-	}
-
-	if pIndex == 0 {
-		//decimal point at beginning
-		return penceStrToPence(strings.TrimLeft(s, "."))
-	}
-
-	strs := strings.Split(s, ".")
-	pence, err := penceStrToPence(strs[1])
-	if err != nil {
-		return Pence(0), err
-	}
-	pounds, err := poundsStrToPence(strs[0])
-	if err != nil {
-		return Pence(0), err
-	}
-	return pence + pounds, nil
-}
-
-// penceStrToPence converts a string containing an amount of pence to the value
-// in pence.
-func penceStrToPence(s string) (Pence, error) {
-	s = strings.TrimRight(s, "0")
-	if len(s) > 2 {
-		return Pence(0), ErrInvalidMoneyStr
-	}
-	if len(s) == 0 {
-		return Pence(0), nil
-	}
-
-	if len(s) == 1 {
-		s += "0"
-	}
-	//Now everything should have correct number of Zeros
-	s = strings.TrimLeft(s, ".")
-	ret, err := strconv.Atoi(s)
-	if err != nil {
-		return Pence(0), err
-	}
-
-	return Pence(ret), nil
-}
-
-// poundsStrToPence returns the number of pence in the pounds passed as an
-// argument
-func poundsStrToPence(s string) (Pence, error) {
-	if s == "" {
-		return Pence(0), nil
-	}
-
-	ret, err := strconv.Atoi(s)
-	if err != nil {
-		return Pence(0), err
-	}
-
-	return Pence(ret * 100), nil
-}
-
-// Category is used to group expenses
-type Category int
-
-const (
-	// CategoryGroceries indicates an expense spend on groceries
-	// e.g. Boursin
-	CategoryGroceries Category = iota
-	// CategoryAlcohol indicates an expense spend on alcohol e.g. K Cider
-	CategoryAlcohol
-	// CategoryDrugs indicates an expense spend on medicine e.g cough
-	// medicine
-	CategoryDrugs
-	// CategoryHouseholdItems indicates an expense spend on household items
-	// e.g. An iron
-	CategoryHouseholdItems
-	// CategoryBills indicates an expense spend to on bill e.g. Netflix
-	CategoryBills
-	// CategoryPresents indicates an expense spent on presents e.g. singing
-	// lessons
-	CategoryPresents
-	// CategoryTickets indicates an expense spent on tickets e.g. Balls Deep
-	CategoryTickets
-	// CategoryMisc is used for expenses that do not fit into any of the
-	// other categories
-	CategoryMisc
-	// CategoryUnknown is not a category that should be used and indicates
-	// a logic error.
-	CategoryUnknown //Should always be last
-)
-
-func (c Category) String() string {
-	switch c {
-	case CategoryGroceries:
-		return "Groceries"
-	case CategoryAlcohol:
-		return "Alcohol"
-	case CategoryDrugs:
-		return "Drugs"
-	case CategoryHouseholdItems:
-		return "Household Items"
-	case CategoryBills:
-		return "Bills"
-	case CategoryPresents:
-		return "Presents"
-	case CategoryMisc:
-		return "Misc"
-	case CategoryTickets:
-		return "Tickets"
-	default:
-		return "Unknown Category"
-	}
-}
-
-func (c Category) Value() (driver.Value, error) {
-	return strings.ToLower(c.String()), nil
-}
-
-func (c *Category) Scan(src interface{}) error {
-	if src == nil {
-		return errors.New("nil value cannot be assigned to Category")
-	}
-
-	v := src.([]uint8)
-	s := ""
-
-	for _, n := range v {
-		s += string(int(n))
-	}
-
-	*c = StringToCategory(s)
-	return nil
+	n, err := parseMinorUnits(s, 2)
+	return Pence(n), err
 }
 
-// Validate ensures the Category is valid
-func (c Category) Validate() error {
-	if c == CategoryUnknown {
-		return fmt.Errorf("Invalid category: %s.", c)
-	}
-	return nil
-}
-
-// AllCategories returns a slice containing all the valid categories
-func AllCategories() []Category {
-	var ret []Category
-	for c := CategoryGroceries; c < CategoryUnknown; c++ {
-		ret = append(ret, c)
-	}
-	return ret
-}
-
-// StringToCategory converts a string to the appropriate category.
-// This performs a lower case compare and strips any surrounding double quote
-// characters.
-func StringToCategory(s string) Category {
-	// Trim any quotes
-	ret, ok := strToCategory[strings.ToLower(strings.Trim(s, `"`))]
-	if !ok {
-		return CategoryUnknown
-	}
-	return ret
-}
-
-var strToCategory = make(map[string]Category)
-
-// Expense represents an expense made that is to be shared with the group
+// Expense represents an expense made that is to be shared with the group. The
+// amount, and the amounts assigned, are in the minor units of the currency.
+// The exchange rate is the rate used to convert the expense into the currency
+// of the group, recorded when the expense is saved. The import ID is the ID
+// given to the transaction by the bank when the expense was imported from a
+// statement, and is otherwise empty.
 type Expense struct {
-	ID          int64                `db:"id" json:"id"`
-	Amount      Pence                `db:"amount" json:"amount"`
-	PayerID     int64                `db:"payer_id" json:"payerId"`
-	GroupID     int64                `db:"group_id" json:"groupId"`
-	Category    Category             `db:"category" json:"category"`
-	Description string               `db:"description" json:"category"`
-	CreatedAt   time.Time            `db:"created_at" json:"category"`
-	Assignments []*ExpenseAssignment `db:"-" json:"assignments"`
+	ID           int64                `db:"id" json:"id"`
+	Amount       Pence                `db:"amount" json:"amount"`
+	Currency     string               `db:"currency" json:"currency"`
+	ExchangeRate float64              `db:"exchange_rate" json:"exchangeRate"`
+	PayerID      int64                `db:"payer_id" json:"payerId"`
+	GroupID      int64                `db:"group_id" json:"groupId"`
+	CategoryID   int64                `db:"category_id" json:"categoryId"`
+	Description  string               `db:"description" json:"description"`
+	CreatedAt    time.Time            `db:"created_at" json:"createdAt"`
+	ImportID     string               `db:"import_id" json:"importId,omitempty"`
+	Assignments  []*ExpenseAssignment `db:"-" json:"assignments"`
 }
 
 // ExpenseAssignment represents the amount of money assigned to each user when
-// an expense is made.
+// an expense is made. Rounding is the part of the amount that came from
+// pennies left over when the expense did not divide exactly.
 type ExpenseAssignment struct {
 	ID        int64 `db:"id" json:"id"`
 	UserID    int64 `db:"user_id" json:"userId"`
 	Amount    Pence `db:"amount" json:"amount"`
+	Rounding  Pence `db:"rounding" json:"rounding"`
 	ExpenseID int64 `db:"expense_id" json:"expenseId"`
 	GroupID   int64 `db:"group_id" json:"groupId"`
 }
@@ -292,25 +150,32 @@ func (e Expense) validate() error {
 		return err
 	}
 
-	err = e.Category.Validate()
-	if err != nil {
-		return err
+	if e.Currency != "" {
+		if _, err = CurrencyByCode(e.Currency); err != nil {
+			return err
+		}
 	}
 
-	if e.PayerID <= 0 || e.GroupID <= 0 {
-		return errors.New("PayerId and GroupId must be positive")
+	if e.PayerID <= 0 || e.GroupID <= 0 || e.CategoryID <= 0 {
+		return errors.New("PayerId, GroupId and CategoryId must be positive")
 	}
 
 	return nil
 }
 
-// Assign assigns an expense to the users given. If the amount is not equally
-// divisable by the number of users the expense applies to, then the
-// remaining amount is assigned at random.
-func (e *Expense) Assign(userIds []int64) ([]*ExpenseAssignment, error) {
-	if len(userIds) == 0 {
-		return nil, ErrMustAssignToUsers
+// Money returns the amount of the expense along with its currency.
+func (e Expense) Money() Money {
+	if e.Currency == "" {
+		return Money{int64(e.Amount), DefaultCurrency}
 	}
+	return Money{int64(e.Amount), e.Currency}
+}
+
+// Assign assigns an expense to the users given in the split. The total
+// assigned is always exactly equal to the amount of the expense, otherwise an
+// error is returned. The history is the rounding previously assigned to each
+// user in the group, which is only needed for RemainderRoundRobin.
+func (e *Expense) Assign(split Split, history Rounding) ([]*ExpenseAssignment, error) {
 	err := e.validate()
 	if err != nil {
 		return nil, err
@@ -320,29 +185,20 @@ func (e *Expense) Assign(userIds []int64) ([]*ExpenseAssignment, error) {
 		return nil, errors.Trace(ErrStructNotSaved)
 	}
 
-	numUsers := int64(len(userIds))
-	average := e.Amount / Pence(numUsers)
-	remainder := int64(e.Amount) % numUsers
-	randIndexes := rand.Perm(int(numUsers))
+	amounts, rounding, err := split.amounts(e.Amount, e.PayerID, history)
+	if err != nil {
+		return nil, err
+	}
 
 	var ret []*ExpenseAssignment
-	for i := int64(0); i < numUsers; i++ {
-		amount := average
-		if i < remainder {
-			amount++
-		}
+	for i, s := range split.Shares {
 		ret = append(ret, &ExpenseAssignment{
-			UserID:    int64(userIds[randIndexes[i]]),
-			Amount:    amount,
+			UserID:    s.UserID,
+			Amount:    amounts[i],
+			Rounding:  rounding[i],
 			ExpenseID: e.ID,
 			GroupID:   e.GroupID,
 		})
 	}
 	return ret, nil
 }
-
-func init() {
-	for _, c := range AllCategories() {
-		strToCategory[strings.ToLower(c.String())] = c
-	}
-}
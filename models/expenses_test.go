@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAssignSplit(t *testing.T) {
+	tests := []struct {
+		amount   Pence
+		split    Split
+		expected map[int64]Pence
+	}{
+		{
+			amount:   120000,
+			split:    Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: 2}, {UserID: 2, Weight: 1}, {UserID: 3, Weight: 1}}},
+			expected: map[int64]Pence{1: 60000, 2: 30000, 3: 30000},
+		},
+		{
+			amount:   100,
+			split:    Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: 2}, {UserID: 2, Weight: 1}}},
+			expected: map[int64]Pence{1: 67, 2: 33},
+		},
+		{
+			amount:   100,
+			split:    EqualSplit([]int64{1, 2, 3}),
+			expected: map[int64]Pence{1: 34, 2: 33, 3: 33},
+		},
+		{
+			amount:   1,
+			split:    Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: 5}, {UserID: 2, Weight: 5}}},
+			expected: map[int64]Pence{1: 1, 2: 0},
+		},
+		{
+			amount:   1000,
+			split:    Split{Mode: SplitExact, Shares: []Share{{UserID: 1, Amount: 999}, {UserID: 2, Amount: 1}}},
+			expected: map[int64]Pence{1: 999, 2: 1},
+		},
+		{
+			amount:   1000,
+			split:    Split{Mode: SplitPercentage, Shares: []Share{{UserID: 1, Weight: 7500}, {UserID: 2, Weight: 2500}}},
+			expected: map[int64]Pence{1: 750, 2: 250},
+		},
+		{
+			amount:   100,
+			split:    Split{Mode: SplitPercentage, Shares: []Share{{UserID: 1, Weight: 3333}, {UserID: 2, Weight: 3333}, {UserID: 3, Weight: 3334}}},
+			expected: map[int64]Pence{1: 33, 2: 33, 3: 34},
+		},
+		{
+			// User 3 is owed the most of a penny, but user 1 has the lowest ID
+			amount:   100,
+			split:    Split{Mode: SplitPercentage, Shares: []Share{{UserID: 3, Weight: 3334}, {UserID: 2, Weight: 3333}, {UserID: 1, Weight: 3333}}, Remainder: RemainderLowestUserID},
+			expected: map[int64]Pence{1: 34, 2: 33, 3: 33},
+		},
+		{
+			// The amount times the weight does not fit in an int64
+			amount:   9000000000000000000,
+			split:    Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: MaxShareWeight}, {UserID: 2, Weight: MaxShareWeight / 2}}},
+			expected: map[int64]Pence{1: 6000000000000000000, 2: 3000000000000000000},
+		},
+	}
+
+	for _, test := range tests {
+		e := &Expense{ID: 1, GroupID: 1, PayerID: 1, CategoryID: 1, Amount: test.amount}
+		eas, err := e.Assign(test.split, nil)
+		if err != nil {
+			t.Fatalf("Error assigning expense: %v", err)
+			return
+		}
+
+		var total Pence
+		for _, ea := range eas {
+			total += ea.Amount
+			if ea.Amount != test.expected[ea.UserID] {
+				t.Fatalf("User %d assigned %s, expected %s", ea.UserID, ea.Amount, test.expected[ea.UserID])
+				return
+			}
+		}
+
+		if total != test.amount {
+			t.Fatalf("Assignments total %s, expected %s", total, test.amount)
+			return
+		}
+	}
+}
+
+func TestAssignRemainderPayer(t *testing.T) {
+	e := &Expense{ID: 1, GroupID: 1, PayerID: 3, CategoryID: 1, Amount: 302}
+	eas, err := e.Assign(Split{Mode: SplitEqual, Shares: EqualShares([]int64{1, 2, 3}), Remainder: RemainderPayer}, nil)
+	if err != nil {
+		t.Fatalf("Error assigning expense: %v", err)
+		return
+	}
+
+	expected := map[int64]Pence{1: 100, 2: 100, 3: 102}
+	for _, ea := range eas {
+		if ea.Amount != expected[ea.UserID] {
+			t.Fatalf("User %d assigned %s, expected %s", ea.UserID, ea.Amount, expected[ea.UserID])
+			return
+		}
+	}
+}
+
+func TestAssignRemainderRoundRobin(t *testing.T) {
+	split := Split{Mode: SplitEqual, Shares: EqualShares([]int64{1, 2, 3}), Remainder: RemainderRoundRobin}
+	history := make(Rounding)
+
+	// Assigning the same expense three times should give each user a single
+	// left over penny.
+	for i := 0; i < 3; i++ {
+		e := &Expense{ID: int64(i + 1), GroupID: 1, PayerID: 1, CategoryID: 1, Amount: 100}
+		eas, err := e.Assign(split, history)
+		if err != nil {
+			t.Fatalf("Error assigning expense: %v", err)
+			return
+		}
+
+		var total Pence
+		for _, ea := range eas {
+			total += ea.Amount
+			history[ea.UserID] += ea.Rounding
+		}
+
+		if total != e.Amount {
+			t.Fatalf("Assignments total %s, expected %s", total, e.Amount)
+			return
+		}
+	}
+
+	for id := int64(1); id <= 3; id++ {
+		if history[id] != 1 {
+			t.Fatalf("User %d assigned %s left over pennies, expected 1p", id, history[id])
+			return
+		}
+	}
+}
+
+func TestAssignInvalidSplit(t *testing.T) {
+	tests := []struct {
+		split    Split
+		expected error
+	}{
+		{split: Split{Mode: SplitEqual, Shares: nil}, expected: ErrMustAssignToUsers},
+		{split: Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1}}}, expected: ErrNonPositiveWeight},
+		{split: Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: 1}, {UserID: 2, Weight: -1}}}, expected: ErrNonPositiveWeight},
+		{split: Split{Mode: SplitWeighted, Shares: []Share{{UserID: 1, Weight: 1}, {UserID: 2, Weight: MaxShareWeight + 1}}}, expected: ErrWeightTooLarge},
+		{split: Split{Mode: SplitExact, Shares: []Share{{UserID: 1, Amount: 50}, {UserID: 2, Amount: 49}}}, expected: ErrSplitAmountMismatch},
+		{split: Split{Mode: SplitExact, Shares: []Share{{UserID: 1, Amount: 101}, {UserID: 2, Amount: -1}}}, expected: ErrNegativeShareAmount},
+		{split: Split{Mode: SplitPercentage, Shares: []Share{{UserID: 1, Weight: 5000}, {UserID: 2, Weight: 4000}}}, expected: ErrSplitPercentageTotal},
+		{split: Split{Mode: SplitMode(100), Shares: []Share{{UserID: 1, Weight: 1}}}, expected: ErrUnknownSplitMode},
+	}
+
+	for _, test := range tests {
+		e := &Expense{ID: 1, GroupID: 1, PayerID: 1, CategoryID: 1, Amount: 100}
+		if _, err := e.Assign(test.split, nil); err != test.expected {
+			t.Fatalf("Expected %v, got %v", test.expected, err)
+			return
+		}
+	}
+
+	e := &Expense{ID: 1, GroupID: 1, PayerID: 1, CategoryID: 1, Amount: 100}
+	if _, err := e.Assign(EqualSplit([]int64{1, 1}), nil); err == nil {
+		t.Fatalf("Expected error assigning two shares to the same user")
+		return
+	}
+}
+
+func TestUnmarshalRemainder(t *testing.T) {
+	tests := map[string]Remainder{
+		`"largest_fraction"`: RemainderLargestFraction,
+		`"lowest_user_id"`:   RemainderLowestUserID,
+		`"Round_Robin"`:      RemainderRoundRobin,
+	}
+
+	for s, expected := range tests {
+		var r Remainder
+		err := json.Unmarshal([]byte(s), &r)
+		if err != nil || r != expected {
+			t.Fatalf("Expected %s to be %s, got %s, %v", s, expected, r, err)
+			return
+		}
+	}
+}
@@ -8,14 +8,39 @@ import (
 
 var (
 	ErrAlreadySaved = errors.New("Cannot insert as model as already saved")
+	// ErrNotMember is returned when a user involved in an expense or payment
+	// is not a member of the group
+	ErrNotMember = errors.New("User is not a member of the group")
+	// ErrPaymentToSelf is returned when the giver and receiver of a payment
+	// are the same user
+	ErrPaymentToSelf = errors.New("A payment must be made to another user")
+	// ErrOutstandingBalance is returned when a user tries to leave a group
+	// that they owe money to, or are owed money by
+	ErrOutstandingBalance = errors.New("User must settle up before leaving the group")
+	// ErrCurrencyInUse is returned when changing the currency of a group
+	// that already has expenses or payments
+	ErrCurrencyInUse = errors.New("The currency of a group cannot be changed once it has expenses or payments")
 )
 
 // Group represents a group of users in which the expenses are shared. An
 // example of this would be housemates sharing the expenses incurred while
-// living together, such as shared meals and communal home items.
+// living together, such as shared meals and communal home items. Balances
+// within the group are calculated in the group's currency. The currency must
+// not be changed once expenses have been recorded, as the exchange rates
+// recorded are into the original currency.
 type Group struct {
-	ID   int64  `db:"id" json:"id"`
-	Name string `db:"name" json:"name"`
+	ID       int64  `db:"id" json:"id"`
+	Name     string `db:"name" json:"name"`
+	Currency string `db:"currency" json:"currency"`
+}
+
+// BaseCurrency returns the currency that balances in the group are
+// calculated in.
+func (g Group) BaseCurrency() string {
+	if g.Currency == "" {
+		return DefaultCurrency
+	}
+	return g.Currency
 }
 
 // UserGroupMap represents the database structure mapping users and groups.
@@ -29,14 +54,48 @@ type UserGroupMap struct {
 	Admin   bool  `db:"admin" json:"admin"`
 }
 
+// Member is a user as seen by the other members of a group they belong to.
+// Admin is whether the user is an admin of the group.
+type Member struct {
+	ID    int64  `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Email string `db:"email" json:"email"`
+	Admin bool   `db:"admin" json:"admin"`
+}
+
 // Payment represent a transfer of money from one person to another in the
 // group. This is typically performed when one person is at a deficit overall
-// to the group and another has paid a surplus with expenses.
+// to the group and another has paid a surplus with expenses. The amount is in
+// the minor units of the currency. The exchange rate is the rate used to
+// convert the payment into the currency of the group.
 type Payment struct {
-	ID         int64     `db:"id" json:"id"`
-	GroupID    int64     `db:"group_id" json:"groupId"`
-	Amount     Pence     `db:"amount" json:"amount"`
-	GiverID    int64     `db:"giver_id" json:"giverId"`
-	ReceiverID int64     `db:"receiver_id" json:"receieverId"`
-	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	ID           int64     `db:"id" json:"id"`
+	GroupID      int64     `db:"group_id" json:"groupId"`
+	Amount       Pence     `db:"amount" json:"amount"`
+	Currency     string    `db:"currency" json:"currency"`
+	ExchangeRate float64   `db:"exchange_rate" json:"exchangeRate"`
+	GiverID      int64     `db:"giver_id" json:"giverId"`
+	ReceiverID   int64     `db:"receiver_id" json:"receiverId"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Money returns the amount of the payment along with its currency.
+func (p Payment) Money() Money {
+	if p.Currency == "" {
+		return Money{int64(p.Amount), DefaultCurrency}
+	}
+	return Money{int64(p.Amount), p.Currency}
+}
+
+func (p Payment) validate() error {
+	err := p.Money().Validate()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if p.GiverID == p.ReceiverID {
+		return ErrPaymentToSelf
+	}
+
+	return nil
 }
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// LedgerDateFormat is the format of the dates in an exported ledger.
+const LedgerDateFormat = "2006-01-02"
+
+const (
+	ledgerExpense = "expense"
+	ledgerPayment = "payment"
+)
+
+// ledgerColumns are the columns of an exported ledger that come before the
+// share of each user.
+var ledgerColumns = []string{"Date", "Type", "Description", "Category", "Payer", "Amount", "Currency"}
+
+// ledgerUsers returns the IDs of the users that have a column in the ledger,
+// along with the name to use for each. The current members come first, in
+// the order they are stored, followed by anyone who has since left the group.
+func ledgerUsers(members []*Member, es []*Expense, ps []*Payment) ([]int64, map[int64]string) {
+	var ids []int64
+	names := make(map[int64]string)
+	for _, mem := range members {
+		ids = append(ids, mem.ID)
+		names[mem.ID] = mem.Name
+	}
+
+	var former []int64
+	add := func(id int64) {
+		if _, ok := names[id]; !ok {
+			former = append(former, id)
+			names[id] = fmt.Sprintf("User %d", id)
+		}
+	}
+
+	for _, e := range es {
+		add(e.PayerID)
+		for _, ea := range e.Assignments {
+			add(ea.UserID)
+		}
+	}
+
+	for _, p := range ps {
+		add(p.GiverID)
+		add(p.ReceiverID)
+	}
+
+	sort.Slice(former, func(i, j int) bool { return former[i] < former[j] })
+	return append(ids, former...), names
+}
+
+// ledgerShares formats the amount assigned to each user in the order given,
+// in the currency given. Users without a share are given zero.
+func ledgerShares(ids []int64, amounts map[int64]Pence, currency string) []string {
+	shares := make([]string, len(ids))
+	for i, id := range ids {
+		shares[i] = Money{int64(amounts[id]), currency}.Decimal()
+	}
+	return shares
+}
+
+// ExportLedger writes the full ledger of the group to w as CSV. There is a row
+// for every expense and payment, oldest first, with the columns given by
+// ledgerColumns followed by the share of each user. The shares of an expense
+// are the amounts assigned to each user, while the receiver of a payment has
+// the whole payment as their share, so the shares of every row add up to its
+// amount. Amounts are in the major units of the row's currency. Rows are
+// written as they are formatted rather than being buffered.
+func (m Manager) ExportLedger(g *Group, w io.Writer) error {
+	members, err := m.store.MembersByGroup(g)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve group members")
+	}
+
+	cs, err := m.store.CategoriesByGroup(g)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve group categories")
+	}
+
+	es, err := m.store.ExpensesByGroup(g)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve group expenses")
+	}
+
+	ps, err := m.store.PaymentsByGroup(g)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve group payments")
+	}
+
+	categories := make(map[int64]*Category)
+	for _, c := range cs {
+		categories[c.ID] = c
+	}
+
+	ids, names := ledgerUsers(members, es, ps)
+
+	cw := csv.NewWriter(w)
+	header := append([]string{}, ledgerColumns...)
+	for _, id := range ids {
+		header = append(header, names[id])
+	}
+
+	err = cw.Write(header)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// Both are ordered by creation, so they are merged with expenses first
+	// when they were created at the same time.
+	for len(es) > 0 || len(ps) > 0 {
+		var record []string
+		if len(ps) == 0 || (len(es) > 0 && !ps[0].CreatedAt.Before(es[0].CreatedAt)) {
+			e := es[0]
+			es = es[1:]
+
+			amounts := make(map[int64]Pence)
+			for _, ea := range e.Assignments {
+				amounts[ea.UserID] += ea.Amount
+			}
+
+			var category string
+			if c, ok := categories[e.CategoryID]; ok {
+				category = c.String()
+			}
+
+			money := e.Money()
+			record = append([]string{
+				e.CreatedAt.UTC().Format(LedgerDateFormat),
+				ledgerExpense,
+				e.Description,
+				category,
+				names[e.PayerID],
+				money.Decimal(),
+				money.Currency,
+			}, ledgerShares(ids, amounts, money.Currency)...)
+		} else {
+			p := ps[0]
+			ps = ps[1:]
+
+			money := p.Money()
+			amounts := map[int64]Pence{p.ReceiverID: p.Amount}
+			record = append([]string{
+				p.CreatedAt.UTC().Format(LedgerDateFormat),
+				ledgerPayment,
+				"Payment to " + names[p.ReceiverID],
+				"",
+				names[p.GiverID],
+				money.Decimal(),
+				money.Currency,
+			}, ledgerShares(ids, amounts, money.Currency)...)
+		}
+
+		err = cw.Write(record)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	cw.Flush()
+	return errors.Trace(cw.Error())
+}
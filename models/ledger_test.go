@@ -0,0 +1,61 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestExportLedger(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+
+	_, err := m.NewExpense(g, models.Money{Amount: 1001, Currency: models.GBP}, us[0].ID, mustCategory(t, m, g, "Bills"), "Gas, electric", split)
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	_, err = m.InsertPayment(g, us[1].ID, us[0].ID, models.Money{Amount: 500, Currency: models.GBP})
+	if err != nil {
+		t.Fatalf("Error creating payment: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = m.ExportLedger(g, &buf)
+	if err != nil {
+		t.Fatalf("Error exporting ledger: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading exported ledger: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d records", len(records))
+	}
+
+	header := []string{"Date", "Type", "Description", "Category", "Payer", "Amount", "Currency", us[0].Name, us[1].Name}
+	if !reflect.DeepEqual(records[0], header) {
+		t.Fatalf("Expected header %v, got %v", header, records[0])
+	}
+
+	expense := records[1][1:]
+	shares := expense[len(expense)-2:]
+	if expense[0] != "expense" || expense[1] != "Gas, electric" || expense[2] != "Bills" || expense[4] != "10.01" || expense[5] != "GBP" {
+		t.Fatalf("Unexpected expense row %v", records[1])
+	}
+
+	if !(shares[0] == "5.01" && shares[1] == "5.00") && !(shares[0] == "5.00" && shares[1] == "5.01") {
+		t.Fatalf("Expected shares of 5.00 and 5.01, got %v", shares)
+	}
+
+	payment := records[2][1:]
+	expected := []string{"payment", "Payment to " + us[0].Name, "", us[1].Name, "5.00", "GBP", "5.00", "0.00"}
+	if !reflect.DeepEqual(payment, expected) {
+		t.Fatalf("Expected payment row %v, got %v", expected, payment)
+	}
+}
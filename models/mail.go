@@ -0,0 +1,80 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer is a Mailer that sends emails through an SMTP server.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer that sends emails from the address given
+// through the SMTP server at addr, which is of the form host:port. If the
+// user is empty then the server is used without authenticating.
+func NewSMTPMailer(addr, from, user, pw string) *SMTPMailer {
+	var auth smtp.Auth
+	if user != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", user, pw, host)
+	}
+
+	return &SMTPMailer{addr: addr, from: from, auth: auth}
+}
+
+// Send emails the body to the address given. The subject is encoded, so it
+// may contain names chosen by users.
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.Replace(body, "\n", "\r\n", -1)
+
+	err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+	return errors.Annotatef(err, "Could not send email to %s", to)
+}
+
+// MailNotifier is a Notifier that emails the members of a group.
+type MailNotifier struct {
+	mailer Mailer
+}
+
+// NewMailNotifier creates a notifier that sends emails with the mailer.
+func NewMailNotifier(m Mailer) *MailNotifier {
+	return &MailNotifier{mailer: m}
+}
+
+// BudgetAlert emails the member how much of the budget of the category has
+// been spent this month.
+func (n *MailNotifier) BudgetAlert(member *Member, g *Group, c *Category, status *BudgetStatus) error {
+	spent := Money{Amount: int64(status.Spent), Currency: g.BaseCurrency()}
+	budget := Money{Amount: int64(status.Budget), Currency: g.BaseCurrency()}
+
+	subject := fmt.Sprintf("Budget alert: %s in %s", c.Name, g.Name)
+	body := fmt.Sprintf("Hi %s,\n\n%s has spent %s on %s in %s, against a budget of %s.\n",
+		member.Name, g.Name, spent, c.Name, status.Month.Format("January 2006"), budget)
+
+	if status.Level == BudgetOver {
+		body += "\nThe budget has been exceeded.\n"
+	} else {
+		body += fmt.Sprintf("\nThis is over %d%% of the budget.\n", BudgetWarningPercent)
+	}
+
+	return errors.Trace(n.mailer.Send(member.Email, subject, body))
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentMail records the last email given to it.
+type sentMail struct {
+	to, subject, body string
+}
+
+func (m *sentMail) Send(to, subject, body string) error {
+	m.to, m.subject, m.body = to, subject, body
+	return nil
+}
+
+func TestMailNotifier(t *testing.T) {
+	mail := &sentMail{}
+	n := models.NewMailNotifier(mail)
+
+	member := &models.Member{ID: 1, Name: "Alice", Email: "alice@example.com"}
+	g := &models.Group{ID: 1, Name: "Flat", Currency: models.GBP}
+	c := &models.Category{ID: 2, GroupID: 1, Name: "Groceries", Budget: 10000}
+	status := &models.BudgetStatus{
+		CategoryID: c.ID,
+		Month:      time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC),
+		Budget:     10000,
+		Spent:      10550,
+		Level:      models.BudgetOver,
+	}
+
+	err := n.BudgetAlert(member, g, c, status)
+	if err != nil {
+		t.Fatalf("Error sending budget alert: %v", err)
+	}
+
+	if mail.to != member.Email {
+		t.Fatalf("Expected alert to be sent to %s, got %s", member.Email, mail.to)
+	}
+
+	if mail.subject != "Budget alert: Groceries in Flat" {
+		t.Fatalf("Unexpected subject %q", mail.subject)
+	}
+
+	for _, s := range []string{"£105.50", "£100.00", "March 2016", "exceeded"} {
+		if !strings.Contains(mail.body, s) {
+			t.Fatalf("Expected body to contain %q, got %q", s, mail.body)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package models
 import (
 	"git.ianfross.com/ifross/expensetracker/auth"
 
+	"github.com/golang/glog"
 	"github.com/juju/errors"
+
+	"strings"
+	"time"
 )
 
 // Storer is the interface required in order to perform the actions required
@@ -18,46 +22,224 @@ type Storer interface {
 	GroupByID(int64) (*Group, error)
 	AddUserToGroup(*Group, *auth.User, bool) error
 	RemoveUserFromGroup(*Group, *auth.User) error
-	ExpensesByGroup(*Group) ([]*Expense, error)
+	ExpensesByGroup(*Group) ([]*Expense, error) // Ordered by creation
 	GroupsByUser(*auth.User) ([]*Group, error)
+	MembersByGroup(*Group) ([]*Member, error)
 	AllGroups() ([]*Group, error)
 
+	// Category storage functions
+	// DeleteCategory must return ErrCategoryInUse, and delete nothing, if
+	// any expenses or recurring expenses use the category.
+	InsertCategory(*Category) error
+	UpdateCategory(*Category) error
+	DeleteCategory(*Category) error
+	CategoryByID(int64) (*Category, error)         // Not found errors satisfy errors.IsNotFound
+	CategoriesByGroup(*Group) ([]*Category, error) // Ordered by name
+
 	// Expense storage functions
-	InsertExpense(*Expense, []int64) error // Need to fill in Id and Assignments
-	UpdateExpense(*Expense, []int64) error
+	// InsertExpense and UpdateExpense need to fill in the Id and
+	// Assignments. When the split uses RemainderRoundRobin, the rounding
+	// previously assigned in the group must be passed to Expense.Assign.
+	// InsertHistory does the same for every expense, each with its own
+	// split, and inserts the payments, keeping the CreatedAt of each.
+	InsertExpense(*Expense, Split) error
+	InsertHistory([]*Expense, []Split, []*Payment) error // All or none must be persisted
+	// ImportExpenses inserts and assigns the expenses returned by plan, each
+	// with its own split, as InsertHistory does. plan is given the expenses
+	// of the group, ordered by creation. Reading and inserting must be done
+	// in one transaction, so that nothing is added to the group in between
+	// and the same rows cannot be imported twice at once. If plan returns an
+	// error then nothing is inserted and the error is returned. plan must
+	// not use the store.
+	ImportExpenses(*Group, func([]*Expense) ([]*Expense, []Split, error)) ([]*Expense, error)
+	UpdateExpense(*Expense, Split) error
 	ExpenseByID(int64) (*Expense, error)
 	DeleteExpense(*Expense) error
+	// SearchExpenses returns the expenses of the group that match the
+	// search, with their assignments, the most recent first. Stores with
+	// full text search should use it for the text, others may use
+	// ExpenseSearch.Matches.
+	SearchExpenses(*Group, ExpenseSearch) ([]*Expense, error)
+	// ExpensesByQuery returns the expenses of the group selected by the
+	// query, with their assignments, in the order of the query. Only the
+	// expenses after the cursor are returned, and no more than the limit,
+	// which is always set. Stores that cannot filter the expenses
+	// themselves may use ExpenseQuery.Matches and ExpenseQuery.Before.
+	ExpensesByQuery(*Group, ExpenseQuery) ([]*Expense, error)
+
+	// Spending storage functions
+	// These total the expenses of the group created from the first time,
+	// inclusive, to the second, exclusive, by month, category and user.
+	// Totals must be grouped by currency, and ordered by month, category,
+	// user and currency. SpendingByPayer totals whole expenses by payer,
+	// while SpendingByAssignee totals the assignments by user.
+	// SpendingByCategory totals only the expenses of the category, by payer.
+	SpendingByPayer(*Group, time.Time, time.Time) ([]*SpendingTotal, error)
+	SpendingByAssignee(*Group, time.Time, time.Time) ([]*SpendingTotal, error)
+	SpendingByCategory(*Category, time.Time, time.Time) ([]*SpendingTotal, error)
 
 	// Payment storage functions
 	InsertPayment(*Payment) error
+	InsertPayments([]*Payment) error // All or none must be persisted
+	// SettleGroup inserts the payments returned by plan, which is given the
+	// expenses of the group, with their assignments, and its payments, both
+	// ordered by creation. Reading and inserting must be done in one
+	// transaction, so that nothing is added to the group in between. If plan
+	// returns an error then nothing is inserted and the error is returned.
+	// plan must not use the store.
+	SettleGroup(*Group, func([]*Expense, []*Payment) ([]*Payment, error)) ([]*Payment, error)
 	UpdatePayment(*Payment) error
 	DeletePayment(*Payment) error
 	PaymentByID(int64) (*Payment, error)
+	PaymentsByGroup(*Group) ([]*Payment, error) // Ordered by creation
+
+	// Recurring expense storage functions
+	InsertRecurringExpense(*RecurringExpense) error
+	UpdateRecurringExpense(*RecurringExpense) error
+	DeleteRecurringExpense(*RecurringExpense) error
+	RecurringExpenseByID(int64) (*RecurringExpense, error)
+	RecurringExpensesByGroup(*Group) ([]*RecurringExpense, error)
+	DueRecurringExpenses(time.Time) ([]*RecurringExpense, error) // NextDue on or before the time
+	// InsertDueExpense inserts and assigns the expense that was due at
+	// NextDue, using the split of the recurring expense and keeping the
+	// CreatedAt of the expense, and sets NextDue to the time given. Both must
+	// be done in one transaction, and only if NextDue has not been changed
+	// in storage since the recurring expense was retrieved. False is
+	// returned, and nothing is saved, if it had already been changed.
+	InsertDueExpense(*RecurringExpense, time.Time, *Expense) (bool, error)
+
+	// Attachment storage functions
+	// Attachments must be deleted along with their expense.
+	InsertAttachment(*Attachment) error
+	DeleteAttachment(*Attachment) error
+	AttachmentByID(int64) (*Attachment, error)
+	AttachmentsByExpense(*Expense) ([]*Attachment, error) // Ordered by creation
+	AttachmentsByGroup(*Group) ([]*Attachment, error)     // Ordered by creation
+	AttachmentsByPayer(*auth.User) ([]*Attachment, error) // Ordered by creation
 }
 
 // Manager contains the methods that are available to the models in the. The
 // manager needs to be created with a Storer interface, which deals with the
 // persistence of the structs. Actions built on these persistence methods
-// are available for use, for example in HTTP handlers.
+// are available for use, for example in HTTP handlers. The RateStore is used
+// to convert expenses and payments into the currency of their group, the
+// Notifier to alert members of a group when a budget is reached, and the
+// BlobStore to keep the receipts attached to expenses.
 type Manager struct {
-	store Storer
+	store    Storer
+	rates    RateStore
+	notifier Notifier
+	blobs    BlobStore
+}
+
+// NewManager creates a new instance of the Manager object. If the RateStore
+// is nil then only expenses and payments in the currency of their group can
+// be saved. If the Notifier is nil then no notifications are sent. If the
+// BlobStore is nil then receipts cannot be attached to expenses.
+func NewManager(s Storer, r RateStore, n Notifier, b BlobStore) *Manager {
+	if n == nil {
+		n = nopNotifier{}
+	}
+
+	return &Manager{s, r, n, b}
+}
+
+// NewGroup creates and persists a new group with the name and currency
+// supplied. If the currency is empty then the default currency is used. The
+// group starts with the default categories.
+func (m Manager) NewGroup(name, currency string) (*Group, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	c, err := CurrencyByCode(currency)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	g := &Group{Name: name, Currency: c.Code}
+	err = m.store.InsertGroup(g)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	for _, c := range DefaultCategories() {
+		c.GroupID = g.ID
+		err = m.store.InsertCategory(&c)
+		if err != nil {
+			// Don't leave a group behind without its categories
+			_ = m.store.DeleteGroup(g)
+			return nil, errors.Annotate(err, "Error creating default categories")
+		}
+	}
+
+	return g, nil
 }
 
-// NewManager creates a new instance of the Manager object.
-func NewManager(s Storer) *Manager {
-	return &Manager{s}
+// exchangeRate returns the rate to convert one currency into another on the
+// date given.
+func (m Manager) exchangeRate(from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	if m.rates == nil {
+		return 0, errors.Annotatef(ErrNoRate, "%s to %s", from, to)
+	}
+
+	r, err := m.rates.Rate(from, to, date)
+	return r, errors.Trace(err)
 }
 
-// NewGroup creates and persists a new group with the name supplied.
-func (m Manager) NewGroup(name string) (*Group, error) {
-	g := &Group{Name: name}
-	return g, errors.Trace(m.store.InsertGroup(g))
+// groupRate returns the rate to convert the currency given into the currency
+// of the group with the ID given. If the date is zero, the current date is
+// used.
+func (m Manager) groupRate(groupID int64, currency string, date time.Time) (float64, error) {
+	g, err := m.store.GroupByID(groupID)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	if date.IsZero() {
+		date = time.Now().UTC()
+	}
+
+	return m.exchangeRate(currency, g.BaseCurrency(), date)
 }
 
 // DeleteGroup removes the group from storage and any mappings to the members
-// of the group.
+// of the group. The contents of the attachments of its expenses are removed
+// once the group has been deleted.
 func (m Manager) DeleteGroup(g *Group) error {
-	return errors.Trace(m.store.DeleteGroup(g))
+	as, err := m.store.AttachmentsByGroup(g)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve group attachments")
+	}
+
+	err = m.store.DeleteGroup(g)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.deleteBlobs(attachmentKeys(as)...))
+}
+
+// DeleteUser removes the user using deleteUser, which is usually
+// auth.UserManager.DeleteUser, as users are stored by the auth package. The
+// expenses the user paid are deleted along with them, so the contents of
+// their attachments are removed once the user has been deleted.
+func (m Manager) DeleteUser(u *auth.User, deleteUser func(*auth.User) error) error {
+	as, err := m.store.AttachmentsByPayer(u)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve user attachments")
+	}
+
+	err = deleteUser(u)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.deleteBlobs(attachmentKeys(as)...))
 }
 
 func (m Manager) UserGroups(u *auth.User) ([]*Group, error) {
@@ -70,11 +252,55 @@ func (m Manager) UserGroups(u *auth.User) ([]*Group, error) {
 }
 
 // UpdateGroup saves any changes made to the group object. If the ID has been
-// changed then this will fail or over-write another existing group!
+// changed then this will fail or over-write another existing group! The
+// currency can only be changed while the group has no expenses or payments,
+// otherwise ErrCurrencyInUse is returned.
 func (m Manager) UpdateGroup(g *Group) error {
+	old, err := m.store.GroupByID(g.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if g.BaseCurrency() != old.BaseCurrency() {
+		c, err := CurrencyByCode(g.BaseCurrency())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		g.Currency = c.Code
+
+		inUse, err := m.hasExpensesOrPayments(g)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if inUse {
+			return errors.Trace(ErrCurrencyInUse)
+		}
+	}
+
 	return errors.Trace(m.store.UpdateGroup(g))
 }
 
+// hasExpensesOrPayments reports whether anything has been recorded in the
+// group.
+func (m Manager) hasExpensesOrPayments(g *Group) (bool, error) {
+	es, err := m.store.ExpensesByQuery(g, ExpenseQuery{Limit: 1})
+	if err != nil {
+		return false, errors.Annotate(err, "Could not retrieve group expenses")
+	}
+
+	if len(es) > 0 {
+		return true, nil
+	}
+
+	ps, err := m.store.PaymentsByGroup(g)
+	if err != nil {
+		return false, errors.Annotate(err, "Could not retrieve group payments")
+	}
+
+	return len(ps) > 0, nil
+}
+
 // GroupByID retrieves a group from persistence by the ID supplied.
 func (m Manager) GroupByID(id int64) (*Group, error) {
 	g, err := m.store.GroupByID(id)
@@ -87,6 +313,237 @@ func (m Manager) AllGroups() ([]*Group, error) {
 	return g, errors.Trace(err)
 }
 
+// GroupMembers retrieves all of the users that are members of the group.
+func (m Manager) GroupMembers(g *Group) ([]*Member, error) {
+	ms, err := m.store.MembersByGroup(g)
+	return ms, errors.Trace(err)
+}
+
+// checkMembers returns ErrNotMember if any of the users with the IDs given
+// are not members of the group.
+func (m Manager) checkMembers(g *Group, ids ...int64) error {
+	ms, err := m.store.MembersByGroup(g)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	members := make(map[int64]bool, len(ms))
+	for _, member := range ms {
+		members[member.ID] = true
+	}
+
+	for _, id := range ids {
+		if !members[id] {
+			return errors.Annotatef(ErrNotMember, "user %d in group %d", id, g.ID)
+		}
+	}
+
+	return nil
+}
+
+// GroupCategories retrieves the categories of the group, ordered by name.
+func (m Manager) GroupCategories(g *Group) ([]*Category, error) {
+	cs, err := m.store.CategoriesByGroup(g)
+	return cs, errors.Trace(err)
+}
+
+// CategoryByID retrieves a category from persistence by the ID supplied.
+func (m Manager) CategoryByID(id int64) (*Category, error) {
+	c, err := m.store.CategoryByID(id)
+	return c, errors.Trace(err)
+}
+
+// NewCategory creates and persists a new category in the group. If the
+// colour is empty then DefaultColour is used. Names are unique within a
+// group, ignoring case. A budget of 0 means the category has no budget.
+func (m Manager) NewCategory(g *Group, name, colour, icon string, budget Pence) (*Category, error) {
+	c := &Category{GroupID: g.ID, Name: name, Colour: colour, Icon: icon, Budget: budget}
+	err := m.checkCategoryName(c)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = m.store.InsertCategory(c)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error inserting category")
+	}
+
+	return c, nil
+}
+
+// UpdateCategory saves any changes to the name, colour, icon or budget of the
+// category. Expenses in the category are not changed.
+func (m Manager) UpdateCategory(c *Category) error {
+	err := m.checkCategoryName(c)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.store.UpdateCategory(c))
+}
+
+// DeleteCategory removes a category from the group. A category that is
+// still used by any expenses or recurring expenses cannot be deleted, and
+// ErrCategoryInUse is returned.
+func (m Manager) DeleteCategory(c *Category) error {
+	return errors.Trace(m.store.DeleteCategory(c))
+}
+
+// checkCategoryName validates the category and returns ErrDuplicateCategory
+// if another category in the group has the same name.
+func (m Manager) checkCategoryName(c *Category) error {
+	c.normalise()
+	err := c.validate()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	cs, err := m.store.CategoriesByGroup(&Group{ID: c.GroupID})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, other := range cs {
+		if other.ID != c.ID && strings.EqualFold(other.Name, c.Name) {
+			return errors.Annotatef(ErrDuplicateCategory, "%q", c.Name)
+		}
+	}
+
+	return nil
+}
+
+// checkCategory retrieves the category with the ID given, returning
+// ErrCategoryNotInGroup if it does not exist or does not belong to the group.
+func (m Manager) checkCategory(g *Group, id int64) (*Category, error) {
+	c, err := m.store.CategoryByID(id)
+	if errors.IsNotFound(err) {
+		return nil, errors.Annotatef(ErrCategoryNotInGroup, "category %d", id)
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if c.GroupID != g.ID {
+		return nil, errors.Annotatef(ErrCategoryNotInGroup, "category %d in group %d", id, g.ID)
+	}
+
+	return c, nil
+}
+
+// BudgetStatuses compares the amount spent during the month containing the
+// time given with the budget of each category of the group that has one.
+func (m Manager) BudgetStatuses(g *Group, month time.Time) ([]*BudgetStatus, error) {
+	cs, err := m.store.CategoriesByGroup(g)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	spent, err := m.categorySpending(g, month)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var ret []*BudgetStatus
+	for _, c := range cs {
+		if c.Budget > 0 {
+			ret = append(ret, newBudgetStatus(c, startOfMonth(month), spent[c.ID]))
+		}
+	}
+
+	return ret, nil
+}
+
+// budgetStatus returns the budget status of the category for the month
+// containing the time given, or nil if the category has no budget.
+func (m Manager) budgetStatus(g *Group, c *Category, month time.Time) (*BudgetStatus, error) {
+	if c.Budget <= 0 {
+		return nil, nil
+	}
+
+	// Only the category is totalled, as this is checked for every expense
+	from := startOfMonth(month)
+	to := from.AddDate(0, 1, 0)
+	ts, err := m.store.SpendingByCategory(c, from, to)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not total category spending")
+	}
+
+	r, err := newReport(g, from, to, ReportByPayer, ts)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return newBudgetStatus(c, from, r.Categories[c.ID]), nil
+}
+
+// categorySpending returns the amount spent in each category of the group
+// during the month containing the time given, in the currency of the group.
+func (m Manager) categorySpending(g *Group, month time.Time) (map[int64]Pence, error) {
+	from := startOfMonth(month)
+	r, err := m.SpendingReport(g, from, from.AddDate(0, 1, 0), ReportByPayer)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return r.Categories, nil
+}
+
+// SpendingReport breaks down the spending of the group between the times
+// given by month, category and either payer or assignee. The spending is
+// totalled by storage rather than by retrieving every expense.
+func (m Manager) SpendingReport(g *Group, from, to time.Time, by ReportBy) (*Report, error) {
+	from, to = from.UTC(), to.UTC()
+
+	var ts []*SpendingTotal
+	var err error
+	switch by {
+	case ReportByPayer:
+		ts, err = m.store.SpendingByPayer(g, from, to)
+	case ReportByAssignee:
+		ts, err = m.store.SpendingByAssignee(g, from, to)
+	default:
+		return nil, errors.Trace(ErrUnknownReportBy)
+	}
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not total spending")
+	}
+
+	r, err := newReport(g, from, to, by, ts)
+	return r, errors.Trace(err)
+}
+
+// alertBudget notifies the members of the group if the category has reached
+// a higher budget level since the status given was retrieved. The expense
+// that caused the change has already been saved, so failures are logged
+// rather than returned.
+func (m Manager) alertBudget(g *Group, c *Category, before *BudgetStatus) {
+	if before == nil {
+		return
+	}
+
+	after, err := m.budgetStatus(g, c, before.Month)
+	if err != nil {
+		glog.Errorf("Could not check budget of category %d: %v", c.ID, errors.ErrorStack(err))
+		return
+	}
+
+	if after.Level <= before.Level {
+		return
+	}
+
+	ms, err := m.store.MembersByGroup(g)
+	if err != nil {
+		glog.Errorf("Could not get members to alert for category %d: %v", c.ID, errors.ErrorStack(err))
+		return
+	}
+
+	for _, member := range ms {
+		err = m.notifier.BudgetAlert(member, g, c, after)
+		if err != nil {
+			glog.Errorf("Could not send budget alert to user %d: %v", member.ID, errors.ErrorStack(err))
+		}
+	}
+}
+
 // AddUserToGroup associates a user to the group. This is done internally by
 // creating a mapping between the user and the group.
 func (m Manager) AddUserToGroup(g *Group, u *auth.User, admin bool) error {
@@ -100,6 +557,21 @@ func (m Manager) RemoveUserFromGroup(g *Group, u *auth.User) error {
 	return errors.Trace(m.store.RemoveUserFromGroup(g, u))
 }
 
+// LeaveGroup removes the user from the group. A user can only leave once
+// they have settled up, as their expenses and payments remain in the group.
+func (m Manager) LeaveGroup(g *Group, u *auth.User) error {
+	b, err := m.GroupBalances(g)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if b[u.ID] != 0 {
+		return errors.Annotatef(ErrOutstandingBalance, "balance of %s", Money{int64(b[u.ID]), g.BaseCurrency()})
+	}
+
+	return errors.Trace(m.store.RemoveUserFromGroup(g, u))
+}
+
 // NewExpense creates, assigns and persists a new expense. The assignments
 // should be created using AssignExpense.
 // For consistency, the expense and the assignments need to occur
@@ -108,50 +580,164 @@ func (m Manager) RemoveUserFromGroup(g *Group, u *auth.User) error {
 // AssignExpense cannot be used to persist the assignments, as this must
 // be called within the transaction. This can only be guaranteed at the
 // storage driver level (i.e. the implementation of the Storer interface)
-func (m Manager) NewExpense(g *Group, amount Pence, payer int64, cat Category, desc string, users []int64) (*Expense, error) {
-	e := &Expense{
-		Amount:      amount,
-		PayerID:     payer,
-		Category:    cat,
-		Description: desc,
-		GroupID:     g.ID,
+// The split determines how the expense is divided between the users; use
+// EqualSplit to divide the expense equally.
+func (m Manager) NewExpense(g *Group, amount Money, payer, category int64, desc string, split Split) (*Expense, error) {
+	e, cat, err := m.prepareExpense(g, amount, payer, category, desc, split, time.Now().UTC())
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 
-	if err := m.store.InsertExpense(e, users); err != nil {
+	before, err := m.budgetStatus(g, cat, time.Now())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if err := m.store.InsertExpense(e, split); err != nil {
 		return nil, errors.Annotate(err, "Unable to insert expense")
 	}
 
+	m.alertBudget(g, cat, before)
 	return e, nil
 }
 
+// prepareExpense checks that a new expense can be saved in the group, and
+// creates it with the rate to convert it into the currency of the group on
+// the date given.
+func (m Manager) prepareExpense(g *Group, amount Money, payer, category int64, desc string, split Split, date time.Time) (*Expense, *Category, error) {
+	c, err := CurrencyByCode(amount.Currency)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	rate, err := m.exchangeRate(c.Code, g.BaseCurrency(), date)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "Unable to convert expense into group currency")
+	}
+
+	err = m.checkMembers(g, append(split.UserIDs(), payer)...)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	cat, err := m.checkCategory(g, category)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	e := &Expense{
+		Amount:       Pence(amount.Amount),
+		Currency:     c.Code,
+		ExchangeRate: rate,
+		PayerID:      payer,
+		CategoryID:   category,
+		Description:  desc,
+		GroupID:      g.ID,
+	}
+	return e, cat, nil
+}
+
 // UpdateExpense saves any changes to the expense. If there are changes
 // made to the amount or number of people, then all previous assignments
 // must be removed and this must be reassigned. This must all happen within
-// a transaction.
-func (m Manager) UpdateExpense(e *Expense, users []int64) error {
+// a transaction. The exchange rate is looked up again for the date of the
+// expense, in case the currency has changed.
+func (m Manager) UpdateExpense(e *Expense, split Split) error {
+	rate, err := m.groupRate(e.GroupID, e.Money().Currency, e.CreatedAt)
+	if err != nil {
+		return errors.Annotate(err, "Unable to convert expense into group currency")
+	}
+	e.ExchangeRate = rate
+
+	g, err := m.store.GroupByID(e.GroupID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = m.checkMembers(g, append(split.UserIDs(), e.PayerID)...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	cat, err := m.checkCategory(g, e.CategoryID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	before, err := m.budgetStatus(g, cat, e.CreatedAt)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
 	// the storage function needs to remove all the assignments
 	// and reassign the expense within a transaction. This
 	// is to ensure consistency within the database.
-	return errors.Trace(m.store.UpdateExpense(e, users))
+	err = m.store.UpdateExpense(e, split)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	m.alertBudget(g, cat, before)
+	return nil
+}
+
+// ExpenseByID retrieves an expense, along with its assignments.
+func (m Manager) ExpenseByID(id int64) (*Expense, error) {
+	e, err := m.store.ExpenseByID(id)
+	return e, errors.Trace(err)
 }
 
 // DeleteExpense removes an expense and any assignments associated with the
-// expense. This must be transactional.
+// expense. This must be transactional. The contents of any attachments are
+// removed once the expense has been deleted.
 func (m Manager) DeleteExpense(e *Expense) error {
-	// Deletes all associated assignments
-	return errors.Trace(m.store.DeleteExpense(e))
+	as, err := m.store.AttachmentsByExpense(e)
+	if err != nil {
+		return errors.Annotate(err, "Could not retrieve expense attachments")
+	}
+
+	// Deletes all associated assignments and attachments
+	err = m.store.DeleteExpense(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.deleteBlobs(attachmentKeys(as)...))
 }
 
 // InsertPayment persists a payment of money from one person to another within
-// a group.
-func (m Manager) InsertPayment(g *Group, giver, receiver int64, amount Pence) (*Payment, error) {
+// a group. Both people must be members of the group.
+func (m Manager) InsertPayment(g *Group, giver, receiver int64, amount Money) (*Payment, error) {
+	c, err := CurrencyByCode(amount.Currency)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	rate, err := m.exchangeRate(c.Code, g.BaseCurrency(), time.Now().UTC())
+	if err != nil {
+		return nil, errors.Annotate(err, "Unable to convert payment into group currency")
+	}
+
 	p := &Payment{
-		Amount:     amount,
-		GroupID:    g.ID,
-		GiverID:    giver,
-		ReceiverID: receiver,
+		Amount:       Pence(amount.Amount),
+		Currency:     c.Code,
+		ExchangeRate: rate,
+		GroupID:      g.ID,
+		GiverID:      giver,
+		ReceiverID:   receiver,
+	}
+
+	err = p.validate()
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
-	err := m.store.InsertPayment(p)
+
+	err = m.checkMembers(g, giver, receiver)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = m.store.InsertPayment(p)
 	if err != nil {
 		return nil, errors.Annotate(err, "Error inserting payment")
 	}
@@ -164,8 +750,26 @@ func (m Manager) DeletePayment(p *Payment) error {
 	return errors.Trace(m.store.DeletePayment(p))
 }
 
-// UpdatePayment saves any modifications to the payment
+// UpdatePayment saves any modifications to the payment. The exchange rate is
+// looked up again for the date of the payment. Both people must still be
+// members of the group.
 func (m Manager) UpdatePayment(p *Payment) error {
+	rate, err := m.groupRate(p.GroupID, p.Money().Currency, p.CreatedAt)
+	if err != nil {
+		return errors.Annotate(err, "Unable to convert payment into group currency")
+	}
+	p.ExchangeRate = rate
+
+	err = p.validate()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = m.checkMembers(&Group{ID: p.GroupID}, p.GiverID, p.ReceiverID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
 	return errors.Trace(m.store.UpdatePayment(p))
 }
 
@@ -178,11 +782,208 @@ func (m Manager) PaymentByID(id int64) (*Payment, error) {
 	return p, nil
 }
 
-func (m Manager) GroupExpenses(g *Group) ([]*Expense, error) {
+// GroupPayments retrieves all of the payments made within the group.
+func (m Manager) GroupPayments(g *Group) ([]*Payment, error) {
+	ps, err := m.store.PaymentsByGroup(g)
+	return ps, errors.Trace(err)
+}
+
+// GroupBalances calculates the net position of every user that has been
+// involved in an expense or payment within the group. The balances are in the
+// currency of the group, converted at the rates recorded on each expense and
+// payment.
+func (m Manager) GroupBalances(g *Group) (Balances, error) {
 	es, err := m.store.ExpensesByGroup(g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not retrieve group expenses")
+	}
+
+	ps, err := m.store.PaymentsByGroup(g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not retrieve group payments")
+	}
+
+	return balances(g, es, ps)
+}
+
+// balances calculates the balances of the group from its expenses and
+// payments, converted into the currency of the group.
+func balances(g *Group, es []*Expense, ps []*Payment) (Balances, error) {
+	var err error
+	currency := g.BaseCurrency()
+	for i, e := range es {
+		es[i], err = convertExpense(e, currency)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	for i, p := range ps {
+		ps[i], err = convertPayment(p, currency)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return CalculateBalances(es, ps), nil
+}
+
+// SettleUpPlan creates the payments needed to settle all of the debts within
+// the group. The payments are in the currency of the group and are not
+// persisted.
+func (m Manager) SettleUpPlan(g *Group) ([]*Payment, error) {
+	b, err := m.GroupBalances(g)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return settleUpPlan(g, b), nil
+}
+
+func settleUpPlan(g *Group, b Balances) []*Payment {
+	ps := SettleUp(b)
+	for _, p := range ps {
+		p.GroupID = g.ID
+		p.Currency = g.BaseCurrency()
+		p.ExchangeRate = 1
+	}
+	return ps
+}
+
+// SettleUp creates and persists the payments needed to settle all of the
+// debts within the group. confirmed is the plan that was shown to the user,
+// and ErrPlanChanged is returned if it no longer settles the group. The plan
+// is checked and the payments inserted in one transaction, so either the
+// group is fully settled or nothing is saved.
+func (m Manager) SettleUp(g *Group, confirmed []*Payment) ([]*Payment, error) {
+	ps, err := m.store.SettleGroup(g, func(es []*Expense, ps []*Payment) ([]*Payment, error) {
+		b, err := balances(g, es, ps)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		plan := settleUpPlan(g, b)
+		if !samePayments(plan, confirmed) {
+			return nil, ErrPlanChanged
+		}
+		return plan, nil
+	})
+	if err != nil {
+		return nil, errors.Annotate(err, "Error inserting settle up payments")
+	}
+
+	return ps, nil
+}
+
+// NewRecurringExpense validates and persists the definition of a recurring
+// expense. The first expense is due on the first day matching the schedule on
+// or after the start date.
+func (m Manager) NewRecurringExpense(r *RecurringExpense, start time.Time) error {
+	if err := r.validate(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := m.checkCategory(&Group{ID: r.GroupID}, r.CategoryID); err != nil {
+		return errors.Trace(err)
+	}
+
+	r.NextDue = r.nextOnOrAfter(start.UTC())
+	return errors.Trace(m.store.InsertRecurringExpense(r))
+}
+
+// UpdateRecurringExpense saves any changes to the definition of a recurring
+// expense. Expenses that have already been created are not changed.
+func (m Manager) UpdateRecurringExpense(r *RecurringExpense) error {
+	if err := r.validate(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := m.checkCategory(&Group{ID: r.GroupID}, r.CategoryID); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(m.store.UpdateRecurringExpense(r))
+}
+
+// DeleteRecurringExpense stops a recurring expense from creating any more
+// expenses. Expenses that have already been created are not removed.
+func (m Manager) DeleteRecurringExpense(r *RecurringExpense) error {
+	return errors.Trace(m.store.DeleteRecurringExpense(r))
+}
+
+// RecurringExpenseByID retrieves the definition of a recurring expense.
+func (m Manager) RecurringExpenseByID(id int64) (*RecurringExpense, error) {
+	r, err := m.store.RecurringExpenseByID(id)
+	return r, errors.Trace(err)
+}
+
+// GroupRecurringExpenses retrieves all of the recurring expenses of a group.
+func (m Manager) GroupRecurringExpenses(g *Group) ([]*RecurringExpense, error) {
+	rs, err := m.store.RecurringExpensesByGroup(g)
+	return rs, errors.Trace(err)
+}
+
+// GenerateRecurringExpenses creates an expense for every recurring expense
+// that has fallen due on or before the time given. If an expense has been
+// missed more than once, one expense is created for each time it was due.
+// Each expense is dated, and converted at the exchange rate of, the day it
+// was due. The expense is inserted in the same transaction that advances
+// NextDue, so running this more than once, even concurrently, never creates
+// the same expense twice or skips one. If a recurring expense cannot be
+// created then the error is logged and the others are still created.
+func (m Manager) GenerateRecurringExpenses(now time.Time) ([]*Expense, error) {
+	rs, err := m.store.DueRecurringExpenses(now)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not retrieve due recurring expenses")
+	}
+
+	var ret []*Expense
+	for _, r := range rs {
+		es, err := m.generateRecurringExpense(r, now)
+		ret = append(ret, es...)
+		if err != nil {
+			glog.Errorf("Could not create expense for recurring expense %d: %v", r.ID, errors.ErrorStack(err))
+		}
+	}
+
+	return ret, nil
+}
+
+// generateRecurringExpense creates the expenses for each time the recurring
+// expense has been due on or before the time given.
+func (m Manager) generateRecurringExpense(r *RecurringExpense, now time.Time) ([]*Expense, error) {
+	g, err := m.store.GroupByID(r.GroupID)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	return es, nil
+	var ret []*Expense
+	for !r.NextDue.After(now) {
+		due := r.NextDue
+		e, cat, err := m.prepareExpense(g, r.Money(), r.PayerID, r.CategoryID, r.Description, r.Split, due)
+		if err != nil {
+			return ret, errors.Trace(err)
+		}
+		e.CreatedAt = due
+
+		before, err := m.budgetStatus(g, cat, due)
+		if err != nil {
+			return ret, errors.Trace(err)
+		}
+
+		inserted, err := m.store.InsertDueExpense(r, r.NextAfter(due), e)
+		if err != nil {
+			return ret, errors.Annotatef(err, "Due %s", due.Format("2006-01-02"))
+		}
+
+		if !inserted {
+			// Another run has already created this expense
+			break
+		}
+
+		m.alertBudget(g, cat, before)
+		ret = append(ret, e)
+	}
+
+	return ret, nil
 }
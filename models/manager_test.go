@@ -0,0 +1,434 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+	"git.ianfross.com/ifross/expensetracker/models/memstore"
+
+	"github.com/juju/errors"
+
+	"testing"
+	"time"
+)
+
+// newTestGroup creates a manager backed by an in memory store, along with a
+// group containing the number of users given.
+func newTestGroup(t *testing.T, n int) (*models.Manager, *models.Group, []*auth.User) {
+	return newNotifiedTestGroup(t, n, nil)
+}
+
+// newNotifiedTestGroup is newTestGroup with a manager that uses the notifier
+// given.
+func newNotifiedTestGroup(t *testing.T, n int, notifier models.Notifier) (*models.Manager, *models.Group, []*auth.User) {
+	return newBlobTestGroup(t, n, notifier, nil)
+}
+
+// newBlobTestGroup is newNotifiedTestGroup with a manager that also uses the
+// blob store given.
+func newBlobTestGroup(t *testing.T, n int, notifier models.Notifier, blobs models.BlobStore) (*models.Manager, *models.Group, []*auth.User) {
+	st := memstore.New()
+	m := models.NewManager(st, nil, notifier, blobs)
+
+	g, err := m.NewGroup("Test group", "")
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	var us []*auth.User
+	for i := 0; i < n; i++ {
+		u := &auth.User{Email: string('a'+rune(i)) + "@example.com", Name: "TEST"}
+		err = st.Insert(u)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+		}
+
+		err = m.AddUserToGroup(g, u, false)
+		if err != nil {
+			t.Fatalf("Error adding user to group: %v", err)
+		}
+		us = append(us, u)
+	}
+
+	return m, g, us
+}
+
+// mustCategory returns the ID of the group's category with the name given.
+func mustCategory(t *testing.T, m *models.Manager, g *models.Group, name string) int64 {
+	cs, err := m.GroupCategories(g)
+	if err != nil {
+		t.Fatalf("Error getting categories: %v", err)
+	}
+
+	for _, c := range cs {
+		if c.Name == name {
+			return c.ID
+		}
+	}
+
+	t.Fatalf("Group %d has no category %q", g.ID, name)
+	return 0
+}
+
+func TestNewExpenseMembers(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	bills := mustCategory(t, m, g, "Bills")
+
+	_, err := m.NewExpense(g, models.Money{Amount: 1000, Currency: models.GBP}, us[0].ID, bills, "Bill", split)
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	err = m.RemoveUserFromGroup(g, us[1])
+	if err != nil {
+		t.Fatalf("Error removing user from group: %v", err)
+	}
+
+	_, err = m.NewExpense(g, models.Money{Amount: 1000, Currency: models.GBP}, us[0].ID, bills, "Bill", split)
+	if errors.Cause(err) != models.ErrNotMember {
+		t.Fatalf("Expected ErrNotMember assigning to a non member, got %v", err)
+	}
+}
+
+func TestGroupCurrency(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+
+	_, err := m.NewGroup("Unknown currency", "XYZ")
+	if errors.Cause(err) != models.ErrUnknownCurrency {
+		t.Fatalf("Expected ErrUnknownCurrency creating group, got %v", err)
+	}
+
+	eur, err := m.NewGroup("Euro group", "eur")
+	if err != nil || eur.Currency != "EUR" {
+		t.Fatalf("Expected group in EUR, got %+v, %v", eur, err)
+	}
+
+	g.Currency = "EUR"
+	err = m.UpdateGroup(g)
+	if err != nil {
+		t.Fatalf("Error changing currency of empty group: %v", err)
+	}
+
+	_, err = m.NewExpense(g, models.Money{Amount: 1000, Currency: "EUR"}, us[0].ID, mustCategory(t, m, g, "Bills"), "Bill", models.EqualSplit([]int64{us[0].ID, us[1].ID}))
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	g.Currency = "GBP"
+	err = m.UpdateGroup(g)
+	if errors.Cause(err) != models.ErrCurrencyInUse {
+		t.Fatalf("Expected ErrCurrencyInUse changing currency of group with expenses, got %v", err)
+	}
+
+	g.Currency = "EUR"
+	g.Name = "Renamed"
+	err = m.UpdateGroup(g)
+	if err != nil {
+		t.Fatalf("Error renaming group with expenses: %v", err)
+	}
+}
+
+func TestGenerateRecurringExpenses(t *testing.T) {
+	st := memstore.New()
+	rates := models.NewRateTable(
+		models.ExchangeRate{Date: time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), From: "EUR", To: "GBP", Rate: 0.78},
+		models.ExchangeRate{Date: time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC), From: "EUR", To: "GBP", Rate: 0.80},
+	)
+	m := models.NewManager(st, rates, nil, nil)
+
+	g, err := m.NewGroup("Test group", "GBP")
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	u := &auth.User{Email: "a@example.com", Name: "TEST"}
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+	}
+
+	err = m.AddUserToGroup(g, u, false)
+	if err != nil {
+		t.Fatalf("Error adding user to group: %v", err)
+	}
+
+	start := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	newRecurring := func(currency string) *models.RecurringExpense {
+		r := &models.RecurringExpense{
+			GroupID:    g.ID,
+			PayerID:    u.ID,
+			Amount:     1000,
+			Currency:   currency,
+			CategoryID: mustCategory(t, m, g, "Bills"),
+			Split:      models.EqualSplit([]int64{u.ID}),
+			Frequency:  models.FrequencyMonthly,
+			Day:        1,
+		}
+
+		err := m.NewRecurringExpense(r, start)
+		if err != nil {
+			t.Fatalf("Error creating recurring expense: %v", err)
+		}
+		return r
+	}
+
+	// There is no rate for USD, so it cannot be created, but the expenses in
+	// EUR must still be.
+	newRecurring("USD")
+	newRecurring("EUR")
+
+	es, err := m.GenerateRecurringExpenses(time.Date(2016, 4, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Error generating recurring expenses: %v", err)
+	}
+
+	if len(es) != 2 {
+		t.Fatalf("Expected 2 expenses to be generated, got %d", len(es))
+	}
+
+	for i, rate := range []float64{0.78, 0.80} {
+		due := start.AddDate(0, i, 0)
+		if !es[i].CreatedAt.Equal(due) || es[i].ExchangeRate != rate {
+			t.Fatalf("Expected expense created on %s at %v, got %s at %v", due, rate, es[i].CreatedAt, es[i].ExchangeRate)
+		}
+	}
+
+	rs, err := m.GroupRecurringExpenses(g)
+	if err != nil {
+		t.Fatalf("Error getting recurring expenses: %v", err)
+	}
+
+	for _, r := range rs {
+		expected := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+		if r.Currency == "USD" {
+			expected = start
+		}
+
+		if !r.NextDue.Equal(expected) {
+			t.Fatalf("Expected %s recurring expense next due %s, got %s", r.Currency, expected, r.NextDue)
+		}
+	}
+}
+
+func TestSettleUpAndLeave(t *testing.T) {
+	m, g, us := newTestGroup(t, 3)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID, us[2].ID})
+
+	_, err := m.NewExpense(g, models.Money{Amount: 3000, Currency: models.GBP}, us[0].ID, mustCategory(t, m, g, "Bills"), "Bill", split)
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	err = m.LeaveGroup(g, us[1])
+	if errors.Cause(err) != models.ErrOutstandingBalance {
+		t.Fatalf("Expected ErrOutstandingBalance leaving before settling, got %v", err)
+	}
+
+	plan, err := m.SettleUpPlan(g)
+	if err != nil {
+		t.Fatalf("Error planning settle up: %v", err)
+	}
+
+	_, err = m.SettleUp(g, plan[1:])
+	if errors.Cause(err) != models.ErrPlanChanged {
+		t.Fatalf("Expected ErrPlanChanged settling up with another plan, got %v", err)
+	}
+
+	ps, err := m.SettleUp(g, plan)
+	if err != nil {
+		t.Fatalf("Error settling up: %v", err)
+	}
+
+	if len(ps) != 2 {
+		t.Fatalf("Expected 2 payments to settle up, got %d", len(ps))
+	}
+
+	b, err := m.GroupBalances(g)
+	if err != nil {
+		t.Fatalf("Error getting balances: %v", err)
+	}
+
+	for id, amount := range b {
+		if amount != 0 {
+			t.Fatalf("Expected user %d to be settled, balance %s", id, amount)
+		}
+	}
+
+	err = m.LeaveGroup(g, us[1])
+	if err != nil {
+		t.Fatalf("Error leaving group: %v", err)
+	}
+
+	members, err := m.GroupMembers(g)
+	if err != nil {
+		t.Fatalf("Error getting members: %v", err)
+	}
+
+	if len(members) != 2 {
+		t.Fatalf("Expected 2 members after leaving, got %d", len(members))
+	}
+}
+
+// brokenCategoryStore fails to retrieve categories, as if the database could
+// not be reached.
+type brokenCategoryStore struct {
+	models.Storer
+}
+
+var errBrokenStore = errors.New("connection refused")
+
+func (brokenCategoryStore) CategoryByID(int64) (*models.Category, error) {
+	return nil, errBrokenStore
+}
+
+func TestCheckCategory(t *testing.T) {
+	m, g, us := newTestGroup(t, 1)
+	split := models.EqualSplit([]int64{us[0].ID})
+	amount := models.Money{Amount: 500, Currency: models.GBP}
+
+	_, err := m.NewExpense(g, amount, us[0].ID, 1000, "Missing category", split)
+	if errors.Cause(err) != models.ErrCategoryNotInGroup {
+		t.Fatalf("Expected ErrCategoryNotInGroup for a missing category, got %v", err)
+	}
+
+	other, err := m.NewGroup("Other group", "")
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	_, err = m.NewExpense(g, amount, us[0].ID, mustCategory(t, m, other, "Bills"), "Other category", split)
+	if errors.Cause(err) != models.ErrCategoryNotInGroup {
+		t.Fatalf("Expected ErrCategoryNotInGroup for another group's category, got %v", err)
+	}
+
+	st := memstore.New()
+	u := &auth.User{Email: "broken@example.com", Name: "TEST"}
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+	}
+
+	m = models.NewManager(st, nil, nil, nil)
+	g, err = m.NewGroup("Broken group", "")
+	if err == nil {
+		err = m.AddUserToGroup(g, u, false)
+	}
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	broken := models.NewManager(brokenCategoryStore{st}, nil, nil, nil)
+	split = models.EqualSplit([]int64{u.ID})
+	_, err = broken.NewExpense(g, amount, u.ID, mustCategory(t, m, g, "Bills"), "Broken store", split)
+	if errors.Cause(err) != errBrokenStore {
+		t.Fatalf("Expected the store's error to be returned, got %v", err)
+	}
+}
+
+func TestGroupCategories(t *testing.T) {
+	m, g, us := newTestGroup(t, 1)
+
+	cs, err := m.GroupCategories(g)
+	if err != nil {
+		t.Fatalf("Error getting categories: %v", err)
+	}
+
+	if len(cs) != len(models.DefaultCategories()) {
+		t.Fatalf("Expected the default categories for a new group, got %d", len(cs))
+	}
+
+	_, err = m.NewCategory(g, " bills ", "", "", 0)
+	if errors.Cause(err) != models.ErrDuplicateCategory {
+		t.Fatalf("Expected ErrDuplicateCategory, got %v", err)
+	}
+
+	_, err = m.NewCategory(g, "Holiday", "blue", "", 0)
+	if errors.Cause(err) != models.ErrInvalidColour {
+		t.Fatalf("Expected ErrInvalidColour, got %v", err)
+	}
+
+	c, err := m.NewCategory(g, " Holiday ", "", "plane", 0)
+	if err != nil {
+		t.Fatalf("Error creating category: %v", err)
+	}
+
+	if c.Name != "Holiday" || c.Colour != models.DefaultColour {
+		t.Fatalf("Expected trimmed name and default colour, got %+v", c)
+	}
+
+	other, err := m.NewGroup("Other group", "")
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	money := models.Money{Amount: 1000, Currency: models.GBP}
+	split := models.EqualSplit([]int64{us[0].ID})
+	_, err = m.NewExpense(g, money, us[0].ID, mustCategory(t, m, other, "Bills"), "Bill", split)
+	if errors.Cause(err) != models.ErrCategoryNotInGroup {
+		t.Fatalf("Expected ErrCategoryNotInGroup, got %v", err)
+	}
+
+	_, err = m.NewExpense(g, money, us[0].ID, c.ID, "Flights", split)
+	if err != nil {
+		t.Fatalf("Error creating expense: %v", err)
+	}
+
+	err = m.DeleteCategory(c)
+	if errors.Cause(err) != models.ErrCategoryInUse {
+		t.Fatalf("Expected ErrCategoryInUse, got %v", err)
+	}
+}
+
+// recordingNotifier records the budget alerts sent to each member.
+type recordingNotifier struct {
+	alerts map[int64][]models.BudgetLevel
+}
+
+func (n *recordingNotifier) BudgetAlert(m *models.Member, g *models.Group, c *models.Category, b *models.BudgetStatus) error {
+	n.alerts[m.ID] = append(n.alerts[m.ID], b.Level)
+	return nil
+}
+
+func TestBudgetAlerts(t *testing.T) {
+	n := &recordingNotifier{alerts: make(map[int64][]models.BudgetLevel)}
+	m, g, us := newNotifiedTestGroup(t, 2, n)
+
+	c, err := m.NewCategory(g, "Holiday", "", "", 10000)
+	if err != nil {
+		t.Fatalf("Error creating category: %v", err)
+	}
+
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	for _, amount := range []int64{5000, 2000, 1000, 1000, 2000, 500} {
+		_, err = m.NewExpense(g, models.Money{Amount: amount, Currency: models.GBP}, us[0].ID, c.ID, "Hotel", split)
+		if err != nil {
+			t.Fatalf("Error creating expense: %v", err)
+		}
+	}
+
+	// 80% is reached by the third expense and exceeded by the fifth
+	for _, u := range us {
+		alerts := n.alerts[u.ID]
+		if len(alerts) != 2 || alerts[0] != models.BudgetWarning || alerts[1] != models.BudgetOver {
+			t.Fatalf("Expected a warning then an over budget alert for user %d, got %v", u.ID, alerts)
+		}
+	}
+
+	bs, err := m.BudgetStatuses(g, time.Now())
+	if err != nil {
+		t.Fatalf("Error getting budget statuses: %v", err)
+	}
+
+	if len(bs) != 1 || bs[0].Spent != 11500 || bs[0].Level != models.BudgetOver {
+		t.Fatalf("Expected 115.00 spent over budget, got %+v", bs)
+	}
+
+	bs, err = m.BudgetStatuses(g, time.Now().AddDate(0, -1, 0))
+	if err != nil {
+		t.Fatalf("Error getting budget statuses: %v", err)
+	}
+
+	if len(bs) != 1 || bs[0].Spent != 0 || bs[0].Level != models.BudgetOK {
+		t.Fatalf("Expected nothing spent last month, got %+v", bs)
+	}
+}
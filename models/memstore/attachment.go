@@ -0,0 +1,118 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"sort"
+)
+
+func copyAttachment(a *models.Attachment) *models.Attachment {
+	ret := *a
+	return &ret
+}
+
+func (s *memStore) InsertAttachment(a *models.Attachment) error {
+	if a.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.expenses[a.ExpenseID]; !ok {
+		return errors.Annotate(errors.NotFoundf("expense with id %d", a.ExpenseID), "Error inserting attachment")
+	}
+
+	for _, stored := range s.attachments {
+		if stored.Key == a.Key {
+			return errors.Errorf("Error inserting attachment: key %q already used", a.Key)
+		}
+	}
+
+	a.ID = s.nextID()
+	a.CreatedAt = now()
+	s.attachments[a.ID] = copyAttachment(a)
+	return nil
+}
+
+func (s *memStore) DeleteAttachment(a *models.Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attachments[a.ID]; !ok {
+		return errors.New("Attachment does not exist")
+	}
+
+	delete(s.attachments, a.ID)
+	a.ID = 0
+	return nil
+}
+
+func (s *memStore) AttachmentByID(id int64) (*models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.attachments[id]
+	if !ok {
+		return nil, errors.NotFoundf("attachment with id %d", id)
+	}
+
+	return copyAttachment(a), nil
+}
+
+func (s *memStore) AttachmentsByExpense(e *models.Expense) ([]*models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.attachmentsWhere(func(expense *models.Expense) bool {
+		return expense.ID == e.ID
+	}), nil
+}
+
+func (s *memStore) AttachmentsByGroup(g *models.Group) ([]*models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.attachmentsWhere(func(e *models.Expense) bool {
+		return e.GroupID == g.ID
+	}), nil
+}
+
+func (s *memStore) AttachmentsByPayer(u *auth.User) ([]*models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.attachmentsWhere(func(e *models.Expense) bool {
+		return e.PayerID == u.ID
+	}), nil
+}
+
+// attachmentsWhere returns copies of the attachments of the expenses that
+// match, in the order they were attached. The lock must be held.
+func (s *memStore) attachmentsWhere(match func(*models.Expense) bool) []*models.Attachment {
+	var as []*models.Attachment
+	for _, a := range s.attachments {
+		if e, ok := s.expenses[a.ExpenseID]; ok && match(e) {
+			as = append(as, copyAttachment(a))
+		}
+	}
+
+	// IDs increase, so are in the order the attachments were created
+	sort.Slice(as, func(i, j int) bool {
+		return as[i].ID < as[j].ID
+	})
+	return as
+}
+
+// deleteAttachments removes the attachments of an expense, the lock must be
+// held by the caller.
+func (s *memStore) deleteAttachments(expenseID int64) {
+	for id, a := range s.attachments {
+		if a.ExpenseID == expenseID {
+			delete(s.attachments, id)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"sort"
+)
+
+func copyCategory(c *models.Category) *models.Category {
+	ret := *c
+	return &ret
+}
+
+// checkCategory enforces the foreign key and the unique name of the
+// categories table.
+func (s *memStore) checkCategory(c *models.Category) error {
+	if _, ok := s.groups[c.GroupID]; !ok {
+		return errors.NotFoundf("group with id %d", c.GroupID)
+	}
+
+	for _, other := range s.categories {
+		if other.ID != c.ID && other.GroupID == c.GroupID && other.Name == c.Name {
+			return errors.AlreadyExistsf("category %q in group %d", c.Name, c.GroupID)
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) InsertCategory(c *models.Category) error {
+	if c.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.checkCategory(c)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting category")
+	}
+
+	c.ID = s.nextID()
+	s.categories[c.ID] = copyCategory(c)
+	return nil
+}
+
+func (s *memStore) UpdateCategory(c *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.categories[c.ID]
+	if !ok {
+		return errors.New("Invalid category ID")
+	}
+
+	// The group cannot be changed, as with the database.
+	updated := copyCategory(c)
+	updated.GroupID = old.GroupID
+
+	err := s.checkCategory(updated)
+	if err != nil {
+		return errors.Annotate(err, "Error updating category")
+	}
+
+	s.categories[c.ID] = updated
+	return nil
+}
+
+// DeleteCategory removes the category, unless any expenses or recurring
+// expenses use it.
+func (s *memStore) DeleteCategory(c *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[c.ID]; !ok {
+		return errors.New("Category does not exist")
+	}
+
+	for _, e := range s.expenses {
+		if e.CategoryID == c.ID {
+			return errors.Trace(models.ErrCategoryInUse)
+		}
+	}
+
+	for _, r := range s.recurring {
+		if r.CategoryID == c.ID {
+			return errors.Trace(models.ErrCategoryInUse)
+		}
+	}
+
+	delete(s.categories, c.ID)
+	c.ID = 0
+	return nil
+}
+
+func (s *memStore) CategoryByID(id int64) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, errors.NotFoundf("category with id %d", id)
+	}
+
+	return copyCategory(c), nil
+}
+
+func (s *memStore) CategoriesByGroup(g *models.Group) ([]*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var cs []*models.Category
+	for _, c := range s.categories {
+		if c.GroupID == g.ID {
+			cs = append(cs, copyCategory(c))
+		}
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Name == cs[j].Name {
+			return cs[i].ID < cs[j].ID
+		}
+		return cs[i].Name < cs[j].Name
+	})
+	return cs, nil
+}
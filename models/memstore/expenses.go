@@ -0,0 +1,707 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"sort"
+	"time"
+)
+
+func copyGroup(g *models.Group) *models.Group {
+	ret := *g
+	return &ret
+}
+
+func copyPayment(p *models.Payment) *models.Payment {
+	ret := *p
+	return &ret
+}
+
+// copyExpense copies the expense without any assignments.
+func copyExpense(e *models.Expense) *models.Expense {
+	ret := *e
+	ret.Assignments = nil
+	return &ret
+}
+
+func copyAssignment(ea *models.ExpenseAssignment) *models.ExpenseAssignment {
+	ret := *ea
+	return &ret
+}
+
+// sortGroups sorts groups by ID, the order they were created.
+func sortGroups(gs []*models.Group) {
+	sort.Slice(gs, func(i, j int) bool {
+		return gs[i].ID < gs[j].ID
+	})
+}
+
+func (s *memStore) InsertGroup(g *models.Group) error {
+	if g.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	if g.Currency == "" {
+		g.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g.ID = s.nextID()
+	s.groups[g.ID] = copyGroup(g)
+	return nil
+}
+
+func (s *memStore) UpdateGroup(g *models.Group) error {
+	if g.Currency == "" {
+		g.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[g.ID]; !ok {
+		return errors.New("Invalid group ID")
+	}
+
+	s.groups[g.ID] = copyGroup(g)
+	return nil
+}
+
+// DeleteGroup removes the group along with its memberships, categories,
+// expenses and recurring expenses. As with the database, a group that has
+// payments cannot be deleted.
+func (s *memStore) DeleteGroup(g *models.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[g.ID]; !ok {
+		return errors.New("No group deleted")
+	}
+
+	for _, p := range s.payments {
+		if p.GroupID == g.ID {
+			return errors.Errorf("Error deleting group %d: group has payments", g.ID)
+		}
+	}
+
+	for id, m := range s.groupsUsers {
+		if m.GroupID == g.ID {
+			delete(s.groupsUsers, id)
+		}
+	}
+
+	for id, e := range s.expenses {
+		if e.GroupID == g.ID {
+			s.deleteExpense(id)
+		}
+	}
+
+	for id, r := range s.recurring {
+		if r.GroupID == g.ID {
+			delete(s.recurring, id)
+		}
+	}
+
+	for id, c := range s.categories {
+		if c.GroupID == g.ID {
+			delete(s.categories, id)
+		}
+	}
+
+	delete(s.groups, g.ID)
+	g.ID = 0
+	return nil
+}
+
+func (s *memStore) GroupByID(id int64) (*models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, ok := s.groups[id]
+	if !ok {
+		return nil, errors.NotFoundf("group with id %d", id)
+	}
+
+	return copyGroup(g), nil
+}
+
+func (s *memStore) GroupsByUser(u *auth.User) ([]*models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []*models.Group
+	for _, m := range s.groupsUsers {
+		if m.UserID == u.ID {
+			groups = append(groups, copyGroup(s.groups[m.GroupID]))
+		}
+	}
+
+	sortGroups(groups)
+	return groups, nil
+}
+
+func (s *memStore) MembersByGroup(g *models.Group) ([]*models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []*models.Member
+	for _, m := range s.groupsUsers {
+		if m.GroupID != g.ID {
+			continue
+		}
+
+		u := s.users[m.UserID]
+		members = append(members, &models.Member{
+			ID:    u.ID,
+			Name:  u.Name,
+			Email: u.Email,
+			Admin: m.Admin,
+		})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Name == members[j].Name {
+			return members[i].ID < members[j].ID
+		}
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+func (s *memStore) AllGroups() ([]*models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []*models.Group
+	for _, g := range s.groups {
+		groups = append(groups, copyGroup(g))
+	}
+
+	sortGroups(groups)
+	return groups, nil
+}
+
+func (s *memStore) AddUserToGroup(g *models.Group, u *auth.User, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[g.ID]; !ok {
+		return errors.NotFoundf("group with id %d", g.ID)
+	}
+
+	if _, ok := s.users[u.ID]; !ok {
+		return errors.NotFoundf("user with id %d", u.ID)
+	}
+
+	if s.membership(g.ID, u.ID) != nil {
+		return errors.Errorf("User %d already in group %d", u.ID, g.ID)
+	}
+
+	id := s.nextID()
+	s.groupsUsers[id] = &models.UserGroupMap{
+		ID:      id,
+		GroupID: g.ID,
+		UserID:  u.ID,
+		Admin:   admin,
+	}
+	return nil
+}
+
+func (s *memStore) RemoveUserFromGroup(g *models.Group, u *auth.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.membership(g.ID, u.ID)
+	if m == nil {
+		return errors.New("User not in group")
+	}
+
+	delete(s.groupsUsers, m.ID)
+	return nil
+}
+
+// membership returns the mapping between the group and user, or nil if the
+// user is not a member of the group.
+func (s *memStore) membership(groupID, userID int64) *models.UserGroupMap {
+	for _, m := range s.groupsUsers {
+		if m.GroupID == groupID && m.UserID == userID {
+			return m
+		}
+	}
+	return nil
+}
+
+// checkPayment enforces the constraints of the payments table.
+func (s *memStore) checkPayment(p *models.Payment) error {
+	if p.Amount < 0 {
+		return models.ErrNegativePence
+	}
+
+	if p.GiverID == p.ReceiverID {
+		return models.ErrPaymentToSelf
+	}
+
+	if _, ok := s.users[p.GiverID]; !ok {
+		return errors.NotFoundf("giver with id %d", p.GiverID)
+	}
+
+	if _, ok := s.users[p.ReceiverID]; !ok {
+		return errors.NotFoundf("receiver with id %d", p.ReceiverID)
+	}
+
+	if _, ok := s.groups[p.GroupID]; !ok {
+		return errors.NotFoundf("group with id %d", p.GroupID)
+	}
+
+	return nil
+}
+
+// insertPayment saves the payment, created at the time given. The lock must
+// be held.
+func (s *memStore) insertPayment(p *models.Payment, createdAt time.Time) error {
+	if p.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	if p.Currency == "" {
+		p.Currency = models.DefaultCurrency
+	}
+
+	err := s.checkPayment(p)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting payment")
+	}
+
+	p.ID = s.nextID()
+	p.CreatedAt = createdAt
+	s.payments[p.ID] = copyPayment(p)
+	return nil
+}
+
+func (s *memStore) InsertPayment(p *models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertPayment(p, now())
+}
+
+func (s *memStore) InsertPayments(ps []*models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertPayments(ps)
+}
+
+// insertPayments saves all of the payments or none of them. The lock must be
+// held.
+func (s *memStore) insertPayments(ps []*models.Payment) error {
+	for i, p := range ps {
+		err := s.insertPayment(p, now())
+		if err != nil {
+			// Roll back the payments already inserted
+			for _, saved := range ps[:i] {
+				delete(s.payments, saved.ID)
+				saved.ID = 0
+			}
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) UpdatePayment(p *models.Payment) error {
+	if p.Currency == "" {
+		p.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.payments[p.ID]
+	if !ok {
+		return errors.New("No payment with ID")
+	}
+
+	err := s.checkPayment(p)
+	if err != nil {
+		return errors.Annotate(err, "Could not update payment")
+	}
+
+	updated := copyPayment(p)
+	updated.CreatedAt = old.CreatedAt
+	s.payments[p.ID] = updated
+	return nil
+}
+
+func (s *memStore) DeletePayment(p *models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.payments[p.ID]; !ok {
+		return errors.New("Payment does not exist")
+	}
+
+	delete(s.payments, p.ID)
+	return nil
+}
+
+func (s *memStore) PaymentByID(id int64) (*models.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return nil, errors.NotFoundf("payment with id %d", id)
+	}
+
+	return copyPayment(p), nil
+}
+
+func (s *memStore) PaymentsByGroup(g *models.Group) ([]*models.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.paymentsByGroup(g), nil
+}
+
+// paymentsByGroup copies the payments of the group. The lock must be held.
+func (s *memStore) paymentsByGroup(g *models.Group) []*models.Payment {
+	var ps []*models.Payment
+	for _, p := range s.payments {
+		if p.GroupID == g.ID {
+			ps = append(ps, copyPayment(p))
+		}
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].ID < ps[j].ID
+	})
+	return ps
+}
+
+// SettleGroup holds the lock while planning, so nothing can be added to the
+// group until the payments are inserted.
+func (s *memStore) SettleGroup(g *models.Group, plan func([]*models.Expense, []*models.Payment) ([]*models.Payment, error)) ([]*models.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps, err := plan(s.expensesByGroup(g), s.paymentsByGroup(g))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return ps, errors.Trace(s.insertPayments(ps))
+}
+
+// checkExpense enforces the foreign keys of the expenses table.
+func (s *memStore) checkExpense(e *models.Expense) error {
+	if _, ok := s.groups[e.GroupID]; !ok {
+		return errors.NotFoundf("group with id %d", e.GroupID)
+	}
+
+	if _, ok := s.users[e.PayerID]; !ok {
+		return errors.NotFoundf("payer with id %d", e.PayerID)
+	}
+
+	if _, ok := s.categories[e.CategoryID]; !ok {
+		return errors.NotFoundf("category with id %d", e.CategoryID)
+	}
+
+	return nil
+}
+
+// assign divides the expense up using the split. The expense must have an
+// ID. Nothing is saved.
+func (s *memStore) assign(e *models.Expense, split models.Split) ([]*models.ExpenseAssignment, error) {
+	eas, err := e.Assign(split, s.roundingHistory(e, split))
+	if err != nil {
+		return nil, errors.Annotate(err, "Error assigning expense")
+	}
+
+	for _, ea := range eas {
+		if _, ok := s.users[ea.UserID]; !ok {
+			return nil, errors.NotFoundf("assigned user with id %d", ea.UserID)
+		}
+	}
+
+	return eas, nil
+}
+
+// saveAssignments stores the assignments, replacing any existing assignments
+// of the expense.
+func (s *memStore) saveAssignments(e *models.Expense, eas []*models.ExpenseAssignment) {
+	s.deleteAssignments(e.ID)
+	for _, ea := range eas {
+		ea.ID = s.nextID()
+		s.assignments[ea.ID] = copyAssignment(ea)
+	}
+	e.Assignments = eas
+}
+
+func (s *memStore) InsertExpense(e *models.Expense, split models.Split) error {
+	if e.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return errors.Trace(s.insertExpense(e, split, now()))
+}
+
+// InsertHistory inserts all of the expenses and payments, keeping the time
+// each was created, or none of them.
+func (s *memStore) InsertHistory(es []*models.Expense, splits []models.Split, ps []*models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return errors.Trace(s.insertHistory(es, splits, ps))
+}
+
+// ImportExpenses holds the lock while planning, so nothing can be added to the
+// group before the expenses from the plan have been inserted.
+func (s *memStore) ImportExpenses(g *models.Group, plan func([]*models.Expense) ([]*models.Expense, []models.Split, error)) ([]*models.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es, splits, err := plan(s.expensesByGroup(g))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return es, errors.Trace(s.insertHistory(es, splits, nil))
+}
+
+// insertHistory inserts all of the expenses and payments, keeping the time
+// each was created, or none of them. The lock must be held.
+func (s *memStore) insertHistory(es []*models.Expense, splits []models.Split, ps []*models.Payment) error {
+	if len(es) != len(splits) {
+		return errors.Errorf("%d expenses but %d splits", len(es), len(splits))
+	}
+
+	for _, e := range es {
+		if e.ID != 0 {
+			return models.ErrAlreadySaved
+		}
+	}
+
+	// Roll back everything inserted so far
+	rollback := func(es []*models.Expense, ps []*models.Payment) {
+		for _, e := range es {
+			s.deleteAssignments(e.ID)
+			delete(s.expenses, e.ID)
+			e.ID = 0
+			e.Assignments = nil
+		}
+
+		for _, p := range ps {
+			delete(s.payments, p.ID)
+			p.ID = 0
+		}
+	}
+
+	for i, e := range es {
+		err := s.insertExpense(e, splits[i], e.CreatedAt)
+		if err != nil {
+			rollback(es[:i], nil)
+			return errors.Trace(err)
+		}
+	}
+
+	for i, p := range ps {
+		err := s.insertPayment(p, p.CreatedAt)
+		if err != nil {
+			rollback(es, ps[:i])
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// insertExpense saves and assigns the expense, created at the time given.
+// The lock must be held.
+func (s *memStore) insertExpense(e *models.Expense, split models.Split, createdAt time.Time) error {
+	if e.Currency == "" {
+		e.Currency = models.DefaultCurrency
+	}
+
+	err := s.checkExpense(e)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting expense")
+	}
+
+	// The expense needs an ID to be assigned, which is only kept if the
+	// assignment succeeds.
+	e.ID = s.lastID + 1
+	eas, err := s.assign(e, split)
+	if err != nil {
+		e.ID = 0
+		return errors.Trace(err)
+	}
+
+	e.ID = s.nextID()
+	e.CreatedAt = createdAt
+	s.expenses[e.ID] = copyExpense(e)
+	s.saveAssignments(e, eas)
+	return nil
+}
+
+func (s *memStore) UpdateExpense(e *models.Expense, split models.Split) error {
+	if e.ID == 0 {
+		return models.ErrStructNotSaved
+	}
+
+	if e.Currency == "" {
+		e.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.expenses[e.ID]
+	if !ok {
+		return errors.New("expense does not have any associated assignments")
+	}
+
+	err := s.checkExpense(e)
+	if err != nil {
+		return errors.Annotate(err, "Error updating expense")
+	}
+
+	eas, err := s.assign(e, split)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// As with the database, the creation time and import ID are not updated
+	updated := copyExpense(e)
+	updated.CreatedAt = old.CreatedAt
+	updated.ImportID = old.ImportID
+	s.expenses[e.ID] = updated
+	s.saveAssignments(e, eas)
+	return nil
+}
+
+// roundingHistory totals the pennies from rounding assigned to each user in
+// the group of the expense, ignoring the expense itself. This is only needed
+// when the split uses the round robin remainder policy.
+func (s *memStore) roundingHistory(e *models.Expense, split models.Split) models.Rounding {
+	if split.Remainder != models.RemainderRoundRobin {
+		return nil
+	}
+
+	history := make(models.Rounding)
+	for _, ea := range s.assignments {
+		if ea.GroupID == e.GroupID && ea.ExpenseID != e.ID {
+			history[ea.UserID] += ea.Rounding
+		}
+	}
+	return history
+}
+
+// expenseAssignments returns copies of the assignments of the expense, in the
+// order they were created.
+func (s *memStore) expenseAssignments(id int64) []*models.ExpenseAssignment {
+	eas := make([]*models.ExpenseAssignment, 0)
+	for _, ea := range s.assignments {
+		if ea.ExpenseID == id {
+			eas = append(eas, copyAssignment(ea))
+		}
+	}
+
+	sort.Slice(eas, func(i, j int) bool {
+		return eas[i].ID < eas[j].ID
+	})
+	return eas
+}
+
+func (s *memStore) ExpenseByID(id int64) (*models.Expense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.expenses[id]
+	if !ok {
+		return nil, errors.NotFoundf("expense with id %d", id)
+	}
+
+	ret := copyExpense(e)
+	ret.Assignments = s.expenseAssignments(id)
+	return ret, nil
+}
+
+func (s *memStore) ExpensesByGroup(g *models.Group) ([]*models.Expense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.expensesByGroup(g), nil
+}
+
+// expensesByGroup copies the expenses of the group, with their assignments,
+// ordered by creation. The lock must be held.
+func (s *memStore) expensesByGroup(g *models.Group) []*models.Expense {
+	return s.expensesWhere(g, nil, func(a, b *models.Expense) bool {
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID < b.ID
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// expensesWhere copies the expenses of the group that match, with their
+// assignments, ordered by less. The assignments are filled in before match
+// is called, and a nil match matches every expense. The lock must be held.
+func (s *memStore) expensesWhere(g *models.Group, match func(*models.Expense) bool, less func(a, b *models.Expense) bool) []*models.Expense {
+	var es []*models.Expense
+	for _, e := range s.expenses {
+		if e.GroupID != g.ID {
+			continue
+		}
+
+		ret := copyExpense(e)
+		ret.Assignments = s.expenseAssignments(e.ID)
+		if match == nil || match(ret) {
+			es = append(es, ret)
+		}
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		return less(es[i], es[j])
+	})
+	return es
+}
+
+func (s *memStore) DeleteExpense(e *models.Expense) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.expenses[e.ID]; !ok {
+		return errors.New("Expense does not exist")
+	}
+
+	s.deleteExpense(e.ID)
+	e.ID = 0
+	return nil
+}
+
+// deleteExpense removes an expense, its assignments and its attachments, the
+// lock must be held by the caller.
+func (s *memStore) deleteExpense(id int64) {
+	s.deleteAssignments(id)
+	s.deleteAttachments(id)
+	delete(s.expenses, id)
+}
+
+func (s *memStore) deleteAssignments(expenseID int64) {
+	for id, ea := range s.assignments {
+		if ea.ExpenseID == expenseID {
+			delete(s.assignments, id)
+		}
+	}
+}
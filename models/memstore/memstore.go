@@ -0,0 +1,66 @@
+// Package memstore is an in memory implementation of models.Storer and
+// auth.Storer. It follows the same rules as the database backed stores, such
+// as unique emails and cascading deletes, but nothing is persisted. It is
+// intended for tests and for running the server as a demo.
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"sync"
+	"time"
+)
+
+var (
+	_ models.Storer = (*memStore)(nil)
+	_ auth.Storer   = (*memStore)(nil)
+)
+
+// memStore holds copies of every struct saved. Structs are copied on the way
+// in and on the way out so that callers can only change what is stored by
+// calling the store's methods. A single lock guards everything, which makes
+// every method transactional.
+type memStore struct {
+	mu sync.RWMutex
+
+	lastID int64
+
+	users       map[int64]*auth.User
+	groups      map[int64]*models.Group
+	groupsUsers map[int64]*models.UserGroupMap
+	categories  map[int64]*models.Category
+	expenses    map[int64]*models.Expense
+	assignments map[int64]*models.ExpenseAssignment
+	payments    map[int64]*models.Payment
+	recurring   map[int64]*models.RecurringExpense
+	attachments map[int64]*models.Attachment
+}
+
+// New creates an empty in memory store.
+func New() *memStore {
+	return &memStore{
+		users:       make(map[int64]*auth.User),
+		groups:      make(map[int64]*models.Group),
+		groupsUsers: make(map[int64]*models.UserGroupMap),
+		categories:  make(map[int64]*models.Category),
+		expenses:    make(map[int64]*models.Expense),
+		assignments: make(map[int64]*models.ExpenseAssignment),
+		payments:    make(map[int64]*models.Payment),
+		recurring:   make(map[int64]*models.RecurringExpense),
+		attachments: make(map[int64]*models.Attachment),
+	}
+}
+
+// nextID returns a new ID. IDs are unique across all of the tables, which
+// helps catch an ID of one kind being used as another.
+func (s *memStore) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// now returns the time used for created_at columns, which the database stores
+// without a time zone.
+func now() time.Time {
+	return time.Now().UTC()
+}
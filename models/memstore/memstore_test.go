@@ -0,0 +1,474 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"sync"
+	"testing"
+	"time"
+)
+
+// mustUsers inserts users with the emails given.
+func mustUsers(t *testing.T, st *memStore, emails ...string) []*auth.User {
+	var us []*auth.User
+	for _, email := range emails {
+		u := &auth.User{Email: email, PwHash: "hash", Name: "TEST"}
+		err := st.Insert(u)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+		}
+		us = append(us, u)
+	}
+	return us
+}
+
+// mustGroup inserts a group with the users given as members.
+func mustGroup(t *testing.T, st *memStore, us ...*auth.User) *models.Group {
+	g := &models.Group{Name: "Test group"}
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+	}
+
+	for _, u := range us {
+		err = st.AddUserToGroup(g, u, false)
+		if err != nil {
+			t.Fatalf("Error adding user to group: %v", err)
+		}
+	}
+	return g
+}
+
+// mustCategory inserts a category into the group.
+func mustCategory(t *testing.T, st *memStore, g *models.Group, name string) *models.Category {
+	c := &models.Category{GroupID: g.ID, Name: name, Colour: models.DefaultColour}
+	err := st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+	}
+	return c
+}
+
+func TestUserCrud(t *testing.T) {
+	st := New()
+	u := mustUsers(t, st, "hello@example.com")[0]
+	if u.ID == 0 || u.CreatedAt == nil {
+		t.Fatalf("Expected ID and creation time to be set, got %+v", u)
+	}
+
+	err := st.Insert(u)
+	if err != auth.ErrAlreadySaved {
+		t.Fatalf("Expected ErrAlreadySaved inserting user twice, got %v", err)
+	}
+
+	err = st.Insert(&auth.User{Email: u.Email, Name: "Other"})
+	if err == nil {
+		t.Fatalf("Expected error inserting user with duplicate email")
+	}
+
+	u.Token = "TOKEN"
+	err = st.Update(u)
+	if err != nil {
+		t.Fatalf("Error updating user: %v", err)
+	}
+
+	u2, err := st.UserByToken("TOKEN")
+	if err != nil || u2.ID != u.ID {
+		t.Fatalf("Expected user %d by token, got %+v, %v", u.ID, u2, err)
+	}
+
+	u2.Name = "Changed"
+	u3, _ := st.UserByEmail(u.Email)
+	if u3.Name != "TEST" {
+		t.Fatalf("Changing a retrieved user changed the store")
+	}
+
+	err = st.Delete(u)
+	if err != nil {
+		t.Fatalf("Error deleting user: %v", err)
+	}
+
+	_, err = st.UserByID(u3.ID)
+	if err == nil {
+		t.Fatalf("Expected error getting deleted user")
+	}
+}
+
+func TestExpenseCrud(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com", "u2@example.com")
+	u1, u2 := us[0], us[1]
+	g := mustGroup(t, st, u1, u2)
+	c := mustCategory(t, st, g, "Drugs")
+
+	e := &models.Expense{
+		CategoryID:  c.ID,
+		Amount:      101,
+		GroupID:     g.ID,
+		Description: "Test expense",
+		PayerID:     u1.ID,
+	}
+
+	err := st.InsertExpense(e, models.EqualSplit([]int64{u1.ID, u2.ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+	}
+
+	if len(e.Assignments) != 2 || e.Assignments[0].Amount+e.Assignments[1].Amount != 101 {
+		t.Fatalf("Expected expense to be assigned in full, got %+v", e.Assignments)
+	}
+
+	err = st.UpdateExpense(e, models.EqualSplit([]int64{u1.ID}))
+	if err != nil {
+		t.Fatalf("Error updating expense: %v", err)
+	}
+
+	e2, err := st.ExpenseByID(e.ID)
+	if err != nil {
+		t.Fatalf("Error getting expense: %v", err)
+	}
+
+	if len(e2.Assignments) != 1 || e2.Assignments[0].Amount != 101 {
+		t.Fatalf("Expected 1 assignment of 101, got %+v", e2.Assignments)
+	}
+
+	id := e.ID
+	err = st.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+	}
+
+	err = st.DeleteExpense(&models.Expense{ID: id})
+	if err == nil {
+		t.Fatalf("Expected error deleting deleted expense")
+	}
+
+	if len(st.assignments) != 0 {
+		t.Fatalf("Expected assignments to be deleted with expense, %d remain", len(st.assignments))
+	}
+}
+
+func TestInsertExpenseFailure(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com")
+	g := mustGroup(t, st, us[0])
+	c := mustCategory(t, st, g, "Bills")
+
+	e := &models.Expense{
+		CategoryID: c.ID,
+		Amount:     100,
+		GroupID:    g.ID,
+		PayerID:    us[0].ID,
+	}
+
+	// The second user does not exist, so nothing should be saved
+	err := st.InsertExpense(e, models.EqualSplit([]int64{us[0].ID, 1000}))
+	if err == nil {
+		t.Fatalf("Expected error assigning expense to missing user")
+	}
+
+	if e.ID != 0 || len(st.expenses) != 0 || len(st.assignments) != 0 {
+		t.Fatalf("Expected nothing saved, got expense %d, %d expenses and %d assignments",
+			e.ID, len(st.expenses), len(st.assignments))
+	}
+}
+
+func TestCategoryCrud(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com")
+	g := mustGroup(t, st, us[0])
+	c := mustCategory(t, st, g, "Bills")
+
+	err := st.InsertCategory(&models.Category{GroupID: g.ID, Name: "Bills"})
+	if err == nil {
+		t.Fatalf("Expected error inserting category with duplicate name")
+	}
+
+	c.Name = "Utilities"
+	c.GroupID = 1000
+	err = st.UpdateCategory(c)
+	if err != nil {
+		t.Fatalf("Error updating category: %v", err)
+	}
+
+	cs, _ := st.CategoriesByGroup(g)
+	if len(cs) != 1 || cs[0].Name != "Utilities" || cs[0].GroupID != g.ID {
+		t.Fatalf("Expected category renamed without changing group, got %+v", cs)
+	}
+
+	e := &models.Expense{CategoryID: c.ID, Amount: 100, GroupID: g.ID, PayerID: us[0].ID}
+	err = st.InsertExpense(e, models.EqualSplit([]int64{us[0].ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+	}
+
+	err = st.DeleteCategory(c)
+	if errors.Cause(err) != models.ErrCategoryInUse {
+		t.Fatalf("Expected ErrCategoryInUse deleting used category, got %v", err)
+	}
+
+	err = st.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+	}
+
+	err = st.DeleteCategory(c)
+	if err != nil {
+		t.Fatalf("Error deleting category: %v", err)
+	}
+}
+
+func TestInsertPaymentsAllOrNone(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com", "u2@example.com")
+	g := mustGroup(t, st, us...)
+
+	ps := []*models.Payment{
+		{GroupID: g.ID, GiverID: us[0].ID, ReceiverID: us[1].ID, Amount: 100},
+		{GroupID: g.ID, GiverID: us[0].ID, ReceiverID: us[0].ID, Amount: 100},
+	}
+
+	err := st.InsertPayments(ps)
+	if err == nil {
+		t.Fatalf("Expected error inserting payment to self")
+	}
+
+	saved, _ := st.PaymentsByGroup(g)
+	if len(saved) != 0 || ps[0].ID != 0 {
+		t.Fatalf("Expected no payments saved, got %d", len(saved))
+	}
+
+	err = st.InsertPayments(ps[:1])
+	if err != nil {
+		t.Fatalf("Error inserting payment: %v", err)
+	}
+
+	saved, _ = st.PaymentsByGroup(g)
+	if len(saved) != 1 {
+		t.Fatalf("Expected 1 payment, got %d", len(saved))
+	}
+}
+
+func TestInsertHistoryAllOrNone(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com", "u2@example.com")
+	g := mustGroup(t, st, us...)
+	c := mustCategory(t, st, g, "Bills")
+	equal := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	exact := models.Split{Mode: models.SplitExact, Shares: []models.Share{{UserID: us[1].ID, Amount: 100}}}
+	splits := []models.Split{equal, exact}
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	es := []*models.Expense{
+		{GroupID: g.ID, PayerID: us[0].ID, CategoryID: c.ID, Amount: 100, CreatedAt: date},
+		{GroupID: g.ID, PayerID: us[0].ID, CategoryID: c.ID, Amount: 100, CreatedAt: date},
+	}
+	ps := []*models.Payment{
+		{GroupID: g.ID, GiverID: us[1].ID, ReceiverID: us[1].ID, Amount: 150, CreatedAt: date},
+	}
+
+	err := st.InsertHistory(es, splits, ps)
+	if err == nil {
+		t.Fatalf("Expected error inserting payment to self")
+	}
+
+	saved, _ := st.ExpensesByGroup(g)
+	if len(saved) != 0 || es[0].ID != 0 || len(st.assignments) != 0 {
+		t.Fatalf("Expected no expenses saved, got %d", len(saved))
+	}
+
+	ps[0].ReceiverID = us[0].ID
+	err = st.InsertHistory(es, splits, ps)
+	if err != nil {
+		t.Fatalf("Error inserting history: %v", err)
+	}
+
+	saved, _ = st.ExpensesByGroup(g)
+	if len(saved) != 2 || !saved[0].CreatedAt.Equal(date) || len(saved[0].Assignments) != 2 || len(saved[1].Assignments) != 1 {
+		t.Fatalf("Expected 2 expenses created at %s assigned by their splits, got %+v", date, saved)
+	}
+
+	payments, _ := st.PaymentsByGroup(g)
+	if len(payments) != 1 || !payments[0].CreatedAt.Equal(date) {
+		t.Fatalf("Expected 1 payment created at %s, got %+v", date, payments)
+	}
+}
+
+func TestDeleteGroupCascades(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com", "u2@example.com")
+	g := mustGroup(t, st, us...)
+	c := mustCategory(t, st, g, "Bills")
+
+	err := st.InsertExpense(&models.Expense{
+		CategoryID: c.ID,
+		Amount:     100,
+		GroupID:    g.ID,
+		PayerID:    us[0].ID,
+	}, models.EqualSplit([]int64{us[0].ID, us[1].ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+	}
+
+	err = st.InsertRecurringExpense(&models.RecurringExpense{
+		GroupID:    g.ID,
+		PayerID:    us[0].ID,
+		Amount:     100,
+		CategoryID: c.ID,
+		Split:      models.EqualSplit([]int64{us[0].ID}),
+		Day:        1,
+		NextDue:    time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Error inserting recurring expense: %v", err)
+	}
+
+	err = st.DeleteGroup(g)
+	if err != nil {
+		t.Fatalf("Error deleting group: %v", err)
+	}
+
+	if len(st.groupsUsers) != 0 || len(st.expenses) != 0 || len(st.assignments) != 0 ||
+		len(st.recurring) != 0 || len(st.categories) != 0 {
+		t.Fatalf("Expected everything in the group to be deleted")
+	}
+
+	groups, _ := st.GroupsByUser(us[0])
+	if len(groups) != 0 {
+		t.Fatalf("Expected user to have no groups, got %d", len(groups))
+	}
+}
+
+func TestInsertDueExpenseOnce(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com")
+	g := mustGroup(t, st, us[0])
+	c := mustCategory(t, st, g, "Bills")
+
+	due := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := &models.RecurringExpense{
+		GroupID:    g.ID,
+		PayerID:    us[0].ID,
+		Amount:     100,
+		CategoryID: c.ID,
+		Split:      models.EqualSplit([]int64{us[0].ID}),
+		Day:        1,
+		NextDue:    due,
+	}
+	err := st.InsertRecurringExpense(r)
+	if err != nil {
+		t.Fatalf("Error inserting recurring expense: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	claimed := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copied := *r
+			e := &models.Expense{GroupID: g.ID, PayerID: us[0].ID, Amount: 100, CategoryID: c.ID, CreatedAt: due}
+			ok, err := st.InsertDueExpense(&copied, due.AddDate(0, 1, 0), e)
+			if err != nil {
+				t.Errorf("Error inserting due expense: %v", err)
+			}
+			claimed <- ok
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	n := 0
+	for ok := range claimed {
+		if ok {
+			n++
+		}
+	}
+
+	if n != 1 || len(st.expenses) != 1 {
+		t.Fatalf("Expected the due expense to be inserted once, inserted %d times", n)
+	}
+}
+
+func TestSpending(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com", "u2@example.com")
+	g := mustGroup(t, st, us...)
+	c := mustCategory(t, st, g, "Bills")
+
+	e := &models.Expense{CategoryID: c.ID, Amount: 301, Currency: models.GBP, ExchangeRate: 1, GroupID: g.ID, PayerID: us[0].ID}
+	err := st.InsertExpense(e, models.EqualSplit([]int64{us[0].ID, us[1].ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+	}
+	st.expenses[e.ID].CreatedAt = time.Date(2016, 3, 31, 23, 59, 0, 0, time.UTC)
+
+	from := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	ts, _ := st.SpendingByPayer(g, from, from.AddDate(0, 1, 0))
+	if len(ts) != 1 || ts[0].Month != "2016-03" || ts[0].UserID != us[0].ID || ts[0].Amount != 301 {
+		t.Fatalf("Expected 301 paid by user %d in March, got %+v", us[0].ID, ts)
+	}
+
+	ts, _ = st.SpendingByAssignee(g, from, from.AddDate(0, 1, 0))
+	if len(ts) != 2 || ts[0].Amount+ts[1].Amount != 301 || ts[0].UserID >= ts[1].UserID {
+		t.Fatalf("Expected 301 assigned to both users in order, got %+v", ts)
+	}
+
+	ts, _ = st.SpendingByPayer(g, from.AddDate(0, 1, 0), from.AddDate(0, 2, 0))
+	if len(ts) != 0 {
+		t.Fatalf("Expected no spending in April, got %+v", ts)
+	}
+}
+
+func TestAttachmentCrud(t *testing.T) {
+	st := New()
+	us := mustUsers(t, st, "u1@example.com")
+	g := mustGroup(t, st, us[0])
+	c := mustCategory(t, st, g, "Bills")
+
+	e := &models.Expense{CategoryID: c.ID, Amount: 100, GroupID: g.ID, PayerID: us[0].ID}
+	err := st.InsertExpense(e, models.EqualSplit([]int64{us[0].ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+	}
+
+	err = st.InsertAttachment(&models.Attachment{ExpenseID: e.ID + 1000, Key: "missing"})
+	if err == nil {
+		t.Fatalf("Expected error attaching to a missing expense")
+	}
+
+	for _, key := range []string{"a", "b"} {
+		a := &models.Attachment{ExpenseID: e.ID, Filename: key + ".png", ContentType: "image/png", Size: 1, Key: key}
+		err = st.InsertAttachment(a)
+		if err != nil {
+			t.Fatalf("Error inserting attachment: %v", err)
+		}
+	}
+
+	err = st.InsertAttachment(&models.Attachment{ExpenseID: e.ID, Key: "a"})
+	if err == nil {
+		t.Fatalf("Expected error inserting attachment with a duplicate key")
+	}
+
+	as, err := st.AttachmentsByExpense(e)
+	if err != nil || len(as) != 2 || as[0].Key != "a" {
+		t.Fatalf("Expected 2 attachments in order, got %+v, %v", as, err)
+	}
+
+	err = st.DeleteAttachment(as[0])
+	if err != nil {
+		t.Fatalf("Error deleting attachment: %v", err)
+	}
+
+	err = st.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+	}
+
+	if len(st.attachments) != 0 {
+		t.Fatalf("Expected attachments to be deleted with expense, %d remain", len(st.attachments))
+	}
+}
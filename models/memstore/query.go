@@ -0,0 +1,16 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+)
+
+func (s *memStore) ExpensesByQuery(g *models.Group, q models.ExpenseQuery) ([]*models.Expense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	es := s.expensesWhere(g, q.Matches, q.Before)
+	if q.Limit > 0 && len(es) > q.Limit {
+		es = es[:q.Limit]
+	}
+	return es, nil
+}
@@ -0,0 +1,171 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"sort"
+	"time"
+)
+
+func copyRecurringExpense(r *models.RecurringExpense) *models.RecurringExpense {
+	ret := *r
+	ret.Split.Shares = append([]models.Share(nil), r.Split.Shares...)
+	return &ret
+}
+
+// sortByNextDue sorts recurring expenses by the date they are next due.
+func sortByNextDue(rs []*models.RecurringExpense) {
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].NextDue.Equal(rs[j].NextDue) {
+			return rs[i].ID < rs[j].ID
+		}
+		return rs[i].NextDue.Before(rs[j].NextDue)
+	})
+}
+
+// checkRecurringExpense enforces the foreign keys of the recurring expenses
+// table.
+func (s *memStore) checkRecurringExpense(r *models.RecurringExpense) error {
+	if _, ok := s.groups[r.GroupID]; !ok {
+		return errors.NotFoundf("group with id %d", r.GroupID)
+	}
+
+	if _, ok := s.users[r.PayerID]; !ok {
+		return errors.NotFoundf("payer with id %d", r.PayerID)
+	}
+
+	if _, ok := s.categories[r.CategoryID]; !ok {
+		return errors.NotFoundf("category with id %d", r.CategoryID)
+	}
+
+	return nil
+}
+
+func (s *memStore) InsertRecurringExpense(r *models.RecurringExpense) error {
+	if r.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	if r.Currency == "" {
+		r.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.checkRecurringExpense(r)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting recurring expense")
+	}
+
+	r.ID = s.nextID()
+	r.CreatedAt = now()
+	s.recurring[r.ID] = copyRecurringExpense(r)
+	return nil
+}
+
+func (s *memStore) UpdateRecurringExpense(r *models.RecurringExpense) error {
+	if r.Currency == "" {
+		r.Currency = models.DefaultCurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.recurring[r.ID]
+	if !ok {
+		return errors.New("Invalid recurring expense ID")
+	}
+
+	err := s.checkRecurringExpense(r)
+	if err != nil {
+		return errors.Annotate(err, "Error updating recurring expense")
+	}
+
+	// The group cannot be changed, as with the database.
+	updated := copyRecurringExpense(r)
+	updated.GroupID = old.GroupID
+	updated.CreatedAt = old.CreatedAt
+	s.recurring[r.ID] = updated
+	return nil
+}
+
+func (s *memStore) DeleteRecurringExpense(r *models.RecurringExpense) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recurring[r.ID]; !ok {
+		return errors.New("Recurring expense does not exist")
+	}
+
+	delete(s.recurring, r.ID)
+	r.ID = 0
+	return nil
+}
+
+func (s *memStore) RecurringExpenseByID(id int64) (*models.RecurringExpense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.recurring[id]
+	if !ok {
+		return nil, errors.NotFoundf("recurring expense with id %d", id)
+	}
+
+	return copyRecurringExpense(r), nil
+}
+
+func (s *memStore) RecurringExpensesByGroup(g *models.Group) ([]*models.RecurringExpense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rs []*models.RecurringExpense
+	for _, r := range s.recurring {
+		if r.GroupID == g.ID {
+			rs = append(rs, copyRecurringExpense(r))
+		}
+	}
+
+	sortByNextDue(rs)
+	return rs, nil
+}
+
+func (s *memStore) DueRecurringExpenses(t time.Time) ([]*models.RecurringExpense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rs []*models.RecurringExpense
+	for _, r := range s.recurring {
+		if !r.NextDue.After(t) {
+			rs = append(rs, copyRecurringExpense(r))
+		}
+	}
+
+	sortByNextDue(rs)
+	return rs, nil
+}
+
+func (s *memStore) InsertDueExpense(r *models.RecurringExpense, next time.Time, e *models.Expense) (bool, error) {
+	if e.ID != 0 {
+		return false, models.ErrAlreadySaved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.recurring[r.ID]
+	if !ok || !stored.NextDue.Equal(r.NextDue) {
+		return false, nil
+	}
+
+	err := s.insertExpense(e, r.Split, e.CreatedAt)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	stored.NextDue = next
+	r.NextDue = next
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"sort"
+	"time"
+)
+
+// spendingKey is what the totals are grouped by, as in the GROUP BY of the
+// database queries.
+type spendingKey struct {
+	month    string
+	category int64
+	user     int64
+	currency string
+}
+
+// spending holds the total for each key.
+type spending map[spendingKey]*models.SpendingTotal
+
+func (sp spending) add(e *models.Expense, user int64, amount models.Pence) {
+	k := spendingKey{e.CreatedAt.UTC().Format(models.ReportMonthFormat), e.CategoryID, user, e.Currency}
+	t, ok := sp[k]
+	if !ok {
+		t = &models.SpendingTotal{Month: k.month, CategoryID: k.category, UserID: k.user, Currency: k.currency}
+		sp[k] = t
+	}
+
+	t.Amount += float64(amount) * e.ExchangeRate
+	t.Count++
+}
+
+// totals returns the totals ordered by their keys.
+func (sp spending) totals() []*models.SpendingTotal {
+	ts := make([]*models.SpendingTotal, 0, len(sp))
+	for _, t := range sp {
+		ts = append(ts, t)
+	}
+
+	sort.Slice(ts, func(i, j int) bool {
+		a, b := ts[i], ts[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.CategoryID != b.CategoryID {
+			return a.CategoryID < b.CategoryID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Currency < b.Currency
+	})
+	return ts
+}
+
+// expensesBetween returns the expenses of the group created from the first
+// time, inclusive, to the second, exclusive.
+func (s *memStore) expensesBetween(g *models.Group, from, to time.Time) map[int64]*models.Expense {
+	es := make(map[int64]*models.Expense)
+	for id, e := range s.expenses {
+		if e.GroupID == g.ID && !e.CreatedAt.Before(from) && e.CreatedAt.Before(to) {
+			es[id] = e
+		}
+	}
+	return es
+}
+
+func (s *memStore) SpendingByPayer(g *models.Group, from, to time.Time) ([]*models.SpendingTotal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sp := make(spending)
+	for _, e := range s.expensesBetween(g, from, to) {
+		sp.add(e, e.PayerID, e.Amount)
+	}
+
+	return sp.totals(), nil
+}
+
+func (s *memStore) SpendingByAssignee(g *models.Group, from, to time.Time) ([]*models.SpendingTotal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	es := s.expensesBetween(g, from, to)
+	sp := make(spending)
+	for _, ea := range s.assignments {
+		if e, ok := es[ea.ExpenseID]; ok {
+			sp.add(e, ea.UserID, ea.Amount)
+		}
+	}
+
+	return sp.totals(), nil
+}
+
+func (s *memStore) SpendingByCategory(c *models.Category, from, to time.Time) ([]*models.SpendingTotal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sp := make(spending)
+	for _, e := range s.expenses {
+		if e.CategoryID == c.ID && !e.CreatedAt.Before(from) && e.CreatedAt.Before(to) {
+			sp.add(e, e.PayerID, e.Amount)
+		}
+	}
+
+	return sp.totals(), nil
+}
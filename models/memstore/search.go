@@ -0,0 +1,17 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+)
+
+func (s *memStore) SearchExpenses(g *models.Group, q models.ExpenseSearch) ([]*models.Expense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.expensesWhere(g, q.Matches, func(a, b *models.Expense) bool {
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID > b.ID
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	}), nil
+}
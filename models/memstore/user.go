@@ -0,0 +1,177 @@
+package memstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+
+	"github.com/juju/errors"
+
+	"sort"
+)
+
+func copyUser(u *auth.User) *auth.User {
+	ret := *u
+	if u.CreatedAt != nil {
+		t := *u.CreatedAt
+		ret.CreatedAt = &t
+	}
+	return &ret
+}
+
+// checkUser enforces the constraints of the users table.
+func (s *memStore) checkUser(u *auth.User) error {
+	if u.Email == "" || u.Name == "" {
+		return errors.New("User must have an email and a name")
+	}
+
+	for _, other := range s.users {
+		if other.Email == u.Email && other.ID != u.ID {
+			return errors.Errorf("User with email %s already exists", u.Email)
+		}
+	}
+
+	return nil
+}
+
+func (s *memStore) Users() ([]*auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	us := make([]*auth.User, 0, len(s.users))
+	for _, u := range s.users {
+		us = append(us, copyUser(u))
+	}
+
+	sort.Slice(us, func(i, j int) bool {
+		return us[i].ID < us[j].ID
+	})
+	return us, nil
+}
+
+// Insert saves a new user
+func (s *memStore) Insert(u *auth.User) error {
+	if u.ID != 0 {
+		return auth.ErrAlreadySaved
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.checkUser(u)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting user")
+	}
+
+	t := now()
+	u.ID = s.nextID()
+	u.CreatedAt = &t
+	s.users[u.ID] = copyUser(u)
+	return nil
+}
+
+// Update updates a saved user
+func (s *memStore) Update(u *auth.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.users[u.ID]
+	if !ok {
+		return errors.NotFoundf("user with id %d", u.ID)
+	}
+
+	err := s.checkUser(u)
+	if err != nil {
+		return errors.Annotate(err, "Error updating user")
+	}
+
+	updated := copyUser(u)
+	updated.CreatedAt = old.CreatedAt
+	s.users[u.ID] = updated
+	return nil
+}
+
+// UserByToken retrieves a user by their unique token
+func (s *memStore) UserByToken(tok string) (*auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Token == tok {
+			return copyUser(u), nil
+		}
+	}
+
+	return nil, errors.NotFoundf("user with token %s", tok)
+}
+
+// UserByID retrieves a user by their ID
+func (s *memStore) UserByID(id int64) (*auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, errors.NotFoundf("user with id %d", id)
+	}
+
+	return copyUser(u), nil
+}
+
+// UserByEmail obtains a user by their email address
+func (s *memStore) UserByEmail(e string) (*auth.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == e {
+			return copyUser(u), nil
+		}
+	}
+
+	return nil, errors.NotFoundf("user %s", e)
+}
+
+// Delete removes a user along with their group memberships, the expenses they
+// paid and their expense assignments. As with the database, a user that has
+// made or received payments cannot be deleted.
+func (s *memStore) Delete(u *auth.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[u.ID]; !ok {
+		return errors.New("No user deleted")
+	}
+
+	for _, p := range s.payments {
+		if p.GiverID == u.ID || p.ReceiverID == u.ID {
+			return errors.Errorf("Could not delete user with id %d: user has payments", u.ID)
+		}
+	}
+
+	for id, m := range s.groupsUsers {
+		if m.UserID == u.ID {
+			delete(s.groupsUsers, id)
+		}
+	}
+
+	for id, e := range s.expenses {
+		if e.PayerID == u.ID {
+			s.deleteExpense(id)
+		}
+	}
+
+	for id, ea := range s.assignments {
+		if ea.UserID == u.ID {
+			delete(s.assignments, id)
+		}
+	}
+
+	for id, r := range s.recurring {
+		if r.PayerID == u.ID {
+			delete(s.recurring, id)
+		}
+	}
+
+	delete(s.users, u.ID)
+	u.ID = 0
+	return nil
+}
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GBP is the ISO 4217 code for Pounds Sterling. This is the currency
+	// used when no other currency is given.
+	GBP = "GBP"
+
+	// DefaultCurrency is the currency of expenses and payments that do
+	// not specify one.
+	DefaultCurrency = GBP
+)
+
+var (
+	// ErrUnknownCurrency is returned when a currency code is not a
+	// supported ISO 4217 code
+	ErrUnknownCurrency = errors.New("Unknown currency code")
+)
+
+// Currency describes an ISO 4217 currency. The exponent is the number of
+// decimal places of the minor unit e.g. 2 for GBP, as there are 100 pence in a
+// pound, and 0 for JPY.
+type Currency struct {
+	Code     string `json:"code"`
+	Exponent int    `json:"exponent"`
+	Symbol   string `json:"symbol"`
+}
+
+var currencies = map[string]Currency{
+	"AUD": {"AUD", 2, "A$"},
+	"BHD": {"BHD", 3, ""},
+	"CAD": {"CAD", 2, "C$"},
+	"CHF": {"CHF", 2, ""},
+	"CNY": {"CNY", 2, "¥"},
+	"CZK": {"CZK", 2, ""},
+	"DKK": {"DKK", 2, ""},
+	"EUR": {"EUR", 2, "€"},
+	"GBP": {"GBP", 2, "£"},
+	"HKD": {"HKD", 2, "HK$"},
+	"HUF": {"HUF", 2, ""},
+	"INR": {"INR", 2, "₹"},
+	"ISK": {"ISK", 0, ""},
+	"JPY": {"JPY", 0, "¥"},
+	"KRW": {"KRW", 0, "₩"},
+	"KWD": {"KWD", 3, ""},
+	"MXN": {"MXN", 2, ""},
+	"NOK": {"NOK", 2, ""},
+	"NZD": {"NZD", 2, "NZ$"},
+	"PLN": {"PLN", 2, ""},
+	"SEK": {"SEK", 2, ""},
+	"SGD": {"SGD", 2, "S$"},
+	"THB": {"THB", 2, "฿"},
+	"TRY": {"TRY", 2, ""},
+	"USD": {"USD", 2, "$"},
+	"ZAR": {"ZAR", 2, ""},
+}
+
+// CurrencyByCode returns the currency with the ISO 4217 code given. The code
+// is not case sensitive.
+func CurrencyByCode(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, errors.Annotatef(ErrUnknownCurrency, "code %q", code)
+	}
+	return c, nil
+}
+
+// Money is an amount of money in the minor units of a currency e.g. pence for
+// GBP or cents for EUR.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MoneyFromString parses a string containing an amount in the major units of
+// a currency, such as "12.40", into Money.
+func MoneyFromString(s, code string) (Money, error) {
+	c, err := CurrencyByCode(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	n, err := parseMinorUnits(s, c.Exponent)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{n, c.Code}, nil
+}
+
+// Pence returns the amount in minor units as Pence. This is only meaningful
+// as pence if the currency is GBP.
+func (m Money) Pence() Pence {
+	return Pence(m.Amount)
+}
+
+// Validate ensures that the amount is positive and that the currency is
+// known.
+func (m Money) Validate() error {
+	if _, err := CurrencyByCode(m.Currency); err != nil {
+		return err
+	}
+	return Pence(m.Amount).Validate()
+}
+
+// String formats the money into a human readable string using the symbol of
+// the currency, or the code when there is no symbol.
+func (m Money) String() string {
+	c, err := CurrencyByCode(m.Currency)
+	if err != nil {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	var negativeString = ""
+	n := m.Amount
+	if n < 0 {
+		n = -n
+		negativeString = "-"
+	}
+
+	prefix := c.Symbol
+	if prefix == "" {
+		prefix = c.Code + " "
+	}
+
+	return negativeString + prefix + formatMinorUnits(n, c.Exponent)
+}
+
+// Decimal formats the money in the major units of its currency without a
+// symbol e.g. "-12.40", which is suitable for spreadsheets.
+func (m Money) Decimal() string {
+	c, err := CurrencyByCode(m.Currency)
+	if err != nil {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	if m.Amount < 0 {
+		return "-" + formatMinorUnits(-m.Amount, c.Exponent)
+	}
+	return formatMinorUnits(m.Amount, c.Exponent)
+}
+
+// formatMinorUnits formats a non-negative amount in minor units as major
+// units, where there are 10^exp minor units in a major unit.
+func formatMinorUnits(n int64, exp int) string {
+	if exp == 0 {
+		return strconv.FormatInt(n, 10)
+	}
+
+	unit := pow10(exp)
+	return fmt.Sprintf("%01d.%0*d", n/unit, exp, n%unit)
+}
+
+func pow10(exp int) int64 {
+	ret := int64(1)
+	for i := 0; i < exp; i++ {
+		ret *= 10
+	}
+	return ret
+}
+
+// parseMinorUnits parses a string containing an amount in major units and
+// returns the amount in minor units, where there are 10^exp minor units in a
+// major unit. The amount may start with a single sign.
+func parseMinorUnits(s string, exp int) (int64, error) {
+	//Quick check to see if there are any non-numerical digits
+	if strings.ToUpper(s) != strings.ToLower(s) {
+		return 0, ErrInvalidMoneyStr
+	}
+
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+
+	// There must be at least one digit, e.g. "-" and "." are not amounts
+	if strings.Trim(s, ".") == "" {
+		return 0, ErrInvalidMoneyStr
+	}
+
+	n, err := parseUnsignedMinorUnits(s, exp)
+	return sign * n, err
+}
+
+// parseUnsignedMinorUnits is parseMinorUnits for amounts without a sign.
+func parseUnsignedMinorUnits(s string, exp int) (int64, error) {
+
+	//Ensure there is, at most, 1 decimal point.
+	nDec := strings.Count(s, ".")
+	if nDec > 1 {
+		return 0, ErrInvalidMoneyStr
+	} else if nDec == 0 {
+		return majorStrToMinor(s, exp)
+	}
+
+	strs := strings.Split(s, ".")
+	minor, err := minorStrToMinor(strs[1], exp)
+	if err != nil {
+		return 0, err
+	}
+	major, err := majorStrToMinor(strs[0], exp)
+	if err != nil {
+		return 0, err
+	}
+	return major + minor, nil
+}
+
+// minorStrToMinor converts the digits after the decimal point into the
+// number of minor units they represent.
+func minorStrToMinor(s string, exp int) (int64, error) {
+	s = strings.TrimRight(s, "0")
+	if len(s) > exp {
+		return 0, ErrInvalidMoneyStr
+	}
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	//Pad with zeros so there is a digit for each decimal place
+	s += strings.Repeat("0", exp-len(s))
+	ret, err := strconv.ParseUint(s, 10, 63)
+	if err != nil {
+		return 0, ErrInvalidMoneyStr
+	}
+
+	return int64(ret), nil
+}
+
+// majorStrToMinor returns the number of minor units in the major units
+// passed as an argument
+func majorStrToMinor(s string, exp int) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	ret, err := strconv.ParseUint(s, 10, 63)
+	if err != nil {
+		return 0, ErrInvalidMoneyStr
+	}
+
+	return int64(ret) * pow10(exp), nil
+}
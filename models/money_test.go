@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestPenceFromString(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Pence
+		err      error
+	}{
+		{s: "12", expected: 1200},
+		{s: "12.4", expected: 1240},
+		{s: "12.40", expected: 1240},
+		{s: ".5", expected: 50},
+		{s: "0.05", expected: 5},
+		{s: "-1.50", expected: -150},
+		{s: "1.505", err: ErrInvalidMoneyStr},
+		{s: "1.2.3", err: ErrInvalidMoneyStr},
+		{s: "£1", err: ErrInvalidMoneyStr},
+		{s: "abc", err: ErrInvalidMoneyStr},
+		{s: "+2", expected: 200},
+		{s: "--5", err: ErrInvalidMoneyStr},
+		{s: "-+5", err: ErrInvalidMoneyStr},
+		{s: "-", err: ErrInvalidMoneyStr},
+		{s: "+", err: ErrInvalidMoneyStr},
+		{s: "", err: ErrInvalidMoneyStr},
+		{s: ".", err: ErrInvalidMoneyStr},
+	}
+
+	for _, test := range tests {
+		p, err := PenceFromString(test.s)
+		if err != test.err {
+			t.Fatalf("Expected error %v, got %v (s=%s)", test.err, err, test.s)
+			return
+		}
+		if err == nil && p != test.expected {
+			t.Fatalf("Expected %d, got %d (s=%s)", test.expected, p, test.s)
+			return
+		}
+	}
+}
+
+func TestMoneyFromString(t *testing.T) {
+	tests := []struct {
+		s, code  string
+		expected Money
+		str, dec string
+	}{
+		{s: "12.40", code: "GBP", expected: Money{1240, "GBP"}, str: "£12.40", dec: "12.40"},
+		{s: "12.4", code: "eur", expected: Money{1240, "EUR"}, str: "€12.40", dec: "12.40"},
+		{s: "1500", code: "JPY", expected: Money{1500, "JPY"}, str: "¥1500", dec: "1500"},
+		{s: "1.5", code: "KWD", expected: Money{1500, "KWD"}, str: "KWD 1.500", dec: "1.500"},
+		{s: "-0.01", code: "CHF", expected: Money{-1, "CHF"}, str: "-CHF 0.01", dec: "-0.01"},
+	}
+
+	for _, test := range tests {
+		m, err := MoneyFromString(test.s, test.code)
+		if err != nil {
+			t.Fatalf("Error parsing %s %s: %v", test.s, test.code, err)
+			return
+		}
+		if m != test.expected {
+			t.Fatalf("Expected %+v, got %+v", test.expected, m)
+			return
+		}
+		if m.String() != test.str {
+			t.Fatalf("Expected %s, got %s", test.str, m.String())
+			return
+		}
+		if m.Decimal() != test.dec {
+			t.Fatalf("Expected %s, got %s", test.dec, m.Decimal())
+			return
+		}
+	}
+
+	if _, err := MoneyFromString("1.5", "JPY"); err != ErrInvalidMoneyStr {
+		t.Fatalf("Expected %v parsing fractional yen, got %v", ErrInvalidMoneyStr, err)
+		return
+	}
+
+	if _, err := MoneyFromString("1", "XXX"); err == nil {
+		t.Fatalf("Expected error parsing unknown currency")
+		return
+	}
+
+	if Pence(-1240).String() != "-£12.40" {
+		t.Fatalf("Expected -£12.40, got %s", Pence(-1240))
+		return
+	}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ofxDateFormat is the date part of the dates in an OFX statement. The time
+// and timezone that may follow it are ignored, as expenses are only dated to
+// the day.
+const ofxDateFormat = "20060102"
+
+// ofxTagRegexp matches an opening or closing OFX tag along with the text that
+// follows it. Version 1 statements are SGML, in which elements holding a
+// value are not closed, so the value is everything up to the next tag.
+var ofxTagRegexp = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads the transactions of an OFX bank statement. Only the
+// transactions of bank and credit card statements are read, using the name of
+// each as its description, or the memo if it has no name. The FITID of each
+// transaction becomes the import ID of its row. Spending is negative in OFX.
+// ErrStatementCurrency is returned if the statement gives a currency other
+// than the one given.
+func ParseOFX(r io.Reader, currency string) ([]*StatementRow, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read statement")
+	}
+
+	s := string(data)
+	if !strings.Contains(strings.ToUpper(s), "<OFX>") {
+		return nil, errors.Annotate(ErrInvalidStatement, "no OFX element")
+	}
+
+	var rows []*StatementRow
+	var row *StatementRow
+	var amount, name, memo string
+	for _, m := range ofxTagRegexp.FindAllStringSubmatchIndex(s, -1) {
+		closing := m[3] > m[2]
+		tag := strings.ToUpper(s[m[4]:m[5]])
+		value := strings.TrimSpace(unescapeOFX(s[m[6]:m[7]]))
+
+		switch {
+		case tag == "CURDEF" && !closing:
+			if !strings.EqualFold(value, currency) {
+				return nil, errors.Annotatef(ErrStatementCurrency, "statement is in %s", value)
+			}
+		case tag == "STMTTRN" && !closing:
+			row = &StatementRow{Line: strings.Count(s[:m[0]], "\n") + 1}
+			amount, name, memo = "", "", ""
+		case tag == "STMTTRN" && closing && row != nil:
+			row.Description = name
+			if row.Description == "" {
+				row.Description = memo
+			}
+
+			if row.Error == "" && row.Date.IsZero() {
+				row.Error = "Transaction has no date"
+			}
+
+			if row.Error == "" {
+				row.setAmount(amount, currency, true, false)
+			}
+
+			rows = append(rows, row)
+			row = nil
+		case row == nil || closing:
+			// Only the values within a transaction are needed
+		case tag == "DTPOSTED":
+			if len(value) < len(ofxDateFormat) {
+				row.Error = "Date must be of the form YYYYMMDD"
+				break
+			}
+
+			date, err := time.Parse(ofxDateFormat, value[:len(ofxDateFormat)])
+			if err != nil {
+				row.Error = "Date must be of the form YYYYMMDD"
+				break
+			}
+			row.Date = date
+		case tag == "TRNAMT":
+			// The decimal point may be a comma
+			amount = value
+			if !strings.Contains(amount, ".") {
+				amount = strings.Replace(amount, ",", ".", 1)
+			}
+		case tag == "FITID":
+			row.ImportID = value
+		case tag == "NAME":
+			name = value
+		case tag == "MEMO":
+			memo = value
+		}
+	}
+
+	return rows, nil
+}
+
+var ofxEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ", "&amp;", "&")
+
+// unescapeOFX replaces the character entities in a value of an OFX
+// statement.
+func unescapeOFX(s string) string {
+	return ofxEntities.Replace(s)
+}
@@ -0,0 +1,142 @@
+package postgrestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore"
+)
+
+const (
+	// Databases created before migrations were introduced already have the
+	// category type, so it is only created if it is missing.
+	createCategoriesIfMissingStr = `
+DO $$ BEGIN
+` + createCategoriesStr + `
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END $$;`
+
+	addMiscCategoryStr = "ALTER TYPE category_t ADD VALUE IF NOT EXISTS 'misc';"
+
+	// Pennies left over when splitting are recorded separately from the share.
+	addAssignmentRoundingStr = `ALTER TABLE expense_assignments ADD COLUMN rounding INTEGER NOT NULL DEFAULT 0 CHECK (rounding >= 0);`
+
+	// Existing expenses and payments were all in pounds.
+	addExpenseCurrencyStr = `ALTER TABLE expenses ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'GBP';`
+	addPaymentCurrencyStr = `ALTER TABLE payments ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'GBP';`
+
+	addGroupCurrencyStr       = `ALTER TABLE groups ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'GBP';`
+	addExpenseExchangeRateStr = `ALTER TABLE expenses ADD COLUMN exchange_rate DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (exchange_rate >= 0);`
+	addPaymentExchangeRateStr = `ALTER TABLE payments ADD COLUMN exchange_rate DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (exchange_rate >= 0);`
+
+	// Every existing group gets the categories that used to be fixed.
+	seedCategoriesStr = `
+WITH defaults (name, colour, icon) AS (VALUES
+	('Groceries', '#4caf50', 'shopping-cart'),
+	('Alcohol', '#9c27b0', 'glass'),
+	('Drugs', '#f44336', 'medkit'),
+	('Household Items', '#795548', 'home'),
+	('Bills', '#2196f3', 'file-text'),
+	('Presents', '#e91e63', 'gift'),
+	('Tickets', '#ff9800', 'ticket'),
+	('Misc', '#9e9e9e', 'tag'))
+INSERT INTO categories (group_id, name, colour, icon)
+	SELECT groups.id, defaults.name, defaults.colour, defaults.icon FROM groups, defaults;`
+
+	addCategoryIDToExpensesStr = `ALTER TABLE expenses ADD COLUMN category_id INTEGER REFERENCES categories(id) ON UPDATE CASCADE;`
+	// Expenses without a category are moved to Misc
+	setExpensesCategoryIDStr = `
+UPDATE expenses SET category_id=(
+	SELECT categories.id FROM categories
+		WHERE categories.group_id=expenses.group_id
+		AND lower(categories.name)=COALESCE(expenses.category::text, 'misc'));`
+	dropExpensesCategoryStr    = `ALTER TABLE expenses DROP COLUMN category;`
+	indexExpensesCategoryIDStr = `CREATE INDEX IF NOT EXISTS expenses_category_id_idx ON expenses (category_id);`
+
+	addCategoryIDToRecurringExpensesStr = `ALTER TABLE recurring_expenses ADD COLUMN category_id INTEGER REFERENCES categories(id) ON UPDATE CASCADE;`
+	// Recurring expenses without a category are moved to Misc
+	setRecurringExpensesCategoryIDStr = `
+UPDATE recurring_expenses SET category_id=(
+	SELECT categories.id FROM categories
+		WHERE categories.group_id=recurring_expenses.group_id
+		AND lower(categories.name)=COALESCE(recurring_expenses.category::text, 'misc'));`
+	dropRecurringExpensesCategoryStr    = `ALTER TABLE recurring_expenses DROP COLUMN category;`
+	indexRecurringExpensesCategoryIDStr = `CREATE INDEX IF NOT EXISTS recurring_expenses_category_id_idx ON recurring_expenses (category_id);`
+
+	// A budget of 0 means the category has no budget
+	addCategoryBudgetStr = `ALTER TABLE categories ADD COLUMN budget INTEGER NOT NULL DEFAULT 0 CHECK (budget >= 0);`
+
+	// Expenses imported from statements remember the ID the bank gave the
+	// transaction, so that it is not imported twice.
+	addExpenseImportIDStr = `ALTER TABLE expenses ADD COLUMN import_id TEXT NOT NULL DEFAULT '';`
+
+	// Must use the same expression as dialect.TextMatch to be used
+	indexExpensesDescriptionSearchStr = `
+CREATE INDEX IF NOT EXISTS expenses_description_search_idx ON expenses
+	USING GIN (to_tsvector('english', COALESCE(description, '')));`
+
+	// Expenses are listed a page at a time in the order of this index, and
+	// filtered by who they are assigned to.
+	indexExpensesGroupIDCreatedAtStr    = `CREATE INDEX IF NOT EXISTS expenses_group_id_created_at_idx ON expenses (group_id, created_at, id);`
+	indexExpenseAssignmentsExpenseIDStr = `CREATE INDEX IF NOT EXISTS expense_assignments_expense_id_idx ON expense_assignments (expense_id);`
+	indexExpenseAssignmentsUserIDStr    = `CREATE INDEX IF NOT EXISTS expense_assignments_user_id_idx ON expense_assignments (user_id);`
+)
+
+var migrations = []sqlstore.Migration{
+	// Creates the schema from before migrations were introduced, so that
+	// databases created then are migrated in the same way as new ones.
+	{Version: 1, Description: "Initial schema", Statements: append([]string{
+		createCategoriesIfMissingStr,
+	}, createTablesArr...)},
+	// Requires Postgres 12 or later, as earlier versions cannot add to an
+	// enum within a transaction.
+	{Version: 2, Description: "Add misc category", Statements: []string{
+		addMiscCategoryStr,
+	}},
+	{Version: 3, Description: "Assignment rounding", Statements: []string{
+		addAssignmentRoundingStr,
+	}},
+	{Version: 4, Description: "Expense currencies", Statements: []string{
+		addExpenseCurrencyStr,
+		addPaymentCurrencyStr,
+	}},
+	{Version: 5, Description: "Exchange rates", Statements: []string{
+		addGroupCurrencyStr,
+		addExpenseExchangeRateStr,
+		addPaymentExchangeRateStr,
+	}},
+	{Version: 6, Description: "Recurring expenses", Statements: []string{
+		createRecurringExpensesTableStr,
+	}},
+	// Replaces the category enum with categories owned by each group.
+	// Expenses are moved to the category of their group with the same name.
+	{Version: 7, Description: "Per-group categories", Statements: []string{
+		createCategoriesTableStr,
+		seedCategoriesStr,
+		addCategoryIDToExpensesStr,
+		setExpensesCategoryIDStr,
+		dropExpensesCategoryStr,
+		indexExpensesCategoryIDStr,
+		addCategoryIDToRecurringExpensesStr,
+		setRecurringExpensesCategoryIDStr,
+		dropRecurringExpensesCategoryStr,
+		indexRecurringExpensesCategoryIDStr,
+		dropCategoriesStr,
+	}},
+	{Version: 8, Description: "Category budgets", Statements: []string{
+		addCategoryBudgetStr,
+	}},
+	{Version: 9, Description: "Expense import IDs", Statements: []string{
+		addExpenseImportIDStr,
+	}},
+	{Version: 10, Description: "Receipt attachments", Statements: []string{
+		createAttachmentsTableStr,
+		indexAttachmentsExpenseIDStr,
+	}},
+	{Version: 11, Description: "Expense description search", Statements: []string{
+		indexExpensesDescriptionSearchStr,
+	}},
+	{Version: 12, Description: "Expense listing indexes", Statements: []string{
+		indexExpensesGroupIDCreatedAtStr,
+		indexExpenseAssignmentsExpenseIDStr,
+		indexExpenseAssignmentsUserIDStr,
+	}},
+}
@@ -1,10 +1,10 @@
 package postgrestore
 
 import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-
-	"fmt"
 )
 
 const (
@@ -20,7 +20,7 @@ CREATE TYPE category_t as ENUM(
 	'tickets'
 );`
 
-	dropCategoriesStr = "DROP TYPE category_t;"
+	dropCategoriesStr = "DROP TYPE IF EXISTS category_t;"
 
 	createUsersTableStr = `
 CREATE TABLE IF NOT EXISTS users (
@@ -55,6 +55,18 @@ CREATE TABLE IF NOT EXISTS groups_users(
 
 	dropGroupUserTableStr = "DROP TABLE IF EXISTS groups_users;"
 
+	createCategoriesTableStr = `
+CREATE TABLE IF NOT EXISTS categories (
+	id         SERIAL PRIMARY KEY,
+	group_id   INTEGER NOT NULL REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	name       TEXT NOT NULL CHECK (name <> ''),
+	colour     CHAR(7) NOT NULL DEFAULT '#9e9e9e',
+	icon       TEXT NOT NULL DEFAULT '',
+	UNIQUE     (group_id, name)
+);`
+
+	dropCategoriesTableStr = "DROP TABLE IF EXISTS categories;"
+
 	createExpensesTableStr = `
 CREATE TABLE IF NOT EXISTS expenses(
 	id          SERIAL PRIMARY KEY,
@@ -89,11 +101,42 @@ CREATE TABLE IF NOT EXISTS payments (
 	group_id    INTEGER REFERENCES groups(id)
 );`
 	dropPaymentsTableStr = "DROP TABLE IF EXISTS payments;"
-)
 
-// user query format strings
+	createRecurringExpensesTableStr = `
+CREATE TABLE IF NOT EXISTS recurring_expenses (
+	id          SERIAL PRIMARY KEY,
+	group_id    INTEGER REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	payer_id    INTEGER REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	amount      INTEGER NOT NULL CHECK (amount >= 0),
+	currency    CHAR(3) NOT NULL DEFAULT 'GBP',
+	category    category_t,
+	description TEXT,
+	split       TEXT NOT NULL,
+	frequency   TEXT NOT NULL,
+	day         INTEGER NOT NULL,
+	next_due    TIMESTAMP NOT NULL,
+	created_at  TIMESTAMP DEFAULT LOCALTIMESTAMP NOT NULL
+);`
+	dropRecurringExpensesTableStr = "DROP TABLE IF EXISTS recurring_expenses;"
+
+	createAttachmentsTableStr = `
+CREATE TABLE IF NOT EXISTS attachments (
+	id           SERIAL PRIMARY KEY,
+	expense_id   INTEGER NOT NULL REFERENCES expenses(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	filename     TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size         INTEGER NOT NULL CHECK (size >= 0),
+	blob_key     TEXT NOT NULL UNIQUE,
+	created_at   TIMESTAMP DEFAULT LOCALTIMESTAMP NOT NULL
+);`
+	indexAttachmentsExpenseIDStr = `CREATE INDEX IF NOT EXISTS attachments_expense_id_idx ON attachments (expense_id);`
+	dropAttachmentsTableStr      = "DROP TABLE IF EXISTS attachments;"
+)
 
 var (
+	// The schema before migrations were introduced, which the initial
+	// migration creates if it is missing. Columns added since are added by
+	// later migrations, so these must not change.
 	createTablesArr = []string{
 		createUsersTableStr,
 		createGroupsTableStr,
@@ -103,122 +146,73 @@ var (
 		createPaymentsTable,
 	}
 
-	// Ensure reverse order to above
+	// Drops everything created by the migrations, in reverse order
 	dropTablesArr = []string{
+		dropAttachmentsTableStr,
+		dropRecurringExpensesTableStr,
 		dropPaymentsTableStr,
 		dropExpenseAssingmentsTableStr,
 		dropExpensesTableStr,
+		dropCategoriesTableStr,
 		dropGroupUserTableStr,
 		dropGroupsTableStr,
 		dropUsersTableStr,
 	}
 
-	createTypesArr = []string{
-		createCategoriesStr,
-	}
-
 	dropTypesArr = []string{
 		dropCategoriesStr,
 	}
 )
 
-type postgresStore struct {
-	db    *sqlx.DB
-	debug bool
-
-	// User statements
-	insertUserStmt  *sqlx.NamedStmt
-	userByEmailStmt *sqlx.NamedStmt
-	userByIDStmt    *sqlx.NamedStmt
-	userByTokenStmt *sqlx.NamedStmt
-	updateUserStmt  *sqlx.NamedStmt
-	deleteUserStmt  *sqlx.NamedStmt
-
-	// Group statements
-	insertGroupStmt         *sqlx.NamedStmt
-	updateGroupStmt         *sqlx.NamedStmt
-	deleteGroupStmt         *sqlx.NamedStmt
-	groupByIDStmt           *sqlx.NamedStmt
-	addUserToGroupStmt      *sqlx.NamedStmt
-	removeUserFromGroupStmt *sqlx.NamedStmt
-	groupsByUserStmt        *sqlx.NamedStmt
-
-	// Payment statements
-	insertPaymentStmt *sqlx.NamedStmt
-	updatePaymentStmt *sqlx.NamedStmt
-	deletePaymentStmt *sqlx.NamedStmt
-	paymentByIDStmt   *sqlx.NamedStmt
-
-	// Expense statements
-	deleteExpenseStmt *sqlx.NamedStmt
+// dialect is how Postgres differs from the SQL shared by sqlstore.
+type dialect struct{}
+
+func (dialect) Migrations() []sqlstore.Migration {
+	return migrations
 }
 
-func MustCreate(d *sqlx.DB) *postgresStore {
-	// Set the timezone for the store. This is needed for the logic here.
-	// This obviously assumes that noone else will change this.
-	// TODO: make this more robust.
-	d.MustExec(setTimeZoneStr)
-	s := &postgresStore{db: d}
-	return s
+func (dialect) LockSchemaVersion() string {
+	return "LOCK TABLE schema_version IN EXCLUSIVE MODE;"
 }
 
-func (s *postgresStore) MustPrepareStmts() {
-	s.insertUserStmt = s.mustPrepareStmt(insertUserStr)
-	s.userByEmailStmt = s.mustPrepareStmt(userByEmailStr)
-	s.userByIDStmt = s.mustPrepareStmt(userByIDStr)
-	s.userByTokenStmt = s.mustPrepareStmt(userByTokenStr)
-	s.deleteUserStmt = s.mustPrepareStmt(deleteUserStr)
-	s.updateUserStmt = s.mustPrepareStmt(updateUserStr)
-
-	s.insertGroupStmt = s.mustPrepareStmt(insertGroupStr)
-	s.updateGroupStmt = s.mustPrepareStmt(updateGroupStr)
-	s.deleteGroupStmt = s.mustPrepareStmt(deleteGroupStr)
-	s.groupByIDStmt = s.mustPrepareStmt(groupByIDStr)
-	s.groupsByUserStmt = s.mustPrepareStmt(groupByUserStr)
-	s.addUserToGroupStmt = s.mustPrepareStmt(addUserToGroupStr)
-	s.removeUserFromGroupStmt = s.mustPrepareStmt(removeUserFromGroupStr)
-
-	s.insertPaymentStmt = s.mustPrepareStmt(insertPaymentStr)
-	s.updatePaymentStmt = s.mustPrepareStmt(updatePaymentStr)
-	s.deletePaymentStmt = s.mustPrepareStmt(deletePaymentStr)
-	s.paymentByIDStmt = s.mustPrepareStmt(paymentByIDStr)
-
-	s.deleteExpenseStmt = s.mustPrepareStmt(deleteExpenseStr)
+// LockGroup locks the row of the group for update, which blocks the foreign
+// key checks of expenses and payments being inserted into the group.
+func (dialect) LockGroup() string {
+	return "SELECT id FROM groups WHERE id=:id FOR UPDATE;"
 }
 
-func (s *postgresStore) mustPrepareStmt(stmt string) *sqlx.NamedStmt {
-	if s.debug {
-		fmt.Println("Preparing: " + stmt)
-	}
-	return s.mustPrepare(s.db.PrepareNamed(stmt))
+func (dialect) DropTables() []string {
+	return dropTablesArr
 }
-func (s *postgresStore) mustPrepare(stmt *sqlx.NamedStmt, err error) *sqlx.NamedStmt {
-	if err != nil {
-		panic("Error during prepare: " + err.Error())
-	}
-	return stmt
+
+// Time returns the expression unchanged, as timestamps are compared by time.
+func (dialect) Time(expr string) string {
+	return expr
 }
-func (s postgresStore) MustCreateTypes() {
-	s.mustExecuteStatements(createTypesArr)
+
+func (dialect) Month(expr string) string {
+	return "to_char(" + expr + ", 'YYYY-MM')"
 }
 
-func (s postgresStore) MustDropTypes() {
-	s.mustExecuteStatements(dropTypesArr)
+// TextMatch uses the full text search that expenses_description_search_idx
+// is built for, so must use the same expression.
+func (dialect) TextMatch(column, param string) string {
+	return "to_tsvector('english', COALESCE(" + column + ", '')) @@ plainto_tsquery('english', " + param + ")"
 }
 
-func (s postgresStore) MustCreateTables() {
-	s.mustExecuteStatements(createTablesArr)
+type postgresStore struct {
+	*sqlstore.Store
 }
 
-func (s postgresStore) MustDropTables() {
-	s.mustExecuteStatements(dropTablesArr)
+func MustCreate(d *sqlx.DB) *postgresStore {
+	// Set the timezone for the store. This is needed for the logic here.
+	// This obviously assumes that noone else will change this.
+	// TODO: make this more robust.
+	d.MustExec(setTimeZoneStr)
+	s := &postgresStore{sqlstore.New(d, dialect{})}
+	return s
 }
 
-func (s postgresStore) mustExecuteStatements(statements []string) {
-	for _, st := range statements {
-		if s.debug {
-			fmt.Println("Executing: " + st + "\n")
-		}
-		s.db.MustExec(st)
-	}
+func (s postgresStore) MustDropTypes() {
+	s.MustExecuteStatements(dropTypesArr)
 }
@@ -1,9 +1,10 @@
 package postgrestore
 
 import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	. "github.com/smartystreets/goconvey/convey"
 
 	"testing"
 )
@@ -19,25 +20,88 @@ func init() {
 }
 
 func TestSchemaCreate(t *testing.T) {
-	s.debug = false
-	defer func() { s.debug = false }()
 	err := db.Ping()
 	if err != nil {
 		t.Fatalf("Error pinging DB: %v", err)
 		return
 	}
 
-	s.MustCreateTypes()
 	defer s.MustDropTypes()
 
-	s.MustCreateTables()
+	from, to, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+		return
+	}
+	defer s.MustDropTables()
+
+	if from != 0 || to != len(migrations) {
+		t.Fatalf("Expected migration from 0 to %d, got %d to %d", len(migrations), from, to)
+		return
+	}
+
 	s.MustPrepareStmts()
-	s.MustDropTables()
+
+	from, to, err = s.Migrate()
+	if err != nil || from != to {
+		t.Fatalf("Expected migrating twice to do nothing, got %d to %d, %v", from, to, err)
+		return
+	}
 
 }
 
-func TestMustPrepare(t *testing.T) {
-	Convey("Attempt to prepare an invalid SQL string", t, func() {
-		So(func() { s.mustPrepareStmt("INVALID SQL") }, ShouldPanic)
-	})
+// TestMigrateBaseline checks that a database created with the schema from
+// before migrations were introduced is migrated to the current schema, with
+// its expenses moved to the categories of their group and in pounds.
+func TestMigrateBaseline(t *testing.T) {
+	defer s.MustDropTypes()
+	defer s.MustDropTables()
+
+	db.MustExec(createCategoriesStr)
+	for _, st := range createTablesArr {
+		db.MustExec(st)
+	}
+
+	db.MustExec("INSERT INTO users (id, email, name) VALUES (1, 'u@example.com', 'TEST');")
+	db.MustExec("INSERT INTO groups (id, name) VALUES (1, 'Group 1');")
+	db.MustExec("INSERT INTO expenses (id, amount, group_id, payer_id, category) VALUES (1, 100, 1, 1, 'bills');")
+	db.MustExec("INSERT INTO expense_assignments (user_id, amount, expense_id, group_id) VALUES (1, 100, 1, 1);")
+
+	from, to, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+		return
+	}
+
+	if from != 0 || to != len(migrations) {
+		t.Fatalf("Expected migration from 0 to %d, got %d to %d", len(migrations), from, to)
+		return
+	}
+
+	s.MustPrepareStmts()
+
+	g, err := s.GroupByID(1)
+	if err != nil {
+		t.Fatalf("Error getting group: %v", err)
+		return
+	}
+
+	if g.Currency != models.GBP {
+		t.Fatalf("Expected group in %s, got %s", models.GBP, g.Currency)
+	}
+
+	es, err := s.ExpensesByGroup(g)
+	if err != nil || len(es) != 1 {
+		t.Fatalf("Expected 1 expense, got %d, %v", len(es), err)
+		return
+	}
+
+	c, err := s.CategoryByID(es[0].CategoryID)
+	if err != nil || c.Name != "Bills" {
+		t.Fatalf("Expected expense in Bills, got %+v, %v", c, err)
+	}
+
+	if es[0].Currency != models.GBP || es[0].ExchangeRate != 1 {
+		t.Fatalf("Expected expense in %s at a rate of 1, got %s at %v", models.GBP, es[0].Currency, es[0].ExchangeRate)
+	}
 }
@@ -0,0 +1,62 @@
+package postgrestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore/storetest"
+
+	"testing"
+)
+
+func testSearchText(st storetest.Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Search group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "search@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	for _, e := range []*models.Expense{
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 450, Description: "Boursin cheese"},
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 300, Description: "Milk"},
+	} {
+		err = st.InsertExpense(e, models.EqualSplit([]int64{u.ID}))
+		if err != nil {
+			t.Fatalf("Error inserting expense: %v", err)
+			return
+		}
+	}
+
+	// Full text search matches other forms of the words
+	es, err := st.SearchExpenses(g, models.ExpenseSearch{Text: "cheeses"})
+	if err != nil || len(es) != 1 || es[0].Description != "Boursin cheese" {
+		t.Fatalf("Expected to find Boursin cheese, got %+v (err=%v)", es, err)
+		return
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	storetest.WrapDbTest(s, s.MustDropTypes, testSearchText)(t)
+}
@@ -0,0 +1,15 @@
+package postgrestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore/storetest"
+
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Test(t, s, s.MustDropTypes)
+}
+
+func BenchmarkStore(b *testing.B) {
+	storetest.Benchmark(b, s, s.MustDropTypes)
+}
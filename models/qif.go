@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// qifDateLayouts returns the layouts to try when parsing a date of a QIF
+// statement. QIF has no standard date format, so as well as the layout given
+// the day and month may be unpadded and the year may have two digits.
+func qifDateLayouts(layout string) []string {
+	unpadded := strings.NewReplacer("02", "2", "01", "1").Replace(layout)
+	layouts := []string{layout, unpadded}
+	for _, l := range []string{layout, unpadded} {
+		if strings.Contains(l, "2006") {
+			layouts = append(layouts, strings.Replace(l, "2006", "06", 1))
+		}
+	}
+	return layouts
+}
+
+// parseQIFDate parses the date of a QIF transaction using any of the layouts
+// given. Quicken separates the year with an apostrophe, e.g. 4/3'16, which is
+// treated as a slash.
+func parseQIFDate(s string, layouts []string) (time.Time, error) {
+	s = strings.Replace(strings.TrimSpace(s), "'", "/", -1)
+	for _, l := range layouts {
+		t, err := time.Parse(l, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("could not parse %q", s)
+}
+
+// ParseQIF reads the transactions of a QIF bank statement. The dates are
+// parsed using the date format given, which defaults to
+// DefaultStatementDateFormat. The payee of each transaction is used as its
+// description, or the memo if it has no payee. Spending is negative in QIF.
+// Headers, such as !Type:Bank, and fields other than the date, amount, payee
+// and memo are ignored.
+func ParseQIF(r io.Reader, dateFormat, currency string) ([]*StatementRow, error) {
+	if dateFormat == "" {
+		dateFormat = DefaultStatementDateFormat
+	}
+	layouts := qifDateLayouts(dateFormat)
+
+	var rows []*StatementRow
+	var row *StatementRow
+	var date, amount, payee, memo string
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		if row == nil {
+			row = &StatementRow{Line: line}
+			date, amount, payee, memo = "", "", "", ""
+		}
+
+		value := text[1:]
+		switch text[0] {
+		case 'D':
+			date = value
+		case 'T', 'U':
+			amount = value
+		case 'P':
+			payee = strings.TrimSpace(value)
+		case 'M':
+			memo = strings.TrimSpace(value)
+		case '^':
+			// End of the transaction
+			row.Description = payee
+			if row.Description == "" {
+				row.Description = memo
+			}
+
+			t, err := parseQIFDate(date, layouts)
+			if err != nil {
+				row.Error = fmt.Sprintf("Date must be of the form %s", dateFormat)
+			} else {
+				row.Date = t
+				row.setAmount(amount, currency, true, false)
+			}
+
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Annotate(err, "Could not read statement")
+	}
+
+	if row != nil {
+		return nil, errors.Annotatef(ErrInvalidStatement, "transaction starting on line %d is not ended with ^", row.Line)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultExpensePageSize is the number of expenses in a page when the
+	// query does not give a limit.
+	DefaultExpensePageSize = 50
+
+	// MaxExpensePageSize is the most expenses that can be in a page.
+	MaxExpensePageSize = 200
+)
+
+var (
+	// ErrInvalidQuery is returned when listing expenses with a query that
+	// cannot be used
+	ErrInvalidQuery = errors.New("Invalid expense query")
+
+	// ErrInvalidCursor is returned when a cursor cannot be read, which is
+	// usually because it was changed by the client
+	ErrInvalidCursor = errors.New("Invalid cursor")
+)
+
+// ExpenseOrder is the order that expenses are listed in.
+type ExpenseOrder int
+
+const (
+	// OrderNewest lists the most recent expenses first.
+	OrderNewest ExpenseOrder = iota
+	// OrderOldest lists the earliest expenses first.
+	OrderOldest
+)
+
+var expenseOrderStrings = map[ExpenseOrder]string{
+	OrderNewest: "newest",
+	OrderOldest: "oldest",
+}
+
+func (o ExpenseOrder) String() string {
+	s, ok := expenseOrderStrings[o]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (o ExpenseOrder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// ParseExpenseOrder converts "newest" or "oldest" into an ExpenseOrder. It is
+// not case sensitive.
+func ParseExpenseOrder(s string) (ExpenseOrder, error) {
+	for o, str := range expenseOrderStrings {
+		if strings.EqualFold(str, s) {
+			return o, nil
+		}
+	}
+	return 0, errors.Annotatef(ErrInvalidQuery, "unknown order %q", s)
+}
+
+// ExpenseCursor marks the last expense of a page, so that the next page
+// starts with the expense after it. Expenses are ordered by when they were
+// created, and then by ID, so the cursor holds both.
+type ExpenseCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// newExpenseCursor creates a cursor pointing at the expense.
+func newExpenseCursor(e *Expense) *ExpenseCursor {
+	return &ExpenseCursor{CreatedAt: e.CreatedAt.UTC(), ID: e.ID}
+}
+
+// String encodes the cursor into an opaque string, to be given back with the
+// query for the next page.
+func (c ExpenseCursor) String() string {
+	s := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseExpenseCursor decodes a cursor created by ExpenseCursor.String.
+func ParseExpenseCursor(s string) (*ExpenseCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Annotatef(ErrInvalidCursor, "%q", s)
+	}
+
+	var nanos, id int64
+	_, err = fmt.Sscanf(string(b), "%d:%d", &nanos, &id)
+	if err != nil || id <= 0 {
+		return nil, errors.Annotatef(ErrInvalidCursor, "%q", s)
+	}
+
+	return &ExpenseCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// ExpenseQuery selects a page of the expenses of a group. Expenses are
+// created from From, inclusive, to To, exclusive. They are in one of the
+// categories, paid by the payer, and assigned in part to the participant.
+// Zero values do not restrict the query. The expenses are listed in the
+// order given, after the cursor if there is one, with at most Limit in a
+// page.
+type ExpenseQuery struct {
+	From          time.Time
+	To            time.Time
+	CategoryIDs   []int64
+	PayerID       int64
+	ParticipantID int64
+	Order         ExpenseOrder
+	Cursor        *ExpenseCursor
+	Limit         int
+}
+
+func (q ExpenseQuery) validate() error {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return errors.Annotate(ErrInvalidQuery, "from must be before to")
+	}
+
+	if _, ok := expenseOrderStrings[q.Order]; !ok {
+		return errors.Annotatef(ErrInvalidQuery, "unknown order %d", q.Order)
+	}
+
+	if q.Limit < 0 || q.Limit > MaxExpensePageSize {
+		return errors.Annotatef(ErrInvalidQuery, "limit must be between 1 and %d", MaxExpensePageSize)
+	}
+
+	return nil
+}
+
+// Before reports whether expense a is listed before expense b in the order of
+// the query.
+func (q ExpenseQuery) Before(a, b *Expense) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		if q.Order == OrderOldest {
+			return a.ID < b.ID
+		}
+		return a.ID > b.ID
+	}
+
+	if q.Order == OrderOldest {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+// Matches reports whether the expense is selected by the query and comes
+// after the cursor, ignoring the limit. The assignments of the expense must
+// be filled in. It is for stores that cannot filter the expenses themselves.
+func (q ExpenseQuery) Matches(e *Expense) bool {
+	switch {
+	case !q.From.IsZero() && e.CreatedAt.Before(q.From),
+		!q.To.IsZero() && !e.CreatedAt.Before(q.To),
+		q.PayerID != 0 && e.PayerID != q.PayerID:
+		return false
+	}
+
+	if q.Cursor != nil {
+		c := &Expense{CreatedAt: q.Cursor.CreatedAt, ID: q.Cursor.ID}
+		if !q.Before(c, e) {
+			return false
+		}
+	}
+
+	if len(q.CategoryIDs) > 0 {
+		found := false
+		for _, id := range q.CategoryIDs {
+			found = found || e.CategoryID == id
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if q.ParticipantID != 0 {
+		for _, ea := range e.Assignments {
+			if ea.UserID == q.ParticipantID {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// ExpensePage is a page of the expenses selected by an ExpenseQuery. Next is
+// the cursor to query the following page with, which is empty on the last
+// page.
+type ExpensePage struct {
+	Expenses []*Expense `json:"expenses"`
+	Next     string     `json:"next,omitempty"`
+}
+
+// ListExpenses returns a page of the expenses of the group selected by the
+// query, with their assignments. Only the expenses of the page are loaded,
+// so long running groups can be listed a page at a time.
+func (m Manager) ListExpenses(g *Group, q ExpenseQuery) (*ExpensePage, error) {
+	err := q.validate()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultExpensePageSize
+	}
+
+	// Ask for one more, to find out whether there is another page
+	limit := q.Limit
+	q.Limit++
+	es, err := m.store.ExpensesByQuery(g, q)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not list group expenses")
+	}
+
+	page := &ExpensePage{Expenses: es}
+	if len(es) > limit {
+		page.Expenses = es[:limit]
+		page.Next = newExpenseCursor(es[limit-1]).String()
+	}
+
+	if page.Expenses == nil {
+		page.Expenses = []*Expense{}
+	}
+	return page, nil
+}
@@ -0,0 +1,172 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"testing"
+	"time"
+)
+
+func pageDescriptions(p *models.ExpensePage) []string {
+	ds := make([]string, 0, len(p.Expenses))
+	for _, e := range p.Expenses {
+		ds = append(ds, e.Description)
+	}
+	return ds
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListExpenses(t *testing.T) {
+	m, g, us := newTestGroup(t, 3)
+	groceries := mustCategory(t, m, g, "Groceries")
+	alcohol := mustCategory(t, m, g, "Alcohol")
+
+	expenses := []struct {
+		payer    int64
+		category int64
+		desc     string
+		split    []int64
+	}{
+		{us[0].ID, groceries, "Bread", []int64{us[0].ID, us[1].ID}},
+		{us[1].ID, alcohol, "Wine", []int64{us[1].ID, us[2].ID}},
+		{us[0].ID, alcohol, "Beer", []int64{us[0].ID}},
+		{us[2].ID, groceries, "Milk", []int64{us[0].ID, us[1].ID, us[2].ID}},
+	}
+
+	for _, e := range expenses {
+		_, err := m.NewExpense(g, models.Money{Amount: 600, Currency: models.GBP}, e.payer, e.category, e.desc, models.EqualSplit(e.split))
+		if err != nil {
+			t.Fatalf("Error creating expense: %v", err)
+		}
+	}
+
+	now := time.Now()
+	tests := []struct {
+		query    models.ExpenseQuery
+		expected []string
+	}{
+		{models.ExpenseQuery{}, []string{"Milk", "Beer", "Wine", "Bread"}},
+		{models.ExpenseQuery{Order: models.OrderOldest}, []string{"Bread", "Wine", "Beer", "Milk"}},
+		{models.ExpenseQuery{CategoryIDs: []int64{alcohol}}, []string{"Beer", "Wine"}},
+		{models.ExpenseQuery{CategoryIDs: []int64{alcohol, groceries}}, []string{"Milk", "Beer", "Wine", "Bread"}},
+		{models.ExpenseQuery{PayerID: us[0].ID}, []string{"Beer", "Bread"}},
+		{models.ExpenseQuery{ParticipantID: us[2].ID}, []string{"Milk", "Wine"}},
+		{models.ExpenseQuery{ParticipantID: us[1].ID, CategoryIDs: []int64{groceries}}, []string{"Milk", "Bread"}},
+		{models.ExpenseQuery{From: now.Add(-time.Hour), To: now.Add(time.Hour)}, []string{"Milk", "Beer", "Wine", "Bread"}},
+		{models.ExpenseQuery{From: now.Add(time.Hour)}, []string{}},
+		{models.ExpenseQuery{To: now.Add(-time.Hour)}, []string{}},
+	}
+
+	for _, test := range tests {
+		page, err := m.ListExpenses(g, test.query)
+		if err != nil {
+			t.Fatalf("Error listing expenses for %+v: %v", test.query, err)
+		}
+
+		if !sameStrings(pageDescriptions(page), test.expected) || page.Next != "" {
+			t.Fatalf("Expected %v for %+v, got %v (next=%q)", test.expected, test.query, pageDescriptions(page), page.Next)
+		}
+
+		for _, e := range page.Expenses {
+			if len(e.Assignments) == 0 {
+				t.Fatalf("Expected %q to have its assignments", e.Description)
+			}
+		}
+	}
+}
+
+func TestListExpensesPages(t *testing.T) {
+	m, g, us := newTestGroup(t, 1)
+	split := models.EqualSplit([]int64{us[0].ID})
+	groceries := mustCategory(t, m, g, "Groceries")
+
+	for _, desc := range []string{"One", "Two", "Three", "Four", "Five"} {
+		_, err := m.NewExpense(g, models.Money{Amount: 100, Currency: models.GBP}, us[0].ID, groceries, desc, split)
+		if err != nil {
+			t.Fatalf("Error creating expense: %v", err)
+		}
+	}
+
+	tests := []struct {
+		order    models.ExpenseOrder
+		expected [][]string
+	}{
+		{models.OrderNewest, [][]string{{"Five", "Four"}, {"Three", "Two"}, {"One"}}},
+		{models.OrderOldest, [][]string{{"One", "Two"}, {"Three", "Four"}, {"Five"}}},
+	}
+
+	for _, test := range tests {
+		q := models.ExpenseQuery{Order: test.order, Limit: 2}
+		for i, expected := range test.expected {
+			page, err := m.ListExpenses(g, q)
+			if err != nil {
+				t.Fatalf("Error listing page %d %s: %v", i, test.order, err)
+			}
+
+			if !sameStrings(pageDescriptions(page), expected) {
+				t.Fatalf("Expected page %d %s to be %v, got %v", i, test.order, expected, pageDescriptions(page))
+			}
+
+			last := i == len(test.expected)-1
+			if last != (page.Next == "") {
+				t.Fatalf("Expected only the last page to have no next cursor, page %d has %q", i, page.Next)
+			}
+
+			if !last {
+				q.Cursor, err = models.ParseExpenseCursor(page.Next)
+				if err != nil {
+					t.Fatalf("Error parsing cursor %q: %v", page.Next, err)
+				}
+			}
+		}
+	}
+}
+
+func TestListExpensesInvalid(t *testing.T) {
+	m, g, _ := newTestGroup(t, 1)
+	now := time.Now()
+
+	for _, q := range []models.ExpenseQuery{
+		{From: now, To: now.Add(-time.Hour)},
+		{Limit: models.MaxExpensePageSize + 1},
+		{Limit: -1},
+		{Order: models.ExpenseOrder(10)},
+	} {
+		_, err := m.ListExpenses(g, q)
+		if errors.Cause(err) != models.ErrInvalidQuery {
+			t.Fatalf("Expected ErrInvalidQuery for %+v, got %v", q, err)
+		}
+	}
+
+	for _, s := range []string{"", "not a cursor", "MTIzOmFiYw"} {
+		_, err := models.ParseExpenseCursor(s)
+		if errors.Cause(err) != models.ErrInvalidCursor {
+			t.Fatalf("Expected ErrInvalidCursor for %q, got %v", s, err)
+		}
+	}
+
+	c := models.ExpenseCursor{CreatedAt: time.Date(2016, time.March, 4, 12, 30, 0, 123456789, time.UTC), ID: 42}
+	parsed, err := models.ParseExpenseCursor(c.String())
+	if err != nil || !parsed.CreatedAt.Equal(c.CreatedAt) || parsed.ID != c.ID {
+		t.Fatalf("Expected cursor %+v to survive being encoded, got %+v (err=%v)", c, parsed, err)
+	}
+
+	_, err = models.ParseExpenseOrder("sideways")
+	if errors.Cause(err) != models.ErrInvalidQuery {
+		t.Fatalf("Expected ErrInvalidQuery for unknown order, got %v", err)
+	}
+}
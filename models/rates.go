@@ -0,0 +1,278 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateDateFormat is the format of the dates in exchange rate files.
+const rateDateFormat = "2006-01-02"
+
+var (
+	// ErrNoRate is returned when there is no exchange rate available to
+	// convert between two currencies
+	ErrNoRate = errors.New("No exchange rate available")
+)
+
+// RateStore provides exchange rates between currencies.
+type RateStore interface {
+	// Rate returns the number of units of the currency to that one unit of
+	// the currency from is worth on the date given.
+	Rate(from, to string, date time.Time) (float64, error)
+}
+
+// ExchangeRate is the value of one unit of a currency in another currency,
+// from the start of the day given.
+type ExchangeRate struct {
+	Date time.Time `json:"-"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Rate float64   `json:"rate"`
+}
+
+// RateTable is an in memory RateStore of dated exchange rates. The rate used
+// for a date is the most recent rate on or before the date. Rates are also
+// used in reverse when there is no rate in the direction requested.
+type RateTable struct {
+	rates map[string][]ExchangeRate // keyed by from+to, sorted by date
+}
+
+// NewRateTable creates a rate table containing the rates given.
+func NewRateTable(rates ...ExchangeRate) *RateTable {
+	t := &RateTable{rates: make(map[string][]ExchangeRate)}
+	for _, r := range rates {
+		t.Add(r)
+	}
+	return t
+}
+
+// Add inserts a rate into the table.
+func (t *RateTable) Add(r ExchangeRate) {
+	r.From = strings.ToUpper(r.From)
+	r.To = strings.ToUpper(r.To)
+	key := r.From + r.To
+
+	rs := append(t.rates[key], r)
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Date.Before(rs[j].Date)
+	})
+	t.rates[key] = rs
+}
+
+// Rate returns the rate to convert from one currency to another on the date
+// given.
+func (t *RateTable) Rate(from, to string, date time.Time) (float64, error) {
+	from = strings.ToUpper(from)
+	to = strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+
+	if r, ok := t.latest(from+to, date); ok {
+		return r, nil
+	}
+
+	if r, ok := t.latest(to+from, date); ok && r != 0 {
+		return 1 / r, nil
+	}
+
+	return 0, errors.Annotatef(ErrNoRate, "%s to %s on %s", from, to, date.Format(rateDateFormat))
+}
+
+// latest finds the most recent rate on or before the date for the key.
+func (t *RateTable) latest(key string, date time.Time) (float64, bool) {
+	rs := t.rates[key]
+	i := sort.Search(len(rs), func(i int) bool {
+		return rs[i].Date.After(date)
+	})
+	if i == 0 {
+		return 0, false
+	}
+	return rs[i-1].Rate, true
+}
+
+// LoadRatesFile reads exchange rates from a local file. Files ending in
+// .json are read with LoadRatesJSON, any other file is read as CSV.
+func LoadRatesFile(path string) (*RateTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not open rates file")
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return LoadRatesJSON(f)
+	}
+	return LoadRatesCSV(f)
+}
+
+// LoadRatesCSV reads exchange rates from CSV with the columns date, from, to
+// and rate, e.g. "2016-03-01,EUR,GBP,0.7782". Dates are in the format
+// YYYY-MM-DD. A header row is skipped if present.
+func LoadRatesCSV(r io.Reader) (*RateTable, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read rates CSV")
+	}
+
+	t := NewRateTable()
+	for i, rec := range records {
+		if len(rec) != 4 {
+			return nil, errors.Errorf("Rates CSV line %d: expected 4 columns, got %d", i+1, len(rec))
+		}
+
+		if i == 0 && strings.ToLower(strings.TrimSpace(rec[0])) == "date" {
+			continue
+		}
+
+		date, err := time.Parse(rateDateFormat, strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, errors.Annotatef(err, "Rates CSV line %d", i+1)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Rates CSV line %d", i+1)
+		}
+
+		t.Add(ExchangeRate{
+			Date: date,
+			From: strings.TrimSpace(rec[1]),
+			To:   strings.TrimSpace(rec[2]),
+			Rate: rate,
+		})
+	}
+
+	return t, nil
+}
+
+// LoadRatesJSON reads exchange rates from a JSON array of objects such as
+// {"date": "2016-03-01", "from": "EUR", "to": "GBP", "rate": 0.7782}.
+func LoadRatesJSON(r io.Reader) (*RateTable, error) {
+	var rates []struct {
+		ExchangeRate
+		Date string `json:"date"`
+	}
+
+	err := json.NewDecoder(r).Decode(&rates)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read rates JSON")
+	}
+
+	t := NewRateTable()
+	for _, rate := range rates {
+		date, err := time.Parse(rateDateFormat, rate.Date)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Invalid date for %s to %s rate", rate.From, rate.To)
+		}
+
+		rate.ExchangeRate.Date = date
+		t.Add(rate.ExchangeRate)
+	}
+
+	return t, nil
+}
+
+// Convert converts the money into another currency at the rate given,
+// rounding to the nearest minor unit.
+func (m Money) Convert(to string, rate float64) (Money, error) {
+	from, err := CurrencyByCode(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	c, err := CurrencyByCode(to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if from.Code == c.Code {
+		return Money{m.Amount, c.Code}, nil
+	}
+
+	amount := float64(m.Amount) * rate * math.Pow10(c.Exponent-from.Exponent)
+	return Money{int64(math.Round(amount)), c.Code}, nil
+}
+
+// convertExpense returns a copy of the expense converted into the currency
+// given using the exchange rate recorded on the expense. The converted
+// assignments are in proportion to the original ones and always add up to
+// the converted amount.
+func convertExpense(e *Expense, currency string) (*Expense, error) {
+	if e.Money().Currency == currency {
+		return e, nil
+	}
+
+	if e.ExchangeRate <= 0 {
+		return nil, errors.Annotatef(ErrNoRate, "expense %d has no exchange rate", e.ID)
+	}
+
+	m, err := e.Money().Convert(currency, e.ExchangeRate)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ret := *e
+	ret.Amount = Pence(m.Amount)
+	ret.Currency = m.Currency
+	ret.Assignments = nil
+
+	var shares []Share
+	for _, ea := range e.Assignments {
+		if ea.Amount > 0 {
+			shares = append(shares, Share{UserID: ea.UserID, Weight: int64(ea.Amount)})
+		}
+	}
+
+	if len(shares) == 0 {
+		return &ret, nil
+	}
+
+	amounts, _, err := Split{Mode: SplitWeighted, Shares: shares}.amounts(ret.Amount, e.PayerID, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	for i, s := range shares {
+		ret.Assignments = append(ret.Assignments, &ExpenseAssignment{
+			UserID:    s.UserID,
+			Amount:    amounts[i],
+			ExpenseID: e.ID,
+			GroupID:   e.GroupID,
+		})
+	}
+
+	return &ret, nil
+}
+
+// convertPayment returns a copy of the payment converted into the currency
+// given using the exchange rate recorded on the payment.
+func convertPayment(p *Payment, currency string) (*Payment, error) {
+	if p.Money().Currency == currency {
+		return p, nil
+	}
+
+	if p.ExchangeRate <= 0 {
+		return nil, errors.Annotatef(ErrNoRate, "payment %d has no exchange rate", p.ID)
+	}
+
+	m, err := p.Money().Convert(currency, p.ExchangeRate)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ret := *p
+	ret.Amount = Pence(m.Amount)
+	ret.Currency = m.Currency
+	return &ret, nil
+}
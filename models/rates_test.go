@@ -0,0 +1,123 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseDate(s string) time.Time {
+	t, err := time.Parse(rateDateFormat, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRateTable(t *testing.T) {
+	rates, err := LoadRatesCSV(strings.NewReader(`date,from,to,rate
+2016-03-01,EUR,GBP,0.78
+2016-03-03,EUR,GBP,0.80
+2016-03-01,GBP,JPY,160
+`))
+	if err != nil {
+		t.Fatalf("Error loading rates: %v", err)
+		return
+	}
+
+	tests := []struct {
+		from, to string
+		date     time.Time
+		expected float64
+	}{
+		{from: "EUR", to: "GBP", date: mustParseDate("2016-03-01"), expected: 0.78},
+		{from: "EUR", to: "GBP", date: mustParseDate("2016-03-02"), expected: 0.78},
+		{from: "eur", to: "gbp", date: mustParseDate("2016-03-05"), expected: 0.80},
+		{from: "JPY", to: "GBP", date: mustParseDate("2016-03-01"), expected: 1.0 / 160},
+		{from: "USD", to: "USD", date: mustParseDate("2016-03-01"), expected: 1},
+	}
+
+	for _, test := range tests {
+		r, err := rates.Rate(test.from, test.to, test.date)
+		if err != nil {
+			t.Fatalf("Error getting rate %s to %s: %v", test.from, test.to, err)
+			return
+		}
+		if r != test.expected {
+			t.Fatalf("Expected rate %v for %s to %s, got %v", test.expected, test.from, test.to, r)
+			return
+		}
+	}
+
+	if _, err := rates.Rate("EUR", "GBP", mustParseDate("2016-02-29")); err == nil {
+		t.Fatalf("Expected error getting rate before the first rate")
+		return
+	}
+
+	if _, err := rates.Rate("USD", "GBP", mustParseDate("2016-03-01")); err == nil {
+		t.Fatalf("Expected error getting rate for unknown currencies")
+		return
+	}
+}
+
+func TestLoadRatesJSON(t *testing.T) {
+	rates, err := LoadRatesJSON(strings.NewReader(`[{"date": "2016-03-01", "from": "EUR", "to": "GBP", "rate": 0.78}]`))
+	if err != nil {
+		t.Fatalf("Error loading rates: %v", err)
+		return
+	}
+
+	r, err := rates.Rate("EUR", "GBP", mustParseDate("2016-03-10"))
+	if err != nil || r != 0.78 {
+		t.Fatalf("Expected rate of 0.78, got %v (err=%v)", r, err)
+		return
+	}
+}
+
+func TestConvertExpense(t *testing.T) {
+	e := &Expense{
+		ID:           1,
+		Amount:       1000,
+		Currency:     "EUR",
+		ExchangeRate: 0.777,
+		PayerID:      1,
+		GroupID:      1,
+		Assignments: []*ExpenseAssignment{
+			{UserID: 1, Amount: 334},
+			{UserID: 2, Amount: 333},
+			{UserID: 3, Amount: 333},
+		},
+	}
+
+	converted, err := convertExpense(e, GBP)
+	if err != nil {
+		t.Fatalf("Error converting expense: %v", err)
+		return
+	}
+
+	if converted.Amount != 777 || converted.Currency != GBP {
+		t.Fatalf("Expected £7.77, got %s", converted.Money())
+		return
+	}
+
+	var total Pence
+	for _, ea := range converted.Assignments {
+		total += ea.Amount
+	}
+
+	if total != converted.Amount {
+		t.Fatalf("Converted assignments total %s, expected %s", total, converted.Amount)
+		return
+	}
+
+	if e.Amount != 1000 || e.Currency != "EUR" {
+		t.Fatalf("Original expense should not be modified")
+		return
+	}
+
+	e.ExchangeRate = 0
+	if _, err := convertExpense(e, GBP); err == nil {
+		t.Fatalf("Expected error converting expense without a rate")
+		return
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSchedule is returned when the schedule of a recurring
+	// expense does not describe a valid day
+	ErrInvalidSchedule = errors.New("Invalid schedule for recurring expense")
+)
+
+// Frequency is how often a recurring expense falls due.
+type Frequency int
+
+const (
+	// FrequencyMonthly recurring expenses are due every month on the day
+	// of the month given. In months that are too short, the expense is due
+	// on the last day of the month.
+	FrequencyMonthly Frequency = iota
+	// FrequencyWeekly recurring expenses are due every week on the day of
+	// the week given, where Sunday is 0.
+	FrequencyWeekly
+)
+
+var frequencyStrings = map[Frequency]string{
+	FrequencyMonthly: "monthly",
+	FrequencyWeekly:  "weekly",
+}
+
+func (f Frequency) String() string {
+	s, ok := frequencyStrings[f]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (f Frequency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.String())
+}
+
+func (f *Frequency) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Trace(err)
+	}
+	return f.parse(s)
+}
+
+func (f Frequency) Value() (driver.Value, error) {
+	return f.String(), nil
+}
+
+func (f *Frequency) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return f.parse(string(v))
+	case string:
+		return f.parse(v)
+	}
+	return errors.New("cannot convert value to Frequency")
+}
+
+func (f *Frequency) parse(s string) error {
+	for freq, str := range frequencyStrings {
+		if strings.ToLower(s) == str {
+			*f = freq
+			return nil
+		}
+	}
+	return errors.Annotatef(ErrInvalidSchedule, "unknown frequency %q", s)
+}
+
+// Value stores the split as JSON so that it can be saved alongside a
+// recurring expense.
+func (s Split) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return string(b), nil
+}
+
+func (s *Split) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return errors.Trace(json.Unmarshal(v, s))
+	case string:
+		return errors.Trace(json.Unmarshal([]byte(v), s))
+	}
+	return errors.New("cannot convert value to Split")
+}
+
+// RecurringExpense is the definition of an expense that repeats on a
+// schedule, such as a monthly bill. The expenses themselves are created by
+// Manager.GenerateRecurringExpenses when they fall due. NextDue is the date
+// that the next expense should be created.
+type RecurringExpense struct {
+	ID          int64     `db:"id" json:"id"`
+	GroupID     int64     `db:"group_id" json:"groupId"`
+	PayerID     int64     `db:"payer_id" json:"payerId"`
+	Amount      Pence     `db:"amount" json:"amount"`
+	Currency    string    `db:"currency" json:"currency"`
+	CategoryID  int64     `db:"category_id" json:"categoryId"`
+	Description string    `db:"description" json:"description"`
+	Split       Split     `db:"split" json:"split"`
+	Frequency   Frequency `db:"frequency" json:"frequency"`
+	Day         int       `db:"day" json:"day"`
+	NextDue     time.Time `db:"next_due" json:"nextDue"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Money returns the amount of each expense along with its currency.
+func (r RecurringExpense) Money() Money {
+	if r.Currency == "" {
+		return Money{int64(r.Amount), DefaultCurrency}
+	}
+	return Money{int64(r.Amount), r.Currency}
+}
+
+func (r RecurringExpense) validate() error {
+	err := r.Money().Validate()
+	if err != nil {
+		return err
+	}
+
+	if r.PayerID <= 0 || r.GroupID <= 0 || r.CategoryID <= 0 {
+		return errors.New("PayerId, GroupId and CategoryId must be positive")
+	}
+
+	switch r.Frequency {
+	case FrequencyMonthly:
+		if r.Day < 1 || r.Day > 31 {
+			return errors.Annotate(ErrInvalidSchedule, "day of month must be between 1 and 31")
+		}
+	case FrequencyWeekly:
+		if r.Day < int(time.Sunday) || r.Day > int(time.Saturday) {
+			return errors.Annotate(ErrInvalidSchedule, "day of week must be between 0 and 6")
+		}
+	default:
+		return ErrInvalidSchedule
+	}
+
+	// Check the split can be used to divide up the amount.
+	_, _, err = r.Split.amounts(r.Amount, r.PayerID, nil)
+	return err
+}
+
+// NextAfter returns the first date the expense is due strictly after the
+// date given.
+func (r RecurringExpense) NextAfter(t time.Time) time.Time {
+	return r.nextOnOrAfter(startOfDay(t).AddDate(0, 0, 1))
+}
+
+// nextOnOrAfter returns the first date the expense is due on or after the
+// date given.
+func (r RecurringExpense) nextOnOrAfter(t time.Time) time.Time {
+	t = startOfDay(t)
+	switch r.Frequency {
+	case FrequencyWeekly:
+		diff := (r.Day - int(t.Weekday()) + 7) % 7
+		return t.AddDate(0, 0, diff)
+	default:
+		due := dayInMonth(t.Year(), t.Month(), r.Day, t.Location())
+		if due.Before(t) {
+			due = dayInMonth(t.Year(), t.Month()+1, r.Day, t.Location())
+		}
+		return due
+	}
+}
+
+// dayInMonth returns the day of the month given, or the last day of the month
+// if the month is too short.
+func dayInMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
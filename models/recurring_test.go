@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestRecurringSchedule(t *testing.T) {
+	tests := []struct {
+		r         RecurringExpense
+		from      string
+		onOrAfter string
+		after     string
+	}{
+		{r: RecurringExpense{Frequency: FrequencyMonthly, Day: 1}, from: "2016-03-01", onOrAfter: "2016-03-01", after: "2016-04-01"},
+		{r: RecurringExpense{Frequency: FrequencyMonthly, Day: 1}, from: "2016-03-02", onOrAfter: "2016-04-01", after: "2016-04-01"},
+		{r: RecurringExpense{Frequency: FrequencyMonthly, Day: 31}, from: "2016-02-01", onOrAfter: "2016-02-29", after: "2016-02-29"},
+		{r: RecurringExpense{Frequency: FrequencyMonthly, Day: 31}, from: "2016-02-29", onOrAfter: "2016-02-29", after: "2016-03-31"},
+		{r: RecurringExpense{Frequency: FrequencyMonthly, Day: 15}, from: "2016-12-20", onOrAfter: "2017-01-15", after: "2017-01-15"},
+		// 2016-03-01 was a Tuesday
+		{r: RecurringExpense{Frequency: FrequencyWeekly, Day: 2}, from: "2016-03-01", onOrAfter: "2016-03-01", after: "2016-03-08"},
+		{r: RecurringExpense{Frequency: FrequencyWeekly, Day: 0}, from: "2016-03-01", onOrAfter: "2016-03-06", after: "2016-03-06"},
+	}
+
+	for _, test := range tests {
+		from := mustParseDate(test.from)
+		if due := test.r.nextOnOrAfter(from); !due.Equal(mustParseDate(test.onOrAfter)) {
+			t.Fatalf("Expected %s due on or after %s, got %s", test.onOrAfter, test.from, due.Format(rateDateFormat))
+			return
+		}
+		if due := test.r.NextAfter(from); !due.Equal(mustParseDate(test.after)) {
+			t.Fatalf("Expected %s due after %s, got %s", test.after, test.from, due.Format(rateDateFormat))
+			return
+		}
+	}
+}
+
+func TestRecurringValidate(t *testing.T) {
+	valid := RecurringExpense{
+		GroupID:    1,
+		PayerID:    1,
+		Amount:     1099,
+		CategoryID: 1,
+		Split:      EqualSplit([]int64{1, 2}),
+		Frequency:  FrequencyMonthly,
+		Day:        1,
+	}
+
+	if err := valid.validate(); err != nil {
+		t.Fatalf("Unexpected error validating recurring expense: %v", err)
+		return
+	}
+
+	invalid := valid
+	invalid.Day = 32
+	if err := invalid.validate(); err == nil {
+		t.Fatalf("Expected error for day 32 of the month")
+		return
+	}
+
+	invalid = valid
+	invalid.Frequency = FrequencyWeekly
+	invalid.Day = 7
+	if err := invalid.validate(); err == nil {
+		t.Fatalf("Expected error for day 7 of the week")
+		return
+	}
+
+	invalid = valid
+	invalid.Split = Split{}
+	if err := invalid.validate(); err != ErrMustAssignToUsers {
+		t.Fatalf("Expected %v, got %v", ErrMustAssignToUsers, err)
+		return
+	}
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Remainder is the policy used to decide who pays the pennies left over when
+// an expense cannot be divided exactly between the users it is assigned to.
+type Remainder int
+
+const (
+	// RemainderLargestFraction gives the left over pennies to the users that
+	// were owed the largest part of a penny on top of their share. Users owed
+	// the same part of a penny are given them in order of user ID, lowest
+	// first.
+	RemainderLargestFraction Remainder = iota
+	// RemainderPayer gives all of the left over pennies to the payer of the
+	// expense. If the payer does not have a share of the expense then the
+	// pennies are given out as with RemainderLargestFraction.
+	RemainderPayer
+	// RemainderRoundRobin gives the left over pennies to the users that have
+	// paid the fewest pennies from rounding in the group so far, so that
+	// the pennies even out over time.
+	RemainderRoundRobin
+	// RemainderLowestUserID gives the left over pennies to the users with
+	// the lowest IDs, however much of a penny they were owed.
+	RemainderLowestUserID
+)
+
+var remainderStrings = map[Remainder]string{
+	RemainderLargestFraction: "largest_fraction",
+	RemainderPayer:           "payer",
+	RemainderRoundRobin:      "round_robin",
+	RemainderLowestUserID:    "lowest_user_id",
+}
+
+func (r Remainder) String() string {
+	s, ok := remainderStrings[r]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (r Remainder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Remainder) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Trace(err)
+	}
+
+	for policy, str := range remainderStrings {
+		if strings.ToLower(s) == str {
+			*r = policy
+			return nil
+		}
+	}
+	return errors.Trace(ErrUnknownRemainder)
+}
+
+// Rounding maps the ID of a user to the total number of left over pennies
+// they have been assigned within a group. The fewer pennies a user has been
+// assigned, the greater the advantage they have had from rounding.
+type Rounding map[int64]Pence
+
+// allocateRemainder gives out the pennies left over after assigning each share
+// the whole number of pennies it is owed. The fractions are the parts of a
+// penny each share was owed in addition, all with the same denominator. The
+// rounding returned is the number of extra pennies given to each share.
+func (r Remainder) allocateRemainder(left Pence, shares []Share, fractions []int64, payerID int64, history Rounding) ([]Pence, error) {
+	rounding := make([]Pence, len(shares))
+	if left == 0 {
+		return rounding, nil
+	}
+
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+
+	byFraction := func(i, j int) bool {
+		a, b := order[i], order[j]
+		if fractions[a] != fractions[b] {
+			return fractions[a] > fractions[b]
+		}
+		return shares[a].UserID < shares[b].UserID
+	}
+
+	switch r {
+	case RemainderPayer:
+		for i, sh := range shares {
+			if sh.UserID == payerID {
+				rounding[i] = left
+				return rounding, nil
+			}
+		}
+		sort.Slice(order, byFraction)
+	case RemainderLargestFraction:
+		sort.Slice(order, byFraction)
+	case RemainderLowestUserID:
+		sort.Slice(order, func(i, j int) bool {
+			return shares[order[i]].UserID < shares[order[j]].UserID
+		})
+	case RemainderRoundRobin:
+		sort.Slice(order, func(i, j int) bool {
+			a, b := shares[order[i]].UserID, shares[order[j]].UserID
+			if history[a] != history[b] {
+				return history[a] < history[b]
+			}
+			return byFraction(i, j)
+		})
+	default:
+		return nil, ErrUnknownRemainder
+	}
+
+	// The remainder is always less than the number of shares, so no share
+	// will get more than one extra penny.
+	for i := Pence(0); i < left; i++ {
+		rounding[order[i]]++
+	}
+
+	return rounding, nil
+}
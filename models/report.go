@@ -0,0 +1,158 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrUnknownReportBy is returned when a report is requested for a breakdown
+// that does not exist
+var ErrUnknownReportBy = errors.New("Reports can only be broken down by payer or assignee")
+
+// ReportMonthFormat is the format of the months in a report.
+const ReportMonthFormat = "2006-01"
+
+// ReportBy is who spending is attributed to in a report.
+type ReportBy int
+
+const (
+	// ReportByPayer attributes each expense to the user who paid it.
+	ReportByPayer ReportBy = iota
+	// ReportByAssignee attributes each share of an expense to the user it
+	// was assigned to.
+	ReportByAssignee
+)
+
+var reportByStrings = map[ReportBy]string{
+	ReportByPayer:    "payer",
+	ReportByAssignee: "assignee",
+}
+
+func (b ReportBy) String() string {
+	s, ok := reportByStrings[b]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (b ReportBy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+// ParseReportBy converts "payer" or "assignee" into a ReportBy.
+func ParseReportBy(s string) (ReportBy, error) {
+	for b, str := range reportByStrings {
+		if str == s {
+			return b, nil
+		}
+	}
+	return 0, errors.Annotatef(ErrUnknownReportBy, "%q", s)
+}
+
+// SpendingTotal is a total of the expenses of a group, aggregated by storage,
+// for one month, category and user in one currency. Month is of the form
+// ReportMonthFormat in UTC. Amount is the sum of the amount of each expense,
+// or of each share, multiplied by the exchange rate of the expense. It is
+// therefore in the currency of the group but in the minor units of Currency.
+type SpendingTotal struct {
+	Month      string  `db:"month"`
+	CategoryID int64   `db:"category_id"`
+	UserID     int64   `db:"user_id"`
+	Currency   string  `db:"currency"`
+	Amount     float64 `db:"amount"`
+	Count      int     `db:"count"`
+}
+
+// ReportRow is the amount spent by one user in one category during a month,
+// in the currency of the group. Count is the number of expenses.
+type ReportRow struct {
+	Month      string `json:"month"`
+	CategoryID int64  `json:"categoryId"`
+	UserID     int64  `json:"userId"`
+	Amount     Pence  `json:"amount"`
+	Count      int    `json:"count"`
+}
+
+// Report breaks down the spending of a group between From, inclusive, and To,
+// exclusive. The totals by month, category and user are the sums of the rows,
+// and all amounts are in the currency of the group.
+type Report struct {
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	By         ReportBy         `json:"by"`
+	Currency   string           `json:"currency"`
+	Rows       []*ReportRow     `json:"rows"`
+	Months     map[string]Pence `json:"months"`
+	Categories map[int64]Pence  `json:"categories"`
+	Users      map[int64]Pence  `json:"users"`
+	Total      Pence            `json:"total"`
+}
+
+// newReport converts the totals from storage into the currency of the group
+// and adds them up.
+func newReport(g *Group, from, to time.Time, by ReportBy, ts []*SpendingTotal) (*Report, error) {
+	c, err := CurrencyByCode(g.BaseCurrency())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	r := &Report{
+		From:       from,
+		To:         to,
+		By:         by,
+		Currency:   c.Code,
+		Rows:       []*ReportRow{},
+		Months:     make(map[string]Pence),
+		Categories: make(map[int64]Pence),
+		Users:      make(map[int64]Pence),
+	}
+
+	type key struct {
+		month    string
+		category int64
+		user     int64
+	}
+
+	// Totals in different currencies for the same row are added together
+	rows := make(map[key]*ReportRow)
+	for _, t := range ts {
+		tc, err := CurrencyByCode(t.Currency)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		amount := Pence(math.Round(t.Amount * math.Pow10(c.Exponent-tc.Exponent)))
+		k := key{t.Month, t.CategoryID, t.UserID}
+		row, ok := rows[k]
+		if !ok {
+			row = &ReportRow{Month: t.Month, CategoryID: t.CategoryID, UserID: t.UserID}
+			rows[k] = row
+			r.Rows = append(r.Rows, row)
+		}
+
+		row.Amount += amount
+		row.Count += t.Count
+		r.Months[t.Month] += amount
+		r.Categories[t.CategoryID] += amount
+		r.Users[t.UserID] += amount
+		r.Total += amount
+	}
+
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.CategoryID != b.CategoryID {
+			return a.CategoryID < b.CategoryID
+		}
+		return a.UserID < b.UserID
+	})
+
+	return r, nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewReport(t *testing.T) {
+	g := &Group{ID: 1, Currency: GBP}
+	ts := []*SpendingTotal{
+		{Month: "2016-03", CategoryID: 1, UserID: 1, Currency: GBP, Amount: 1000, Count: 2},
+		// 1500 yen at 0.0065 is 9.75 in pounds
+		{Month: "2016-03", CategoryID: 1, UserID: 1, Currency: "JPY", Amount: 9.75, Count: 1},
+		{Month: "2016-04", CategoryID: 2, UserID: 2, Currency: GBP, Amount: 500, Count: 1},
+	}
+
+	from := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	r, err := newReport(g, from, from.AddDate(0, 2, 0), ReportByPayer, ts)
+	if err != nil {
+		t.Fatalf("Error creating report: %v", err)
+		return
+	}
+
+	if len(r.Rows) != 2 || r.Rows[0].Amount != 1975 || r.Rows[0].Count != 3 || r.Rows[1].Amount != 500 {
+		t.Fatalf("Expected rows of 19.75 and 5.00, got %+v, %+v", r.Rows[0], r.Rows[1])
+		return
+	}
+
+	if r.Months["2016-03"] != 1975 || r.Categories[2] != 500 || r.Users[1] != 1975 || r.Total != 2475 {
+		t.Fatalf("Unexpected totals in report %+v", r)
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"strings"
+)
+
+var (
+	// ErrInvalidSearch is returned when searching for expenses with an
+	// amount range that cannot match anything
+	ErrInvalidSearch = errors.New("The minimum amount must not be negative or more than the maximum")
+)
+
+// ExpenseSearch filters the expenses of a group. Text matches the words of
+// the description, and the other fields restrict the expenses further. The
+// amounts are in the minor units of the currency of each expense, and are
+// inclusive. Zero values do not restrict the search, so the zero
+// ExpenseSearch matches every expense.
+type ExpenseSearch struct {
+	Text       string `json:"text"`
+	MinAmount  Pence  `json:"minAmount"`
+	MaxAmount  Pence  `json:"maxAmount"`
+	CategoryID int64  `json:"categoryId"`
+	PayerID    int64  `json:"payerId"`
+}
+
+func (q ExpenseSearch) validate() error {
+	if q.MinAmount < 0 || q.MaxAmount < 0 {
+		return errors.Trace(ErrInvalidSearch)
+	}
+
+	if q.MaxAmount != 0 && q.MinAmount > q.MaxAmount {
+		return errors.Trace(ErrInvalidSearch)
+	}
+
+	return nil
+}
+
+// MatchesFilters reports whether the expense is within the amount range and
+// has the category and payer searched for. The text is not considered.
+func (q ExpenseSearch) MatchesFilters(e *Expense) bool {
+	switch {
+	case q.MinAmount != 0 && e.Amount < q.MinAmount,
+		q.MaxAmount != 0 && e.Amount > q.MaxAmount,
+		q.CategoryID != 0 && e.CategoryID != q.CategoryID,
+		q.PayerID != 0 && e.PayerID != q.PayerID:
+		return false
+	}
+	return true
+}
+
+// MatchesText reports whether every word of the text appears somewhere in
+// the description, ignoring case. It is a simple substitute for full text
+// search, for stores that do not have it.
+func (q ExpenseSearch) MatchesText(description string) bool {
+	description = strings.ToLower(description)
+	for _, word := range strings.Fields(strings.ToLower(q.Text)) {
+		if !strings.Contains(description, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the expense matches both the text and the filters.
+func (q ExpenseSearch) Matches(e *Expense) bool {
+	return q.MatchesFilters(e) && q.MatchesText(e.Description)
+}
+
+// SearchExpenses returns the expenses of the group matching the search, the
+// most recent first. How closely the text must match depends on the store:
+// Postgres uses full text search, so that e.g. "shops" also matches "shop",
+// while other stores look for each word within the description.
+func (m Manager) SearchExpenses(g *Group, q ExpenseSearch) ([]*Expense, error) {
+	err := q.validate()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	q.Text = strings.TrimSpace(q.Text)
+	es, err := m.store.SearchExpenses(g, q)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not search group expenses")
+	}
+
+	if es == nil {
+		es = []*Expense{}
+	}
+	return es, nil
+}
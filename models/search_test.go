@@ -0,0 +1,70 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"testing"
+)
+
+func TestSearchExpenses(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	groceries := mustCategory(t, m, g, "Groceries")
+	alcohol := mustCategory(t, m, g, "Alcohol")
+
+	expenses := []struct {
+		amount   int64
+		payer    int64
+		category int64
+		desc     string
+	}{
+		{450, us[0].ID, groceries, "Boursin and crackers"},
+		{1200, us[1].ID, alcohol, "Wine to go with the boursin"},
+		{300, us[0].ID, groceries, "Milk"},
+	}
+
+	for _, e := range expenses {
+		_, err := m.NewExpense(g, models.Money{Amount: e.amount, Currency: models.GBP}, e.payer, e.category, e.desc, split)
+		if err != nil {
+			t.Fatalf("Error creating expense: %v", err)
+		}
+	}
+
+	tests := []struct {
+		search   models.ExpenseSearch
+		expected []string
+	}{
+		{models.ExpenseSearch{}, []string{"Milk", "Wine to go with the boursin", "Boursin and crackers"}},
+		{models.ExpenseSearch{Text: "BOURSIN"}, []string{"Wine to go with the boursin", "Boursin and crackers"}},
+		{models.ExpenseSearch{Text: "crackers boursin"}, []string{"Boursin and crackers"}},
+		{models.ExpenseSearch{Text: "boursin", MaxAmount: 1000}, []string{"Boursin and crackers"}},
+		{models.ExpenseSearch{MinAmount: 300, MaxAmount: 450}, []string{"Milk", "Boursin and crackers"}},
+		{models.ExpenseSearch{Text: "boursin", CategoryID: alcohol}, []string{"Wine to go with the boursin"}},
+		{models.ExpenseSearch{PayerID: us[1].ID}, []string{"Wine to go with the boursin"}},
+		{models.ExpenseSearch{Text: "cheddar"}, []string{}},
+	}
+
+	for _, test := range tests {
+		es, err := m.SearchExpenses(g, test.search)
+		if err != nil {
+			t.Fatalf("Error searching for %+v: %v", test.search, err)
+		}
+
+		if len(es) != len(test.expected) {
+			t.Fatalf("Expected %d expenses for %+v, got %d", len(test.expected), test.search, len(es))
+		}
+
+		for i, e := range es {
+			if e.Description != test.expected[i] || len(e.Assignments) != 2 {
+				t.Fatalf("Expected %q with its assignments for %+v, got %+v", test.expected[i], test.search, e)
+			}
+		}
+	}
+
+	_, err := m.SearchExpenses(g, models.ExpenseSearch{MinAmount: 500, MaxAmount: 100})
+	if errors.Cause(err) != models.ErrInvalidSearch {
+		t.Fatalf("Expected ErrInvalidSearch, got %v", err)
+	}
+}
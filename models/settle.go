@@ -0,0 +1,106 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"sort"
+)
+
+var (
+	// ErrPlanChanged is returned when settling up with a plan that no longer
+	// matches the balances of the group
+	ErrPlanChanged = errors.New("The group's balances have changed since the plan was created")
+)
+
+// debt is used to keep track of the outstanding amount owed to, or by, a
+// user while creating a settle up plan.
+type debt struct {
+	userID int64
+	amount Pence
+}
+
+// byAmount sorts debts largest first. Ties are broken on the user ID so that
+// the plan produced for a set of balances is always the same.
+type byAmount []*debt
+
+func (a byAmount) Len() int {
+	return len(a)
+}
+
+func (a byAmount) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a byAmount) Less(i, j int) bool {
+	if a[i].amount == a[j].amount {
+		return a[i].userID < a[j].userID
+	}
+	return a[i].amount > a[j].amount
+}
+
+// SettleUp creates the payments required to bring every balance back to
+// zero. The user that owes the most repeatedly pays the user that is owed the
+// most, which settles at least one of them with every payment. This means
+// that a group of n users with non-zero balances needs at most n-1 payments.
+// The payments returned have not been saved and do not have a GroupID.
+func SettleUp(b Balances) []*Payment {
+	var creditors, debtors []*debt
+	for id, amount := range b {
+		if amount > 0 {
+			creditors = append(creditors, &debt{id, amount})
+		} else if amount < 0 {
+			debtors = append(debtors, &debt{id, -amount})
+		}
+	}
+
+	sort.Sort(byAmount(creditors))
+	sort.Sort(byAmount(debtors))
+
+	var ret []*Payment
+	for len(creditors) > 0 && len(debtors) > 0 {
+		c, d := creditors[0], debtors[0]
+		amount := c.amount
+		if d.amount < amount {
+			amount = d.amount
+		}
+
+		ret = append(ret, &Payment{
+			GiverID:    d.userID,
+			ReceiverID: c.userID,
+			Amount:     amount,
+		})
+
+		c.amount -= amount
+		d.amount -= amount
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+
+		// Keep the largest outstanding amounts at the front.
+		sort.Sort(byAmount(creditors))
+		sort.Sort(byAmount(debtors))
+	}
+
+	return ret
+}
+
+// samePayments checks that two lists of payments transfer the same amounts
+// between the same people, in the same order.
+func samePayments(a, b []*Payment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].GiverID != b[i].GiverID ||
+			a[i].ReceiverID != b[i].ReceiverID ||
+			a[i].Amount != b[i].Amount {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		balances    Balances
+		maxPayments int
+	}{
+		{balances: Balances{}, maxPayments: 0},
+		{balances: Balances{1: 0, 2: 0}, maxPayments: 0},
+		{balances: Balances{1: 1240, 2: -1240}, maxPayments: 1},
+		{balances: Balances{1: 100, 2: -50, 3: -50}, maxPayments: 2},
+		{balances: Balances{1: 1000, 2: 500, 3: -300, 4: -700, 5: -500}, maxPayments: 4},
+		{balances: Balances{1: 1, 2: 1, 3: 1, 4: -1, 5: -1, 6: -1}, maxPayments: 3},
+	}
+
+	for _, test := range tests {
+		ps := SettleUp(test.balances)
+		if len(ps) > test.maxPayments {
+			t.Fatalf("Expected at most %d payments, got %d (balances=%v)", test.maxPayments, len(ps), test.balances)
+			return
+		}
+
+		// Applying the payments must bring everybody back to zero
+		after := CalculateBalances(nil, ps)
+		for id, amount := range test.balances {
+			after[id] += amount
+		}
+
+		for id, amount := range after {
+			if amount != 0 {
+				t.Fatalf("User %d has balance %s after settling up (balances=%v)", id, amount, test.balances)
+				return
+			}
+		}
+
+		for _, p := range ps {
+			if p.Amount <= 0 {
+				t.Fatalf("Payment must be positive, got %s", p.Amount)
+				return
+			}
+		}
+	}
+}
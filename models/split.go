@@ -0,0 +1,210 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/json"
+	"math/big"
+	"strings"
+)
+
+// SplitMode determines how the shares of a Split are used to divide up an
+// expense.
+type SplitMode int
+
+const (
+	// SplitEqual divides the expense equally between the users. The
+	// weights and amounts of the shares are ignored.
+	SplitEqual SplitMode = iota
+	// SplitWeighted divides the expense in proportion to the weight of
+	// each share e.g. rent split 2:1:1
+	SplitWeighted
+	// SplitExact assigns the amount of each share to the user. The amounts
+	// must add up to the amount of the expense.
+	SplitExact
+	// SplitPercentage assigns a percentage of the expense to each user. The
+	// weight of each share is the percentage in hundredths of a percent
+	// (e.g. 3333 is 33.33%) and must add up to 10000.
+	SplitPercentage
+)
+
+// percentageTotal is the total weight of the shares of a percentage split.
+const percentageTotal = 10000
+
+// MaxShareWeight is the largest weight a share may have, which keeps the
+// total weight of a split far from overflowing.
+const MaxShareWeight = 1000000
+
+var splitModeStrings = map[SplitMode]string{
+	SplitEqual:      "equal",
+	SplitWeighted:   "weighted",
+	SplitExact:      "exact",
+	SplitPercentage: "percentage",
+}
+
+func (m SplitMode) String() string {
+	s, ok := splitModeStrings[m]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (m SplitMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *SplitMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Trace(err)
+	}
+
+	for mode, str := range splitModeStrings {
+		if strings.ToLower(s) == str {
+			*m = mode
+			return nil
+		}
+	}
+	return errors.Trace(ErrUnknownSplitMode)
+}
+
+// Share is the part of an expense that is assigned to a user. Which of the
+// fields are used depends on the mode of the Split the share is in.
+type Share struct {
+	UserID int64 `json:"userId"`
+	Weight int64 `json:"weight,omitempty"`
+	Amount Pence `json:"amount,omitempty"`
+}
+
+// EqualShares creates shares with the same weight for each of the users
+// given, so that an expense is split equally between them.
+func EqualShares(userIDs []int64) []Share {
+	ret := make([]Share, 0, len(userIDs))
+	for _, id := range userIDs {
+		ret = append(ret, Share{UserID: id, Weight: 1})
+	}
+	return ret
+}
+
+// Split describes how an expense is divided up between the users of a group.
+// The remainder policy decides who pays any pennies left over when the
+// expense does not divide exactly.
+type Split struct {
+	Mode      SplitMode `json:"mode"`
+	Shares    []Share   `json:"shares"`
+	Remainder Remainder `json:"remainder"`
+}
+
+// EqualSplit creates a split which divides an expense equally between the
+// users given.
+func EqualSplit(userIDs []int64) Split {
+	return Split{Mode: SplitEqual, Shares: EqualShares(userIDs)}
+}
+
+// UserIDs returns the IDs of the users in the split, in order.
+func (s Split) UserIDs() []int64 {
+	ret := make([]int64, 0, len(s.Shares))
+	for _, sh := range s.Shares {
+		ret = append(ret, sh.UserID)
+	}
+	return ret
+}
+
+// validate ensures that there is at least one share, that each user only has
+// a single share and that no weight is more than MaxShareWeight.
+func (s Split) validate() error {
+	if len(s.Shares) == 0 {
+		return ErrMustAssignToUsers
+	}
+
+	seen := make(map[int64]bool)
+	for _, sh := range s.Shares {
+		if sh.Weight > MaxShareWeight {
+			return ErrWeightTooLarge
+		}
+
+		if seen[sh.UserID] {
+			return errors.Errorf("User %d has more than one share", sh.UserID)
+		}
+		seen[sh.UserID] = true
+	}
+	return nil
+}
+
+// amounts calculates the amount assigned to each share, in the same order as
+// the shares, when splitting the total given. The part of each amount that
+// came from left over pennies is also returned.
+func (s Split) amounts(total Pence, payerID int64, history Rounding) ([]Pence, []Pence, error) {
+	if err := s.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	weights := make([]int64, len(s.Shares))
+	switch s.Mode {
+	case SplitEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case SplitWeighted, SplitPercentage:
+		var sum int64
+		for i, sh := range s.Shares {
+			if sh.Weight <= 0 {
+				return nil, nil, ErrNonPositiveWeight
+			}
+			weights[i] = sh.Weight
+			sum += sh.Weight
+		}
+		if s.Mode == SplitPercentage && sum != percentageTotal {
+			return nil, nil, ErrSplitPercentageTotal
+		}
+	case SplitExact:
+		ret := make([]Pence, len(s.Shares))
+		var sum Pence
+		for i, sh := range s.Shares {
+			if sh.Amount < 0 {
+				return nil, nil, ErrNegativeShareAmount
+			}
+			ret[i] = sh.Amount
+			sum += sh.Amount
+		}
+		if sum != total {
+			return nil, nil, ErrSplitAmountMismatch
+		}
+		return ret, make([]Pence, len(s.Shares)), nil
+	default:
+		return nil, nil, ErrUnknownSplitMode
+	}
+
+	var totalWeight int64
+	for _, w := range weights {
+		totalWeight += w
+	}
+
+	// Every share gets the whole number of pennies it is owed, keeping
+	// track of what is left over. The total times the weight can overflow
+	// an int64 for large amounts, but the quotient and remainder cannot.
+	amounts := make([]Pence, len(weights))
+	fractions := make([]int64, len(weights))
+	var assigned Pence
+	product, quotient, remainder := new(big.Int), new(big.Int), new(big.Int)
+	divisor := big.NewInt(totalWeight)
+	for i, w := range weights {
+		product.Mul(big.NewInt(int64(total)), big.NewInt(w))
+		quotient.QuoRem(product, divisor, remainder)
+		amounts[i] = Pence(quotient.Int64())
+		fractions[i] = remainder.Int64()
+		assigned += amounts[i]
+	}
+
+	rounding, err := s.Remainder.allocateRemainder(total-assigned, s.Shares, fractions, payerID, history)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range amounts {
+		amounts[i] += rounding[i]
+	}
+
+	return amounts, rounding, nil
+}
@@ -0,0 +1,493 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSplitwise is returned when a Splitwise export cannot be read
+	ErrInvalidSplitwise = errors.New("The Splitwise export could not be read")
+
+	// ErrUnknownSplitwiseUser is returned when someone in a Splitwise export
+	// has no email, or is not a member of the group being imported into
+	ErrUnknownSplitwiseUser = errors.New("Everyone in the Splitwise export must be a member of the group")
+
+	// ErrSplitwiseUnbalanced is returned when an expense in a Splitwise
+	// export was not paid and owed in full
+	ErrSplitwiseUnbalanced = errors.New("An expense in the Splitwise export does not add up")
+)
+
+// splitwiseDateFormat is the format of the dates in a Splitwise CSV export.
+const splitwiseDateFormat = "2006-01-02"
+
+// splitwiseCSVColumns are the columns of a Splitwise CSV export before the
+// column of each person.
+var splitwiseCSVColumns = []string{"Date", "Description", "Category", "Cost", "Currency"}
+
+// SplitwiseExpense is an expense or payment from a Splitwise export. Paid and
+// Owed map the email of each person, in lower case, to the amount they paid
+// and owe in the minor units of the currency. The person who gave a payment
+// has paid it and the person who received it owes it.
+type SplitwiseExpense struct {
+	Date        time.Time
+	Description string
+	Category    string
+	Currency    string
+	Payment     bool
+	Paid        map[string]Pence
+	Owed        map[string]Pence
+}
+
+// validate ensures that the expense was paid and owed in full, and that a
+// payment was from one person to another.
+func (e SplitwiseExpense) validate() error {
+	var paid, owed Pence
+	for _, p := range e.Paid {
+		if p < 0 {
+			return errors.Trace(ErrNegativePence)
+		}
+		paid += p
+	}
+
+	for _, p := range e.Owed {
+		if p < 0 {
+			return errors.Trace(ErrNegativePence)
+		}
+		owed += p
+	}
+
+	if paid <= 0 || paid != owed {
+		return errors.Annotatef(ErrSplitwiseUnbalanced, "%q paid %d but owed %d", e.Description, paid, owed)
+	}
+
+	if e.Payment {
+		giver, receiver := splitwiseEmails(e.Paid), splitwiseEmails(e.Owed)
+		if len(giver) != 1 || len(receiver) != 1 || giver[0] == receiver[0] {
+			return errors.Annotatef(ErrSplitwiseUnbalanced, "payment %q is not from one person to another", e.Description)
+		}
+	}
+
+	return nil
+}
+
+// splitwiseEmails returns the emails of the people with a positive amount, in
+// order.
+func splitwiseEmails(amounts map[string]Pence) []string {
+	var emails []string
+	for email, p := range amounts {
+		if p > 0 {
+			emails = append(emails, email)
+		}
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+// splitwiseSplits divides up an expense between the people who paid it, as
+// there is only one payer of each expense here. Each payer gets an exact
+// split of the amount they paid, and the people who owe are assigned to them
+// in turn, so that everyone is assigned exactly what they owe. An expense with
+// one payer therefore keeps the exact shares it had on Splitwise. The IDs map
+// the emails to the ID of each user. The ID of each payer and the amount they
+// paid are returned with their split.
+func splitwiseSplits(e *SplitwiseExpense, ids map[string]int64) ([]int64, []Pence, []Split) {
+	payers := splitwiseEmails(e.Paid)
+	owers := splitwiseEmails(e.Owed)
+
+	remaining := make(map[string]Pence)
+	for _, email := range owers {
+		remaining[email] = e.Owed[email]
+	}
+
+	var payerIDs []int64
+	var amounts []Pence
+	var splits []Split
+	next := 0
+	for _, payer := range payers {
+		left := e.Paid[payer]
+		payerIDs = append(payerIDs, ids[payer])
+		amounts = append(amounts, left)
+
+		split := Split{Mode: SplitExact}
+		for left > 0 && next < len(owers) {
+			ower := owers[next]
+			take := remaining[ower]
+			if take > left {
+				take = left
+			}
+
+			split.Shares = append(split.Shares, Share{UserID: ids[ower], Amount: take})
+			left -= take
+			remaining[ower] -= take
+			if remaining[ower] == 0 {
+				next++
+			}
+		}
+		splits = append(splits, split)
+	}
+
+	return payerIDs, amounts, splits
+}
+
+// splitwiseAmount parses an amount from a Splitwise export.
+func splitwiseAmount(s, currency string) (Pence, error) {
+	// The amounts of people not involved in an expense may be left empty
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	m, err := MoneyFromString(s, currency)
+	if err != nil {
+		return 0, errors.Annotatef(ErrInvalidSplitwise, "amount %q: %v", s, err)
+	}
+	return Pence(m.Amount), nil
+}
+
+// ParseSplitwiseCSV reads the expenses and payments of a Splitwise CSV export.
+// The export has a column for each person, headed by their name, giving how
+// much each expense changed their balance. The emails map the names to the
+// email of each person. As only the change in balance is exported, the payer
+// of an expense is the one person whose balance went up, and they owe the
+// rest of the cost. If several people paid, then each is treated as having
+// paid what their balance went up by, which keeps the balances the same.
+// Expenses that did not change anyone's balance, and the total balance at the
+// end of the export, are skipped.
+func ParseSplitwiseCSV(r io.Reader, emails map[string]string) ([]*SplitwiseExpense, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read Splitwise export")
+	}
+
+	if len(records) == 0 || len(records[0]) <= len(splitwiseCSVColumns) {
+		return nil, errors.Annotate(ErrInvalidSplitwise, "no people in the export")
+	}
+
+	header := records[0]
+	for i, col := range splitwiseCSVColumns {
+		if !strings.EqualFold(strings.TrimSpace(header[i]), col) {
+			return nil, errors.Annotatef(ErrInvalidSplitwise, "column %d is not %s", i+1, col)
+		}
+	}
+
+	var people []string
+	for _, name := range header[len(splitwiseCSVColumns):] {
+		name = strings.TrimSpace(name)
+		email, ok := emails[name]
+		if !ok || email == "" {
+			return nil, errors.Annotatef(ErrUnknownSplitwiseUser, "no email for %q", name)
+		}
+		people = append(people, strings.ToLower(strings.TrimSpace(email)))
+	}
+
+	var ret []*SplitwiseExpense
+	for i, record := range records[1:] {
+		line := i + 2
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		if len(record) != len(header) {
+			return nil, errors.Annotatef(ErrInvalidSplitwise, "line %d has %d columns, not %d", line, len(record), len(header))
+		}
+
+		if strings.EqualFold(strings.TrimSpace(record[1]), "Total balance") {
+			continue
+		}
+
+		date, err := time.Parse(splitwiseDateFormat, strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, errors.Annotatef(ErrInvalidSplitwise, "line %d: date must be of the form YYYY-MM-DD", line)
+		}
+
+		e := &SplitwiseExpense{
+			Date:        date,
+			Description: strings.TrimSpace(record[1]),
+			Category:    strings.TrimSpace(record[2]),
+			Currency:    strings.ToUpper(strings.TrimSpace(record[4])),
+			Paid:        make(map[string]Pence),
+			Owed:        make(map[string]Pence),
+		}
+		e.Payment = strings.EqualFold(e.Category, "Payment")
+
+		cost, err := splitwiseAmount(record[3], e.Currency)
+		if err != nil {
+			return nil, errors.Annotatef(err, "line %d", line)
+		}
+
+		nets := make(map[string]Pence)
+		var payers []string
+		for j, email := range people {
+			net, err := splitwiseAmount(record[len(splitwiseCSVColumns)+j], e.Currency)
+			if err != nil {
+				return nil, errors.Annotatef(err, "line %d", line)
+			}
+
+			nets[email] = net
+			if net > 0 {
+				payers = append(payers, email)
+			}
+		}
+
+		if len(payers) == 0 {
+			continue
+		}
+
+		for email, net := range nets {
+			switch {
+			case net < 0:
+				e.Owed[email] = -net
+			case net > 0 && (len(payers) > 1 || e.Payment):
+				e.Paid[email] = net
+			case net > 0:
+				e.Paid[email] = cost
+				if cost > net {
+					e.Owed[email] = cost - net
+				}
+			}
+		}
+
+		err = e.validate()
+		if err != nil {
+			return nil, errors.Annotatef(err, "line %d", line)
+		}
+		ret = append(ret, e)
+	}
+
+	return ret, nil
+}
+
+// splitwiseUser is a person in a Splitwise JSON export. Only members of the
+// group have their email in the API, so the users of each expense are looked
+// up in the members of the group.
+type splitwiseUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+func (u splitwiseUser) name() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// splitwiseJSON is a Splitwise JSON export, which is the response of the
+// get_group API, for the members, along with that of get_expenses.
+type splitwiseJSON struct {
+	Group struct {
+		Members []splitwiseUser `json:"members"`
+	} `json:"group"`
+	Expenses []struct {
+		Description  string     `json:"description"`
+		Payment      bool       `json:"payment"`
+		CurrencyCode string     `json:"currency_code"`
+		Date         time.Time  `json:"date"`
+		DeletedAt    *time.Time `json:"deleted_at"`
+		Category     struct {
+			Name string `json:"name"`
+		} `json:"category"`
+		Users []struct {
+			User      splitwiseUser `json:"user"`
+			UserID    int64         `json:"user_id"`
+			PaidShare string        `json:"paid_share"`
+			OwedShare string        `json:"owed_share"`
+		} `json:"users"`
+	} `json:"expenses"`
+}
+
+// ParseSplitwiseJSON reads the expenses and payments of a Splitwise JSON
+// export, which is an object holding the group from the get_group API and the
+// expenses from the get_expenses API. The paid and owed shares of each person
+// are kept exactly. Deleted expenses are skipped.
+func ParseSplitwiseJSON(r io.Reader) ([]*SplitwiseExpense, error) {
+	var export splitwiseJSON
+	err := json.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, errors.Annotatef(ErrInvalidSplitwise, "%v", err)
+	}
+
+	emails := make(map[int64]string)
+	for _, u := range export.Group.Members {
+		emails[u.ID] = u.Email
+	}
+
+	var ret []*SplitwiseExpense
+	for _, se := range export.Expenses {
+		if se.DeletedAt != nil {
+			continue
+		}
+
+		e := &SplitwiseExpense{
+			Date:        se.Date.UTC(),
+			Description: strings.TrimSpace(se.Description),
+			Category:    strings.TrimSpace(se.Category.Name),
+			Currency:    strings.ToUpper(se.CurrencyCode),
+			Payment:     se.Payment,
+			Paid:        make(map[string]Pence),
+			Owed:        make(map[string]Pence),
+		}
+
+		for _, su := range se.Users {
+			id := su.UserID
+			if id == 0 {
+				id = su.User.ID
+			}
+
+			email := su.User.Email
+			if email == "" {
+				email = emails[id]
+			}
+
+			if email == "" {
+				return nil, errors.Annotatef(ErrUnknownSplitwiseUser, "no email for %q", su.User.name())
+			}
+			email = strings.ToLower(strings.TrimSpace(email))
+
+			paid, err := splitwiseAmount(su.PaidShare, e.Currency)
+			if err != nil {
+				return nil, errors.Annotatef(err, "%q", e.Description)
+			}
+
+			owed, err := splitwiseAmount(su.OwedShare, e.Currency)
+			if err != nil {
+				return nil, errors.Annotatef(err, "%q", e.Description)
+			}
+
+			e.Paid[email] += paid
+			e.Owed[email] += owed
+		}
+
+		err = e.validate()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		ret = append(ret, e)
+	}
+
+	return ret, nil
+}
+
+// ImportSplitwise recreates the expenses and payments of a Splitwise export in
+// the group, keeping their dates. Everyone in the export must be a member of
+// the group with the same email. Expenses are put in the category of the
+// group with the same name as their Splitwise category, or the category given
+// if there is none. Everything is imported in a single transaction, and the
+// balance of each member changes by exactly what it did on Splitwise, in each
+// currency. Budget alerts are not sent for imported expenses.
+func (m Manager) ImportSplitwise(g *Group, ses []*SplitwiseExpense, category int64) ([]*Expense, []*Payment, error) {
+	fallback, err := m.checkCategory(g, category)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	cs, err := m.store.CategoriesByGroup(g)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "Could not retrieve group categories")
+	}
+
+	categories := make(map[string]int64)
+	for _, c := range cs {
+		categories[strings.ToLower(c.Name)] = c.ID
+	}
+
+	members, err := m.store.MembersByGroup(g)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "Could not retrieve group members")
+	}
+
+	ids := make(map[string]int64)
+	for _, mem := range members {
+		ids[strings.ToLower(mem.Email)] = mem.ID
+	}
+
+	var missing []string
+	for _, se := range ses {
+		for _, amounts := range []map[string]Pence{se.Paid, se.Owed} {
+			for email := range amounts {
+				if _, ok := ids[email]; !ok {
+					missing = append(missing, email)
+					ids[email] = 0
+				}
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, nil, errors.Annotatef(ErrUnknownSplitwiseUser, "not members: %s", strings.Join(missing, ", "))
+	}
+
+	es := []*Expense{}
+	ps := []*Payment{}
+	var splits []Split
+	for _, se := range ses {
+		err := se.validate()
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+
+		c, err := CurrencyByCode(se.Currency)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+
+		rate, err := m.exchangeRate(c.Code, g.BaseCurrency(), se.Date)
+		if err != nil {
+			return nil, nil, errors.Annotatef(err, "Unable to convert %q into group currency", se.Description)
+		}
+
+		if se.Payment {
+			giver, receiver := splitwiseEmails(se.Paid), splitwiseEmails(se.Owed)
+			ps = append(ps, &Payment{
+				GroupID:      g.ID,
+				Amount:       se.Paid[giver[0]],
+				Currency:     c.Code,
+				ExchangeRate: rate,
+				GiverID:      ids[giver[0]],
+				ReceiverID:   ids[receiver[0]],
+				CreatedAt:    se.Date,
+			})
+			continue
+		}
+
+		categoryID, ok := categories[strings.ToLower(se.Category)]
+		if !ok {
+			categoryID = fallback.ID
+		}
+
+		payers, amounts, payerSplits := splitwiseSplits(se, ids)
+		for i, payer := range payers {
+			es = append(es, &Expense{
+				Amount:       amounts[i],
+				Currency:     c.Code,
+				ExchangeRate: rate,
+				PayerID:      payer,
+				CategoryID:   categoryID,
+				Description:  se.Description,
+				GroupID:      g.ID,
+				CreatedAt:    se.Date,
+			})
+			splits = append(splits, payerSplits[i])
+		}
+	}
+
+	if len(es) == 0 && len(ps) == 0 {
+		return es, ps, nil
+	}
+
+	err = m.store.InsertHistory(es, splits, ps)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "Unable to import Splitwise history")
+	}
+
+	return es, ps, nil
+}
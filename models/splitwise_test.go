@@ -0,0 +1,164 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSplitwiseCSV = `Date,Description,Category,Cost,Currency,Ann,Bob,Cat
+
+2016-03-04,Dinner,Dining out,30.00,GBP,20.00,-10.00,-10.00
+2016-03-05,Taxi,Taxi,12.00,GBP,4.00,4.00,-8.00
+2016-03-06,Bob paid Ann,Payment,5.00,GBP,-5.00,5.00,0.00
+2016-03-07,Tickets,Tickets,9.00,GBP,0.00,0.00,0.00
+
+2016-03-07,Total balance, , ,GBP,19.00,-1.00,-18.00
+`
+
+var testSplitwiseEmails = map[string]string{
+	"Ann": "A@example.com",
+	"Bob": "b@example.com",
+	"Cat": "c@example.com",
+}
+
+func TestParseSplitwiseCSV(t *testing.T) {
+	ses, err := models.ParseSplitwiseCSV(strings.NewReader(testSplitwiseCSV), testSplitwiseEmails)
+	if err != nil {
+		t.Fatalf("Error parsing Splitwise CSV: %v", err)
+	}
+
+	if len(ses) != 3 {
+		t.Fatalf("Expected 3 expenses, got %d", len(ses))
+	}
+
+	dinner := ses[0]
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+	if !dinner.Date.Equal(date) || dinner.Description != "Dinner" || dinner.Payment {
+		t.Fatalf("Unexpected dinner %+v", dinner)
+	}
+
+	if dinner.Paid["a@example.com"] != 3000 || dinner.Owed["a@example.com"] != 1000 || dinner.Owed["c@example.com"] != 1000 {
+		t.Fatalf("Expected Ann to pay 30.00 and owe 10.00, got %+v", dinner)
+	}
+
+	if taxi := ses[1]; taxi.Paid["a@example.com"] != 400 || taxi.Paid["b@example.com"] != 400 || taxi.Owed["c@example.com"] != 800 {
+		t.Fatalf("Expected Ann and Bob to pay 4.00 each, got %+v", taxi)
+	}
+
+	if payment := ses[2]; !payment.Payment || payment.Paid["b@example.com"] != 500 || payment.Owed["a@example.com"] != 500 {
+		t.Fatalf("Expected payment from Bob to Ann, got %+v", payment)
+	}
+
+	_, err = models.ParseSplitwiseCSV(strings.NewReader(testSplitwiseCSV), map[string]string{"Ann": "a@example.com"})
+	if errors.Cause(err) != models.ErrUnknownSplitwiseUser {
+		t.Fatalf("Expected ErrUnknownSplitwiseUser, got %v", err)
+	}
+
+	unbalanced := "Date,Description,Category,Cost,Currency,Ann,Bob,Cat\n2016-03-04,Dinner,Dining out,30.00,GBP,20.00,-5.00,-10.00\n"
+	_, err = models.ParseSplitwiseCSV(strings.NewReader(unbalanced), testSplitwiseEmails)
+	if errors.Cause(err) != models.ErrSplitwiseUnbalanced {
+		t.Fatalf("Expected ErrSplitwiseUnbalanced, got %v", err)
+	}
+}
+
+const testSplitwiseJSON = `{
+	"group": {"members": [
+		{"id": 1, "first_name": "Ann", "email": "a@example.com"},
+		{"id": 2, "first_name": "Bob", "email": "b@example.com"}
+	]},
+	"expenses": [
+		{"description": "Wine", "payment": false, "currency_code": "GBP", "date": "2016-03-04T19:00:00Z",
+		 "category": {"name": "Alcohol"},
+		 "users": [
+			{"user": {"id": 1, "first_name": "Ann"}, "user_id": 1, "paid_share": "10.01", "owed_share": "5.01"},
+			{"user": {"id": 2, "first_name": "Bob"}, "user_id": 2, "paid_share": "0.0", "owed_share": "5.00"}
+		 ]},
+		{"description": "Deleted", "payment": false, "currency_code": "GBP", "date": "2016-03-05T19:00:00Z",
+		 "deleted_at": "2016-03-06T10:00:00Z", "category": {"name": "General"},
+		 "users": [
+			{"user": {"id": 1}, "user_id": 1, "paid_share": "1.00", "owed_share": "0.0"}
+		 ]}
+	]
+}`
+
+func TestParseSplitwiseJSON(t *testing.T) {
+	ses, err := models.ParseSplitwiseJSON(strings.NewReader(testSplitwiseJSON))
+	if err != nil {
+		t.Fatalf("Error parsing Splitwise JSON: %v", err)
+	}
+
+	if len(ses) != 1 {
+		t.Fatalf("Expected the deleted expense to be skipped, got %d expenses", len(ses))
+	}
+
+	wine := ses[0]
+	if wine.Category != "Alcohol" || wine.Paid["a@example.com"] != 1001 || wine.Owed["a@example.com"] != 501 || wine.Owed["b@example.com"] != 500 {
+		t.Fatalf("Unexpected wine %+v", wine)
+	}
+}
+
+func TestImportSplitwise(t *testing.T) {
+	m, g, us := newTestGroup(t, 3)
+	misc := mustCategory(t, m, g, "Misc")
+
+	ses, err := models.ParseSplitwiseCSV(strings.NewReader(testSplitwiseCSV), testSplitwiseEmails)
+	if err != nil {
+		t.Fatalf("Error parsing Splitwise CSV: %v", err)
+	}
+
+	es, ps, err := m.ImportSplitwise(g, ses, misc)
+	if err != nil {
+		t.Fatalf("Error importing from Splitwise: %v", err)
+	}
+
+	// The taxi had two payers, so becomes two expenses
+	if len(es) != 3 || len(ps) != 1 {
+		t.Fatalf("Expected 3 expenses and 1 payment, got %d and %d", len(es), len(ps))
+	}
+
+	if date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC); !es[0].CreatedAt.Equal(date) || es[0].CategoryID != misc {
+		t.Fatalf("Expected dinner dated %s in Misc, got %+v", date, es[0])
+	}
+
+	b, err := m.GroupBalances(g)
+	if err != nil {
+		t.Fatalf("Error getting balances: %v", err)
+	}
+
+	expected := models.Balances{us[0].ID: 1900, us[1].ID: -100, us[2].ID: -1800}
+	for id, amount := range expected {
+		if b[id] != amount {
+			t.Fatalf("Expected balance of %d for user %d, got %d", amount, id, b[id])
+		}
+	}
+
+	ses, err = models.ParseSplitwiseJSON(strings.NewReader(testSplitwiseJSON))
+	if err != nil {
+		t.Fatalf("Error parsing Splitwise JSON: %v", err)
+	}
+
+	es, _, err = m.ImportSplitwise(g, ses, misc)
+	if err != nil {
+		t.Fatalf("Error importing from Splitwise: %v", err)
+	}
+
+	if es[0].CategoryID != mustCategory(t, m, g, "Alcohol") {
+		t.Fatalf("Expected wine in Alcohol, got category %d", es[0].CategoryID)
+	}
+
+	m, g, _ = newTestGroup(t, 2)
+	ses, err = models.ParseSplitwiseCSV(strings.NewReader(testSplitwiseCSV), testSplitwiseEmails)
+	if err != nil {
+		t.Fatalf("Error parsing Splitwise CSV: %v", err)
+	}
+
+	_, _, err = m.ImportSplitwise(g, ses, mustCategory(t, m, g, "Misc"))
+	if errors.Cause(err) != models.ErrUnknownSplitwiseUser {
+		t.Fatalf("Expected ErrUnknownSplitwiseUser importing for a non-member, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package sqlitestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore"
+
+	"fmt"
+)
+
+const (
+	// Every existing group gets the categories that used to be fixed.
+	seedCategoriesStr = `
+WITH defaults (name, colour, icon) AS (VALUES
+	('Groceries', '#4caf50', 'shopping-cart'),
+	('Alcohol', '#9c27b0', 'glass'),
+	('Drugs', '#f44336', 'medkit'),
+	('Household Items', '#795548', 'home'),
+	('Bills', '#2196f3', 'file-text'),
+	('Presents', '#e91e63', 'gift'),
+	('Tickets', '#ff9800', 'ticket'),
+	('Misc', '#9e9e9e', 'tag'))
+INSERT INTO categories (group_id, name, colour, icon)
+	SELECT groups.id, defaults.name, defaults.colour, defaults.icon FROM groups, defaults;`
+
+	addCategoryIDToExpensesStr = `ALTER TABLE expenses ADD COLUMN category_id INTEGER REFERENCES categories(id) ON UPDATE CASCADE;`
+	// Expenses without a category are moved to Misc
+	setExpensesCategoryIDStr = `
+UPDATE expenses SET category_id=(
+	SELECT categories.id FROM categories
+		WHERE categories.group_id=expenses.group_id
+		AND lower(categories.name)=COALESCE(expenses.category, 'misc'));`
+	dropExpensesCategoryStr    = `ALTER TABLE expenses DROP COLUMN category;`
+	indexExpensesCategoryIDStr = `CREATE INDEX IF NOT EXISTS expenses_category_id_idx ON expenses (category_id);`
+
+	addCategoryIDToRecurringExpensesStr = `ALTER TABLE recurring_expenses ADD COLUMN category_id INTEGER REFERENCES categories(id) ON UPDATE CASCADE;`
+	// Recurring expenses without a category are moved to Misc
+	setRecurringExpensesCategoryIDStr = `
+UPDATE recurring_expenses SET category_id=(
+	SELECT categories.id FROM categories
+		WHERE categories.group_id=recurring_expenses.group_id
+		AND lower(categories.name)=COALESCE(recurring_expenses.category, 'misc'));`
+	dropRecurringExpensesCategoryStr    = `ALTER TABLE recurring_expenses DROP COLUMN category;`
+	indexRecurringExpensesCategoryIDStr = `CREATE INDEX IF NOT EXISTS recurring_expenses_category_id_idx ON recurring_expenses (category_id);`
+
+	// A budget of 0 means the category has no budget
+	addCategoryBudgetStr = `ALTER TABLE categories ADD COLUMN budget INTEGER NOT NULL DEFAULT 0 CHECK (budget >= 0);`
+
+	// Expenses imported from statements remember the ID the bank gave the
+	// transaction, so that it is not imported twice.
+	addExpenseImportIDStr = `ALTER TABLE expenses ADD COLUMN import_id TEXT NOT NULL DEFAULT '';`
+
+	// Expenses are listed a page at a time in the order of this index, and
+	// filtered by who they are assigned to. Must use the same expression as
+	// dialect.Time to be used.
+	indexExpensesGroupIDCreatedAtStr = `
+CREATE INDEX IF NOT EXISTS expenses_group_id_created_at_idx ON expenses
+	(group_id, strftime('%Y-%m-%d %H:%M:%f', created_at), id);`
+	indexExpenseAssignmentsExpenseIDStr = `CREATE INDEX IF NOT EXISTS expense_assignments_expense_id_idx ON expense_assignments (expense_id);`
+	indexExpenseAssignmentsUserIDStr    = `CREATE INDEX IF NOT EXISTS expense_assignments_user_id_idx ON expense_assignments (user_id);`
+)
+
+var migrations = []sqlstore.Migration{
+	{Version: 1, Description: "Initial schema", Statements: []string{
+		createUsersTableStr,
+		createGroupsTableStr,
+		createGroupsUsersTableStr,
+		fmt.Sprintf(createExpensesTableStr, categoryCheck()),
+		createExpenseAssignmentsTableStr,
+		createPaymentsTable,
+		fmt.Sprintf(createRecurringExpensesTableStr, categoryCheck()),
+	}},
+	// Replaces the category check with categories owned by each group.
+	// Expenses are moved to the category of their group with the same name.
+	{Version: 2, Description: "Per-group categories", Statements: []string{
+		createCategoriesTableStr,
+		seedCategoriesStr,
+		addCategoryIDToExpensesStr,
+		setExpensesCategoryIDStr,
+		dropExpensesCategoryStr,
+		indexExpensesCategoryIDStr,
+		addCategoryIDToRecurringExpensesStr,
+		setRecurringExpensesCategoryIDStr,
+		dropRecurringExpensesCategoryStr,
+		indexRecurringExpensesCategoryIDStr,
+	}},
+	{Version: 3, Description: "Category budgets", Statements: []string{
+		addCategoryBudgetStr,
+	}},
+	{Version: 4, Description: "Expense import IDs", Statements: []string{
+		addExpenseImportIDStr,
+	}},
+	{Version: 5, Description: "Receipt attachments", Statements: []string{
+		createAttachmentsTableStr,
+		indexAttachmentsExpenseIDStr,
+	}},
+	{Version: 6, Description: "Expense listing indexes", Statements: []string{
+		indexExpensesGroupIDCreatedAtStr,
+		indexExpenseAssignmentsExpenseIDStr,
+		indexExpenseAssignmentsUserIDStr,
+	}},
+}
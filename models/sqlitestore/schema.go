@@ -0,0 +1,229 @@
+// Package sqlitestore implements models.Storer and auth.Storer using a SQLite
+// database file, so that a household can run the app on a single small
+// machine without a database server. The SQL is shared with postgrestore in
+// sqlstore, and the schema follows postgrestore.
+package sqlitestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+
+	"strings"
+)
+
+const (
+	createUsersTableStr = `
+CREATE TABLE IF NOT EXISTS users (
+	id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+	email               VARCHAR(64) NOT NULL CHECK (email <> '') UNIQUE,
+	pw_hash             VARCHAR(128),
+	admin               BOOLEAN NOT NULL DEFAULT false,
+	active              BOOLEAN NOT NULL DEFAULT false,
+	token               TEXT,
+	name                TEXT NOT NULL CHECK (name <> ''),
+	created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);`
+
+	dropUsersTableStr = "DROP TABLE IF EXISTS users;"
+
+	createGroupsTableStr = `
+CREATE TABLE IF NOT EXISTS groups (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	name      TEXT NOT NULL,
+	currency  CHAR(3) NOT NULL DEFAULT 'GBP'
+);`
+
+	dropGroupsTableStr = "DROP TABLE IF EXISTS groups;"
+
+	createGroupsUsersTableStr = `
+CREATE TABLE IF NOT EXISTS groups_users(
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    INTEGER REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	group_id   INTEGER REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	admin      BOOLEAN NOT NULL DEFAULT FALSE,
+	UNIQUE     (user_id, group_id)
+);`
+
+	dropGroupUserTableStr = "DROP TABLE IF EXISTS groups_users;"
+
+	createCategoriesTableStr = `
+CREATE TABLE IF NOT EXISTS categories (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	group_id   INTEGER NOT NULL REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	name       TEXT NOT NULL CHECK (name <> ''),
+	colour     CHAR(7) NOT NULL DEFAULT '#9e9e9e',
+	icon       TEXT NOT NULL DEFAULT '',
+	UNIQUE     (group_id, name)
+);`
+
+	dropCategoriesTableStr = "DROP TABLE IF EXISTS categories;"
+
+	// The category columns use %s for the category check, as SQLite has no
+	// enum types.
+	createExpensesTableStr = `
+CREATE TABLE IF NOT EXISTS expenses(
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	amount        INTEGER NOT NULL CHECK (amount >= 0),
+	currency      CHAR(3) NOT NULL DEFAULT 'GBP',
+	exchange_rate REAL NOT NULL DEFAULT 1 CHECK (exchange_rate >= 0),
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	group_id      INTEGER REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	payer_id      INTEGER REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	category      TEXT %s,
+	description   TEXT
+);`
+
+	dropExpensesTableStr = "DROP TABLE IF EXISTS expenses;"
+
+	createExpenseAssignmentsTableStr = `
+CREATE TABLE IF NOT EXISTS expense_assignments (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    INTEGER REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	amount     INTEGER NOT NULL CHECK (amount >= 0),
+	rounding   INTEGER NOT NULL DEFAULT 0 CHECK (rounding >= 0),
+	expense_id INTEGER REFERENCES expenses(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	group_id   INTEGER REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE
+);`
+
+	dropExpenseAssingmentsTableStr = "DROP TABLE IF EXISTS expense_assignments;"
+
+	createPaymentsTable = `
+CREATE TABLE IF NOT EXISTS payments (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	amount        INTEGER NOT NULL CHECK (amount >= 0),
+	currency      CHAR(3) NOT NULL DEFAULT 'GBP',
+	exchange_rate REAL NOT NULL DEFAULT 1 CHECK (exchange_rate >= 0),
+	giver_id      INTEGER REFERENCES users(id) NOT NULL,
+	receiver_id   INTEGER REFERENCES users(id) CHECK (giver_id <> receiver_id),
+	group_id      INTEGER REFERENCES groups(id)
+);`
+	dropPaymentsTableStr = "DROP TABLE IF EXISTS payments;"
+
+	createRecurringExpensesTableStr = `
+CREATE TABLE IF NOT EXISTS recurring_expenses (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	group_id    INTEGER REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	payer_id    INTEGER REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	amount      INTEGER NOT NULL CHECK (amount >= 0),
+	currency    CHAR(3) NOT NULL DEFAULT 'GBP',
+	category    TEXT %s,
+	description TEXT,
+	split       TEXT NOT NULL,
+	frequency   TEXT NOT NULL,
+	day         INTEGER NOT NULL,
+	next_due    TIMESTAMP NOT NULL,
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);`
+	dropRecurringExpensesTableStr = "DROP TABLE IF EXISTS recurring_expenses;"
+
+	createAttachmentsTableStr = `
+CREATE TABLE IF NOT EXISTS attachments (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	expense_id   INTEGER NOT NULL REFERENCES expenses(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	filename     TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size         INTEGER NOT NULL CHECK (size >= 0),
+	blob_key     TEXT NOT NULL UNIQUE,
+	created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);`
+	indexAttachmentsExpenseIDStr = `CREATE INDEX IF NOT EXISTS attachments_expense_id_idx ON attachments (expense_id);`
+	dropAttachmentsTableStr      = "DROP TABLE IF EXISTS attachments;"
+)
+
+var (
+	// Drops everything created by the migrations, in reverse order
+	dropTablesArr = []string{
+		dropAttachmentsTableStr,
+		dropRecurringExpensesTableStr,
+		dropPaymentsTableStr,
+		dropExpenseAssingmentsTableStr,
+		dropExpensesTableStr,
+		dropCategoriesTableStr,
+		dropGroupUserTableStr,
+		dropGroupsTableStr,
+		dropUsersTableStr,
+	}
+)
+
+// categoryCheck is the equivalent of the category_t enum in Postgres, used by
+// the initial schema. The category columns have since been replaced by
+// category_id, but the list must not change as it is part of a migration.
+func categoryCheck() string {
+	values := []string{
+		"groceries",
+		"alcohol",
+		"drugs",
+		"household items",
+		"bills",
+		"presents",
+		"tickets",
+		"misc",
+	}
+	return "CHECK (category IN ('" + strings.Join(values, "', '") + "'))"
+}
+
+// dialect is how SQLite differs from the SQL shared by sqlstore.
+type dialect struct{}
+
+func (dialect) Migrations() []sqlstore.Migration {
+	return migrations
+}
+
+// LockSchemaVersion is not needed, as transactions take the write lock when
+// they begin, which stops anyone else migrating at the same time.
+func (dialect) LockSchemaVersion() string {
+	return ""
+}
+
+// LockGroup is not needed, for the same reason as LockSchemaVersion.
+func (dialect) LockGroup() string {
+	return ""
+}
+
+func (dialect) DropTables() []string {
+	return dropTablesArr
+}
+
+// Time converts the expression to the same format, keeping milliseconds, as
+// SQLite stores times as text in more than one format depending on whether
+// they were given or defaulted. Colons are doubled so that sqlx does not take
+// them for named parameters.
+func (dialect) Time(expr string) string {
+	return "strftime('%Y-%m-%d %H::%M::%f', " + expr + ")"
+}
+
+func (dialect) Month(expr string) string {
+	return "strftime('%Y-%m', " + expr + ")"
+}
+
+// TextMatch returns "", as SQLite has no full text search without an
+// extension.
+func (dialect) TextMatch(column, param string) string {
+	return ""
+}
+
+type sqliteStore struct {
+	*sqlstore.Store
+}
+
+// Open opens the SQLite database file at the path given, creating it if it
+// does not exist. Foreign keys are enforced so that deletes cascade as they
+// do in Postgres, and transactions take the write lock when they begin. Only
+// one connection is used, as SQLite only allows a single writer and
+// ":memory:" databases are not shared between connections.
+func Open(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func MustCreate(d *sqlx.DB) *sqliteStore {
+	return &sqliteStore{sqlstore.New(d, dialect{})}
+}
@@ -0,0 +1,120 @@
+package sqlitestore
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"testing"
+)
+
+var (
+	db *sqlx.DB
+	s  *sqliteStore
+)
+
+func init() {
+	var err error
+	db, err = Open(":memory:")
+	if err != nil {
+		panic(err)
+	}
+	s = MustCreate(db)
+}
+
+func TestSchemaCreate(t *testing.T) {
+	err := db.Ping()
+	if err != nil {
+		t.Fatalf("Error pinging DB: %v", err)
+		return
+	}
+
+	from, to, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+		return
+	}
+	defer s.MustDropTables()
+
+	if from != 0 || to != len(migrations) {
+		t.Fatalf("Expected migration from 0 to %d, got %d to %d", len(migrations), from, to)
+		return
+	}
+
+	s.MustPrepareStmts()
+
+	from, to, err = s.Migrate()
+	if err != nil || from != to {
+		t.Fatalf("Expected migrating twice to do nothing, got %d to %d, %v", from, to, err)
+		return
+	}
+
+	version, err := s.SchemaVersion()
+	if err != nil || version != to {
+		t.Fatalf("Expected schema version %d, got %d, %v", to, version, err)
+	}
+}
+
+// TestMigrateCategories checks that expenses saved with the fixed categories
+// are moved to the categories of their group.
+func TestMigrateCategories(t *testing.T) {
+	_, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Error creating schema_version table: %v", err)
+		return
+	}
+	defer s.MustDropTables()
+
+	tx := db.MustBegin()
+	err = s.ApplyMigration(tx, migrations[0])
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("Error applying initial schema: %v", err)
+		return
+	}
+	tx.MustExec("INSERT INTO users (id, email, name) VALUES (1, 'u@example.com', 'TEST');")
+	tx.MustExec("INSERT INTO groups (id, name) VALUES (1, 'Group 1'), (2, 'Group 2');")
+	tx.MustExec(`INSERT INTO expenses (id, amount, group_id, payer_id, category) VALUES
+		(1, 100, 1, 1, 'household items'),
+		(2, 100, 2, 1, 'bills'),
+		(3, 100, 2, 1, NULL);`)
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing initial schema: %v", err)
+		return
+	}
+
+	_, _, err = s.Migrate()
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+		return
+	}
+
+	var categories []struct {
+		ID      int64  `db:"id"`
+		GroupID int64  `db:"group_id"`
+		Name    string `db:"name"`
+	}
+	err = db.Select(&categories, `
+SELECT expenses.id, categories.group_id, categories.name FROM expenses
+	INNER JOIN categories ON categories.id=expenses.category_id
+	ORDER BY expenses.id;`)
+	if err != nil {
+		t.Fatalf("Error getting categories of expenses: %v", err)
+		return
+	}
+
+	expected := []struct {
+		GroupID int64
+		Name    string
+	}{{1, "Household Items"}, {2, "Bills"}, {2, "Misc"}}
+
+	if len(categories) != len(expected) {
+		t.Fatalf("Expected %d categorised expenses, got %d", len(expected), len(categories))
+		return
+	}
+
+	for i, c := range categories {
+		if c.GroupID != expected[i].GroupID || c.Name != expected[i].Name {
+			t.Fatalf("Expected expense %d in %+v, got %+v", c.ID, expected[i], c)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package sqlitestore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models/sqlstore/storetest"
+
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Test(t, s, nil)
+}
+
+func BenchmarkStore(b *testing.B) {
+	storetest.Benchmark(b, s, nil)
+}
@@ -0,0 +1,97 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+)
+
+const (
+	insertAttachmentStr = `
+INSERT INTO attachments (expense_id, filename, content_type, size, blob_key)
+	VALUES (:expense_id, :filename, :content_type, :size, :blob_key) RETURNING *;`
+	deleteAttachmentStr     = `DELETE FROM attachments WHERE id=:id;`
+	attachmentByIDStr       = `SELECT * FROM attachments WHERE id=:id;`
+	attachmentsByExpenseStr = `SELECT * FROM attachments WHERE expense_id=:id ORDER BY created_at, id;`
+	attachmentsByGroupStr   = `
+SELECT attachments.* FROM attachments
+	INNER JOIN expenses
+		ON expenses.id=attachments.expense_id
+	WHERE expenses.group_id=:id
+	ORDER BY attachments.created_at, attachments.id;`
+	attachmentsByPayerStr = `
+SELECT attachments.* FROM attachments
+	INNER JOIN expenses
+		ON expenses.id=attachments.expense_id
+	WHERE expenses.payer_id=:id
+	ORDER BY attachments.created_at, attachments.id;`
+)
+
+func (s *Store) InsertAttachment(a *models.Attachment) error {
+	if a.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	err := s.insertAttachmentStmt.Get(a, a)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting attachment")
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteAttachment(a *models.Attachment) error {
+	r, err := s.deleteAttachmentStmt.Exec(a)
+	if err != nil {
+		return errors.Annotate(err, "Error deleting attachment")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("Attachment does not exist")
+	}
+
+	a.ID = 0
+	return nil
+}
+
+func (s *Store) AttachmentByID(id int64) (*models.Attachment, error) {
+	var a = models.Attachment{ID: id}
+	err := s.attachmentByIDStmt.Get(&a, a)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting attachment by ID")
+	}
+
+	return &a, nil
+}
+
+func (s *Store) AttachmentsByExpense(e *models.Expense) ([]*models.Attachment, error) {
+	var as []*models.Attachment
+	err := s.attachmentsByExpenseStmt.Select(&as, e)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting expense's attachments")
+	}
+
+	return as, nil
+}
+
+func (s *Store) AttachmentsByGroup(g *models.Group) ([]*models.Attachment, error) {
+	var as []*models.Attachment
+	err := s.attachmentsByGroupStmt.Select(&as, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group's attachments")
+	}
+
+	return as, nil
+}
+
+func (s *Store) AttachmentsByPayer(u *auth.User) ([]*models.Attachment, error) {
+	var as []*models.Attachment
+	err := s.attachmentsByPayerStmt.Select(&as, u)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting payer's attachments")
+	}
+
+	return as, nil
+}
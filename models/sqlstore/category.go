@@ -0,0 +1,97 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"database/sql"
+)
+
+const (
+	insertCategoryStr = `
+INSERT INTO categories (group_id, name, colour, icon, budget)
+	VALUES (:group_id, :name, :colour, :icon, :budget) RETURNING *;`
+	updateCategoryStr = `
+UPDATE categories SET
+		name=:name,
+		colour=:colour,
+		icon=:icon,
+		budget=:budget
+	WHERE id=:id;`
+	// Categories that are in use are not deleted, rather than relying on
+	// the foreign keys, so that ErrCategoryInUse can be returned.
+	deleteCategoryStr = `
+DELETE FROM categories WHERE id=:id
+	AND NOT EXISTS (SELECT 1 FROM expenses WHERE category_id=:id)
+	AND NOT EXISTS (SELECT 1 FROM recurring_expenses WHERE category_id=:id);`
+	categoryByIDStr      = `SELECT * FROM categories WHERE id=:id;`
+	categoriesByGroupStr = `SELECT * FROM categories WHERE group_id=:id ORDER BY name, id;`
+)
+
+func (s *Store) InsertCategory(c *models.Category) error {
+	if c.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	err := s.insertCategoryStmt.Get(c, c)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting category")
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateCategory(c *models.Category) error {
+	r, err := s.updateCategoryStmt.Exec(c)
+	if err != nil {
+		return errors.Annotate(err, "Error updating category")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("Invalid category ID")
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteCategory(c *models.Category) error {
+	r, err := s.deleteCategoryStmt.Exec(c)
+	if err != nil {
+		return errors.Annotate(err, "Error deleting category")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		if _, err := s.CategoryByID(c.ID); err != nil {
+			return errors.New("Category does not exist")
+		}
+		return errors.Trace(models.ErrCategoryInUse)
+	}
+
+	c.ID = 0
+	return nil
+}
+
+func (s *Store) CategoryByID(id int64) (*models.Category, error) {
+	var c = models.Category{ID: id}
+	err := s.categoryByIDStmt.Get(&c, c)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("category with id %d", id)
+	} else if err != nil {
+		return nil, errors.Annotate(err, "Error getting category by ID")
+	}
+
+	return &c, nil
+}
+
+func (s *Store) CategoriesByGroup(g *models.Group) ([]*models.Category, error) {
+	var cs []*models.Category
+	err := s.categoriesByGroupStmt.Select(&cs, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group's categories")
+	}
+
+	return cs, nil
+}
@@ -0,0 +1,883 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/juju/errors"
+)
+
+const (
+	// Group only strings
+	insertGroupStr = `INSERT INTO groups (name, currency) VALUES (:name, :currency) RETURNING *;`
+	updateGroupStr = `UPDATE groups SET name=:name, currency=:currency WHERE id=:id;`
+	deleteGroupStr = `DELETE FROM groups where id=:id;`
+	groupByIDStr   = `SELECT * FROM groups where id=:id;`
+	groupByUserStr = `
+SELECT groups.* FROM groups
+	INNER JOIN groups_users
+		ON groups_users.group_id=groups.id
+	WHERE groups_users.user_id=:id;`
+	allGroupsStr = `SELECT * FROM groups;`
+
+	membersByGroupStr = `
+SELECT users.id, users.name, users.email, groups_users.admin FROM users
+	INNER JOIN groups_users
+		ON groups_users.user_id=users.id
+	WHERE groups_users.group_id=:id
+	ORDER BY users.name, users.id;`
+
+	// Strings involving user group mappings
+	addUserToGroupStr      = `INSERT INTO groups_users (group_id, user_id, admin) VALUES (:group_id, :user_id, :admin) RETURNING *;`
+	removeUserFromGroupStr = `DELETE FROM groups_users where user_id=:user_id AND group_id=:group_id;`
+
+	// Payment strings
+	insertPaymentStr = `
+INSERT INTO payments (group_id, amount, currency, exchange_rate, giver_id, receiver_id)
+	VALUES(:group_id, :amount, :currency, :exchange_rate, :giver_id, :receiver_id) RETURNING *;`
+	updatePaymentStr = `
+UPDATE payments SET
+	group_id=:group_id,
+	amount=:amount,
+	currency=:currency,
+	exchange_rate=:exchange_rate,
+	giver_id=:giver_id,
+	receiver_id=:receiver_id
+WHERE id=:id;`
+	insertDatedPaymentStr = `
+INSERT INTO payments (group_id, amount, currency, exchange_rate, giver_id, receiver_id, created_at)
+	VALUES(:group_id, :amount, :currency, :exchange_rate, :giver_id, :receiver_id, :created_at) RETURNING *;`
+	deletePaymentStr   = `DELETE FROM payments WHERE id=:id;`
+	paymentByIDStr     = `SELECT * FROM payments WHERE id=:id;`
+	paymentsByGroupStr = `SELECT * FROM payments WHERE group_id=:id ORDER BY created_at, id;`
+
+	// Expense strings
+	insertExpeseStr = `
+INSERT INTO expenses (amount, currency, exchange_rate, payer_id, group_id, category_id, description)
+	VALUES (:amount, :currency, :exchange_rate, :payer_id, :group_id, :category_id, :description) RETURNING *;`
+	insertDatedExpenseStr = `
+INSERT INTO expenses (amount, currency, exchange_rate, payer_id, group_id, category_id, description, created_at, import_id)
+	VALUES (:amount, :currency, :exchange_rate, :payer_id, :group_id, :category_id, :description, :created_at, :import_id) RETURNING *;`
+	insertExpenseAssignmentStr = `
+INSERT INTO expense_assignments (amount, rounding, user_id, expense_id, group_id)
+	VALUES (:amount, :rounding, :user_id, :expense_id, :group_id) RETURNING *;`
+	deleteExpenseStr            = `DELETE FROM expenses WHERE id=:id;`
+	deleteExpenseAssignmentsStr = `DELETE FROM expense_assignments WHERE expense_id=:id;`
+	updateExpenseStr            = `
+UPDATE expenses set
+		amount=:amount,
+		currency=:currency,
+		exchange_rate=:exchange_rate,
+		payer_id=:payer_id,
+		group_id=:group_id,
+		category_id=:category_id,
+		description=:description
+	WHERE id=:id;`
+
+	expenseByIDStr           = `SELECT * FROM expenses WHERE id=:id;`
+	assingmentsByExpenseStr  = `SELECT * from expense_assignments WHERE expense_id=:id;`
+	expensesByGroupStr       = `SELECT * FROM expenses WHERE group_id=:id ORDER BY created_at, id;`
+	assignmentsByExpensesStr = `SELECT * FROM expense_assignments WHERE expense_id IN (?) ORDER BY expense_id, id;`
+	roundingByGroupStr       = `
+SELECT user_id, SUM(rounding) AS rounding FROM expense_assignments
+	WHERE group_id=:group_id AND expense_id<>:id
+	GROUP BY user_id;`
+)
+
+func (s *Store) InsertGroup(g *models.Group) error {
+	if g.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	if g.Currency == "" {
+		g.Currency = models.DefaultCurrency
+	}
+
+	err := s.insertGroupStmt.Get(g, g)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting group")
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateGroup(g *models.Group) error {
+	if g.Currency == "" {
+		g.Currency = models.DefaultCurrency
+	}
+
+	r, err := s.updateGroupStmt.Exec(g)
+	if err != nil {
+		return errors.Annotate(err, "Error updating group")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("Invalid group ID")
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteGroup(g *models.Group) error {
+	r, err := s.deleteGroupStmt.Exec(g)
+	if err != nil {
+		return errors.Annotate(err, "Error deleting group")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("No group deleted")
+	}
+
+	g.ID = 0
+	return nil
+}
+
+func (s *Store) GroupByID(id int64) (*models.Group, error) {
+	var g = models.Group{ID: id}
+	err := s.groupByIDStmt.Get(&g, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group by ID")
+	}
+
+	return &g, nil
+}
+
+func (s *Store) GroupsByUser(u *auth.User) ([]*models.Group, error) {
+	var groups []*models.Group
+	err := s.groupsByUserStmt.Select(&groups, u)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting user's groups")
+	}
+
+	return groups, nil
+}
+
+func (s *Store) MembersByGroup(g *models.Group) ([]*models.Member, error) {
+	var members []*models.Member
+	err := s.membersByGroupStmt.Select(&members, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group's members")
+	}
+
+	return members, nil
+}
+
+func (s *Store) AllGroups() ([]*models.Group, error) {
+	var groups []*models.Group
+	err := s.db.Select(&groups, allGroupsStr)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting all groups")
+	}
+
+	return groups, nil
+}
+
+func (s *Store) AddUserToGroup(g *models.Group, u *auth.User, admin bool) error {
+	m := models.UserGroupMap{
+		GroupID: g.ID,
+		UserID:  u.ID,
+		Admin:   admin,
+	}
+
+	err := s.addUserToGroupStmt.Get(&m, m)
+	if err != nil {
+		return errors.Annotate(err, "Error adding user to group")
+	}
+
+	if m.ID == 0 {
+		return errors.New("UserGroupMap has ID=0 after insertion")
+	}
+	return nil
+}
+
+func (s *Store) RemoveUserFromGroup(g *models.Group, u *auth.User) error {
+	m := models.UserGroupMap{
+		GroupID: g.ID,
+		UserID:  u.ID,
+	}
+
+	r, err := s.removeUserFromGroupStmt.Exec(m)
+	if err != nil {
+		return errors.Annotate(err, "Could not remove user from group")
+	}
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("User not in group")
+	}
+
+	return nil
+}
+
+func (s *Store) InsertPayment(p *models.Payment) error {
+	if p.Currency == "" {
+		p.Currency = models.DefaultCurrency
+	}
+
+	err := s.insertPaymentStmt.Get(p, p)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting payment")
+	}
+	return nil
+}
+
+func (s *Store) InsertPayments(ps []*models.Payment) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Annotate(err, "Could not create transaction")
+	}
+
+	err = s.insertPayments(ps, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		resetPaymentIDs(ps)
+		return errors.Annotate(err, "Error committing payments")
+	}
+
+	return nil
+}
+
+// insertPayments inserts the payments within the transaction. None of them
+// may have been saved already. If any fail then the IDs of the others are
+// reset, as the transaction must be rolled back.
+func (s *Store) insertPayments(ps []*models.Payment, tx *sqlx.Tx) error {
+	for _, p := range ps {
+		if p.ID != 0 {
+			return models.ErrAlreadySaved
+		}
+	}
+
+	stmt, err := tx.PrepareNamed(insertPaymentStr)
+	if err != nil {
+		return errors.Annotate(err, "Error preparing insert payment statement")
+	}
+
+	for _, p := range ps {
+		if p.Currency == "" {
+			p.Currency = models.DefaultCurrency
+		}
+
+		err = stmt.Get(p, p)
+		if err != nil {
+			resetPaymentIDs(ps)
+			return errors.Annotate(err, "Error inserting payment")
+		}
+	}
+
+	return nil
+}
+
+// SettleGroup locks the group, so that nothing can be added to it until the
+// payments from the plan have been inserted.
+func (s *Store) SettleGroup(g *models.Group, plan func([]*models.Expense, []*models.Payment) ([]*models.Payment, error)) ([]*models.Payment, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not create transaction")
+	}
+
+	err = s.lockGroup(g, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	es, err := s.expensesByGroup(g, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	var ps []*models.Payment
+	stmt, err := tx.PrepareNamed(paymentsByGroupStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "Error preparing payments by group statement")
+	}
+
+	err = stmt.Select(&ps, g)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "Error getting payments")
+	}
+
+	settle, err := plan(es, ps)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	err = s.insertPayments(settle, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		resetPaymentIDs(settle)
+		return nil, errors.Annotate(err, "Error committing payments")
+	}
+
+	return settle, nil
+}
+
+// lockGroup locks the group until the transaction ends, if the dialect needs
+// it to.
+func (s *Store) lockGroup(g *models.Group, tx *sqlx.Tx) error {
+	lock := s.dialect.LockGroup()
+	if lock == "" {
+		return nil
+	}
+
+	stmt, err := tx.PrepareNamed(lock)
+	if err != nil {
+		return errors.Annotate(err, "Error preparing lock group statement")
+	}
+
+	_, err = stmt.Exec(g)
+	return errors.Annotate(err, "Could not lock group")
+}
+
+// resetPaymentIDs marks the payments as unsaved after a failed transaction.
+func resetPaymentIDs(ps []*models.Payment) {
+	for _, p := range ps {
+		p.ID = 0
+	}
+}
+
+func (s *Store) UpdatePayment(p *models.Payment) error {
+	if p.Currency == "" {
+		p.Currency = models.DefaultCurrency
+	}
+
+	r, err := s.updatePaymentStmt.Exec(p)
+	if err != nil {
+		return errors.Annotate(err, "Could not update payment")
+	}
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("No payment with ID")
+	}
+
+	return nil
+}
+
+func (s *Store) DeletePayment(p *models.Payment) error {
+	r, err := s.deletePaymentStmt.Exec(p)
+	if err != nil {
+		return errors.Annotate(err, "Error deleting payment")
+	}
+
+	n, _ := r.RowsAffected()
+	if n != 1 {
+		return errors.New("Payment does not exist")
+	}
+
+	return nil
+}
+
+func (s *Store) PaymentByID(id int64) (*models.Payment, error) {
+	var p = models.Payment{
+		ID: id,
+	}
+
+	err := s.paymentByIDStmt.Get(&p, p)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting payment by ID")
+	}
+	return &p, nil
+}
+
+func (s *Store) PaymentsByGroup(g *models.Group) ([]*models.Payment, error) {
+	var ps []*models.Payment
+	err := s.paymentsByGroupStmt.Select(&ps, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group's payments")
+	}
+
+	return ps, nil
+}
+
+func (s *Store) InsertExpense(e *models.Expense, split models.Split) error {
+	// Assign expense and commit everything to the db within the same transaction
+	if e.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Annotate(err, "Could not create transaction")
+	}
+
+	stmt, err := tx.PrepareNamed(insertExpeseStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Error preparing insert expense statement")
+	}
+
+	eas, err := s.insertExpense(e, split, stmt, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	// Sucessfully inserted expense and assignments.
+	err = tx.Commit()
+	if err != nil {
+		return errors.Annotate(err, "Error committing to database")
+	}
+
+	e.Assignments = eas
+
+	return nil
+}
+
+// InsertHistory inserts and assigns the expenses, each with its own split,
+// along with the payments within a single transaction. The time each expense
+// and payment was created is kept.
+func (s *Store) InsertHistory(es []*models.Expense, splits []models.Split, ps []*models.Payment) error {
+	if len(es) != len(splits) {
+		return errors.Errorf("%d expenses but %d splits", len(es), len(splits))
+	}
+
+	for _, e := range es {
+		if e.ID != 0 {
+			return models.ErrAlreadySaved
+		}
+	}
+
+	for _, p := range ps {
+		if p.ID != 0 {
+			return models.ErrAlreadySaved
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Annotate(err, "Could not create transaction")
+	}
+
+	assignments, err := s.insertDatedExpenses(es, splits, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	stmt, err := tx.PrepareNamed(insertDatedPaymentStr)
+	if err != nil {
+		_ = tx.Rollback()
+		resetExpenseIDs(es)
+		return errors.Annotate(err, "Error preparing insert payment statement")
+	}
+
+	for _, p := range ps {
+		if p.Currency == "" {
+			p.Currency = models.DefaultCurrency
+		}
+
+		err = stmt.Get(p, p)
+		if err != nil {
+			_ = tx.Rollback()
+			resetExpenseIDs(es)
+			resetPaymentIDs(ps)
+			return errors.Annotate(err, "Error inserting payment")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		resetExpenseIDs(es)
+		resetPaymentIDs(ps)
+		return errors.Annotate(err, "Error committing history")
+	}
+
+	for i, e := range es {
+		e.Assignments = assignments[i]
+	}
+
+	return nil
+}
+
+// ImportExpenses locks the group, so that nothing can be added to it until
+// the expenses from the plan have been inserted.
+func (s *Store) ImportExpenses(g *models.Group, plan func([]*models.Expense) ([]*models.Expense, []models.Split, error)) ([]*models.Expense, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not create transaction")
+	}
+
+	err = s.lockGroup(g, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	existing, err := s.expensesByGroup(g, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	es, splits, err := plan(existing)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	if len(es) != len(splits) {
+		_ = tx.Rollback()
+		return nil, errors.Errorf("%d expenses but %d splits", len(es), len(splits))
+	}
+
+	for _, e := range es {
+		if e.ID != 0 {
+			_ = tx.Rollback()
+			return nil, models.ErrAlreadySaved
+		}
+	}
+
+	assignments, err := s.insertDatedExpenses(es, splits, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		resetExpenseIDs(es)
+		return nil, errors.Annotate(err, "Error committing expenses")
+	}
+
+	for i, e := range es {
+		e.Assignments = assignments[i]
+	}
+
+	return es, nil
+}
+
+// insertDatedExpenses inserts and assigns each expense with its split within
+// the transaction, keeping the time it was created. The IDs of the expenses
+// are reset if any cannot be inserted.
+func (s *Store) insertDatedExpenses(es []*models.Expense, splits []models.Split, tx *sqlx.Tx) ([][]*models.ExpenseAssignment, error) {
+	stmt, err := tx.PrepareNamed(insertDatedExpenseStr)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error preparing insert expense statement")
+	}
+
+	assignments := make([][]*models.ExpenseAssignment, len(es))
+	for i, e := range es {
+		assignments[i], err = s.insertExpense(e, splits[i], stmt, tx)
+		if err != nil {
+			resetExpenseIDs(es)
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return assignments, nil
+}
+
+// insertExpense inserts the expense using the statement given, then assigns
+// it within the transaction. The assignments are returned rather than set on
+// the expense, as they are only valid once the transaction is committed.
+func (s *Store) insertExpense(e *models.Expense, split models.Split, stmt *sqlx.NamedStmt, tx *sqlx.Tx) ([]*models.ExpenseAssignment, error) {
+	if e.Currency == "" {
+		e.Currency = models.DefaultCurrency
+	}
+
+	err := stmt.Get(e, e)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error inserting expense")
+	}
+
+	history, err := s.roundingHistory(e, split, tx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	eas, err := e.Assign(split, history)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error assigning expense")
+	}
+
+	err = s.insertExpenseAssignments(eas, tx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return eas, nil
+}
+
+// resetExpenseIDs marks the expenses as unsaved after a failed transaction.
+func resetExpenseIDs(es []*models.Expense) {
+	for _, e := range es {
+		e.ID = 0
+	}
+}
+
+func (s *Store) UpdateExpense(e *models.Expense, split models.Split) error {
+	if e.ID == 0 {
+		return models.ErrStructNotSaved
+	}
+
+	if e.Currency == "" {
+		e.Currency = models.DefaultCurrency
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Annotate(err, "Could not create transaction")
+	}
+
+	history, err := s.roundingHistory(e, split, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	eas, err := e.Assign(split, history)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Could not assign expense")
+	}
+
+	stmt, err := tx.PrepareNamed(deleteExpenseAssignmentsStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Error preparing delete expense assignments statement")
+	}
+
+	r, err := stmt.Exec(e)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Error deleting expense assignments")
+	}
+
+	n, _ := r.RowsAffected()
+	if n == 0 {
+		_ = tx.Rollback()
+		return errors.New("expense does not have any associated assignments")
+	}
+
+	stmt, err = tx.PrepareNamed(updateExpenseStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Error preparing update expense statement")
+	}
+
+	r, err = stmt.Exec(e)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Annotate(err, "Error updating expense")
+	}
+
+	err = s.insertExpenseAssignments(eas, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	// updated expense and created new assignments
+	err = tx.Commit()
+	if err != nil {
+		return errors.Annotate(err, "error committing expense update")
+	}
+
+	e.Assignments = eas
+	return nil
+}
+
+// roundingHistory obtains the pennies from rounding assigned to each user in
+// the group of the expense, ignoring the expense itself. This is only needed
+// when the split uses the round robin remainder policy.
+func (s *Store) roundingHistory(e *models.Expense, split models.Split, tx *sqlx.Tx) (models.Rounding, error) {
+	if split.Remainder != models.RemainderRoundRobin {
+		return nil, nil
+	}
+
+	stmt, err := tx.PrepareNamed(roundingByGroupStr)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error preparing rounding by group statement")
+	}
+
+	var rows []struct {
+		UserID   int64        `db:"user_id"`
+		Rounding models.Pence `db:"rounding"`
+	}
+	err = stmt.Select(&rows, e)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting rounding history")
+	}
+
+	history := make(models.Rounding)
+	for _, r := range rows {
+		history[r.UserID] = r.Rounding
+	}
+	return history, nil
+}
+
+func (s *Store) insertExpenseAssignments(eas []*models.ExpenseAssignment, tx *sqlx.Tx) error {
+	stmt, err := tx.PrepareNamed(insertExpenseAssignmentStr)
+	if err != nil {
+		return errors.Annotate(err, "Error preparing insert expense assigment statement")
+	}
+
+	for _, ea := range eas {
+		err = stmt.Get(ea, ea)
+		if err != nil {
+			return errors.Annotate(err, "Error inserting expense assignment")
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) ExpenseByID(id int64) (*models.Expense, error) {
+	e := models.Expense{
+		ID: id,
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "could not create transaction")
+	}
+
+	stmt, err := tx.PrepareNamed(expenseByIDStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "could not create expense by ID statement")
+	}
+
+	err = stmt.Get(&e, e)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "could not get expense by id")
+	}
+
+	var eas []*models.ExpenseAssignment
+	stmt, err = tx.PrepareNamed(assingmentsByExpenseStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "could not prepare assignments by expense statement")
+	}
+
+	err = stmt.Select(&eas, e)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "could not get assignments for expense")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Annotate(err, "could not commit")
+	}
+
+	e.Assignments = eas
+
+	return &e, nil
+
+}
+
+func (s *Store) ExpensesByGroup(g *models.Group) ([]*models.Expense, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "could not create transaction")
+	}
+
+	es, err := s.expensesByGroup(g, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Trace(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return es, nil
+}
+
+func (s *Store) expensesByGroup(g *models.Group, tx *sqlx.Tx) ([]*models.Expense, error) {
+	es, err := selectExpenses(tx, expensesByGroupStr, g)
+	return es, errors.Annotate(err, "Error getting group's expenses")
+}
+
+// assignmentsBatchSize is the most expense IDs selectExpenses passes in one
+// statement, as databases limit the number of parameters.
+const assignmentsBatchSize = 500
+
+// selectExpenses selects expenses using the named statement and its argument,
+// then selects the assignments of the expenses found and pairs them with
+// their expense. It is done within the transaction so that the assignments
+// are of the same expenses.
+func selectExpenses(tx *sqlx.Tx, expensesStr string, arg interface{}) ([]*models.Expense, error) {
+	var es []*models.Expense
+	stmt, err := tx.PrepareNamed(expensesStr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = stmt.Select(&es, arg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	byID := make(map[int64]*models.Expense, len(es))
+	ids := make([]int64, 0, len(es))
+	for _, e := range es {
+		e.Assignments = make([]*models.ExpenseAssignment, 0, 0)
+		byID[e.ID] = e
+		ids = append(ids, e.ID)
+	}
+
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > assignmentsBatchSize {
+			batch = batch[:assignmentsBatchSize]
+		}
+		ids = ids[len(batch):]
+
+		query, args, err := sqlx.In(assignmentsByExpensesStr, batch)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		var eas []*models.ExpenseAssignment
+		err = tx.Select(&eas, tx.Rebind(query), args...)
+		if err != nil {
+			return nil, errors.Annotate(err, "Error getting assignments of expenses")
+		}
+
+		// Pair the assignments with their expense
+		for _, ea := range eas {
+			e := byID[ea.ExpenseID]
+			e.Assignments = append(e.Assignments, ea)
+		}
+	}
+
+	return es, nil
+}
+
+func (s *Store) DeleteExpense(e *models.Expense) error {
+	r, err := s.deleteExpenseStmt.Exec(e)
+
+	// Delete expense deletes all associated assignments due to CASCADE
+	if err != nil {
+		return errors.Annotatef(err, "Could not delete expense with ID=%d", e.ID)
+	}
+
+	n, _ := r.RowsAffected()
+	if n == 0 {
+		return errors.New("Expense does not exist")
+	}
+
+	e.ID = 0
+	return nil
+}
@@ -0,0 +1,122 @@
+package sqlstore
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/juju/errors"
+
+	"fmt"
+)
+
+// Migration is a numbered change to the schema. Migrations are forward only:
+// new migrations are added to the end of the list, and a migration must never
+// be changed once it has been released.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+const (
+	createSchemaVersionTableStr = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version     INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);`
+	dropSchemaVersionTableStr = "DROP TABLE IF EXISTS schema_version;"
+
+	schemaVersionStr       = "SELECT COALESCE(MAX(version), 0) FROM schema_version;"
+	insertSchemaVersionStr = "INSERT INTO schema_version (version, description) VALUES (?, ?);"
+)
+
+// SchemaVersion returns the version of the latest migration applied to the
+// database, or 0 if no migrations have been applied.
+func (s *Store) SchemaVersion() (int, error) {
+	_, err := s.db.Exec(createSchemaVersionTableStr)
+	if err != nil {
+		return 0, errors.Annotate(err, "Could not create schema_version table")
+	}
+
+	var version int
+	err = s.db.Get(&version, schemaVersionStr)
+	return version, errors.Annotate(err, "Could not get schema version")
+}
+
+// Migrate applies any migrations of the dialect that have not yet been
+// applied to the database. All of the pending migrations are applied in a
+// single transaction, so if any of them fail the schema is left unchanged.
+// The versions before and after migrating are returned.
+func (s *Store) Migrate() (int, int, error) {
+	_, err := s.db.Exec(createSchemaVersionTableStr)
+	if err != nil {
+		return 0, 0, errors.Annotate(err, "Could not create schema_version table")
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, 0, errors.Annotate(err, "Could not create transaction")
+	}
+
+	// Stop anyone else migrating at the same time
+	if lock := s.dialect.LockSchemaVersion(); lock != "" {
+		_, err = tx.Exec(lock)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, 0, errors.Annotate(err, "Could not lock schema_version table")
+		}
+	}
+
+	var from int
+	err = tx.Get(&from, schemaVersionStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, errors.Annotate(err, "Could not get schema version")
+	}
+
+	to := from
+	for _, m := range s.dialect.Migrations() {
+		if m.Version <= from {
+			continue
+		}
+
+		err = s.ApplyMigration(tx, m)
+		if err != nil {
+			_ = tx.Rollback()
+			return from, from, errors.Trace(err)
+		}
+		to = m.Version
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return from, from, errors.Annotate(err, "Could not commit migrations")
+	}
+
+	return from, to, nil
+}
+
+// ApplyMigration executes the statements of the migration within the
+// transaction, and records that it has been applied.
+func (s *Store) ApplyMigration(tx *sqlx.Tx, m Migration) error {
+	for _, st := range m.Statements {
+		if s.debug {
+			fmt.Println("Executing: " + st + "\n")
+		}
+
+		_, err := tx.Exec(st)
+		if err != nil {
+			return errors.Annotatef(err, "Migration %d (%s) failed", m.Version, m.Description)
+		}
+	}
+
+	_, err := tx.Exec(tx.Rebind(insertSchemaVersionStr), m.Version, m.Description)
+	return errors.Annotatef(err, "Could not record migration %d", m.Version)
+}
+
+// MustMigrate applies all pending migrations, panicking on failure.
+func (s *Store) MustMigrate() {
+	_, _, err := s.Migrate()
+	if err != nil {
+		panic("Error migrating: " + err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"fmt"
+	"strings"
+	"time"
+)
+
+// queryExpensesFilterStr selects the expenses of a group matching an
+// ExpenseQuery, where filters that are zero match everything. Each of the
+// categories has its own parameter, category_0 and so on, as databases do not
+// agree on how to pass a list.
+func queryExpensesFilterStr(d Dialect, categories int) string {
+	filter := `
+group_id=:group_id
+	AND ` + d.Time("created_at") + ` >= ` + d.Time(":from") + `
+	AND ` + d.Time("created_at") + ` < ` + d.Time(":to") + `
+	AND (:payer_id = 0 OR payer_id = :payer_id)
+	AND (:participant_id = 0 OR EXISTS (
+		SELECT 1 FROM expense_assignments a WHERE a.expense_id = expenses.id AND a.user_id = :participant_id))`
+
+	if categories > 0 {
+		params := make([]string, categories)
+		for i := range params {
+			params[i] = fmt.Sprintf(":category_%d", i)
+		}
+		filter += `
+	AND category_id IN (` + strings.Join(params, ", ") + `)`
+	}
+	return filter
+}
+
+// queryPageStr selects a page of the expenses matching the query. A page
+// continues after the expense at the cursor. Expenses are ordered by id as
+// well as created_at, as they can be created at the same time, which
+// expenses_group_id_created_at_idx is built for.
+func queryPageStr(d Dialect, q models.ExpenseQuery) string {
+	cmp, dir := "<", " DESC"
+	if q.Order == models.OrderOldest {
+		cmp, dir = ">", ""
+	}
+
+	return queryExpensesFilterStr(d, len(q.CategoryIDs)) + `
+	AND (:cursor_id = 0 OR (` + d.Time("created_at") + `, id) ` + cmp + ` (` + d.Time(":cursor_created_at") + `, :cursor_id))
+	ORDER BY ` + d.Time("created_at") + dir + `, id` + dir + ` LIMIT :limit`
+}
+
+func queryExpensesStr(d Dialect, q models.ExpenseQuery) string {
+	return `SELECT * FROM expenses WHERE ` + queryPageStr(d, q) + `;`
+}
+
+// endOfTime is used as the end of the date range of queries without one.
+var endOfTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+func queryArgs(g *models.Group, q models.ExpenseQuery) map[string]interface{} {
+	to := q.To
+	if to.IsZero() {
+		to = endOfTime
+	}
+
+	var cursor models.ExpenseCursor
+	if q.Cursor != nil {
+		cursor = *q.Cursor
+	}
+
+	args := map[string]interface{}{
+		"group_id":          g.ID,
+		"from":              q.From.UTC(),
+		"to":                to.UTC(),
+		"payer_id":          q.PayerID,
+		"participant_id":    q.ParticipantID,
+		"cursor_created_at": cursor.CreatedAt.UTC(),
+		"cursor_id":         cursor.ID,
+		"limit":             q.Limit,
+	}
+
+	for i, id := range q.CategoryIDs {
+		args[fmt.Sprintf("category_%d", i)] = id
+	}
+	return args
+}
+
+func (s *Store) ExpensesByQuery(g *models.Group, q models.ExpenseQuery) ([]*models.Expense, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "could not create transaction")
+	}
+
+	// The assignments are selected by the IDs of the page, rather than by
+	// repeating the query, so they belong to exactly the expenses returned
+	es, err := selectExpenses(tx, queryExpensesStr(s.dialect, q), queryArgs(g, q))
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "Error querying expenses")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return es, nil
+}
@@ -0,0 +1,171 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"time"
+)
+
+const (
+	insertRecurringExpenseStr = `
+INSERT INTO recurring_expenses (group_id, payer_id, amount, currency, category_id, description, split, frequency, day, next_due)
+	VALUES (:group_id, :payer_id, :amount, :currency, :category_id, :description, :split, :frequency, :day, :next_due) RETURNING *;`
+	updateRecurringExpenseStr = `
+UPDATE recurring_expenses SET
+		payer_id=:payer_id,
+		amount=:amount,
+		currency=:currency,
+		category_id=:category_id,
+		description=:description,
+		split=:split,
+		frequency=:frequency,
+		day=:day,
+		next_due=:next_due
+	WHERE id=:id;`
+	deleteRecurringExpenseStr   = `DELETE FROM recurring_expenses WHERE id=:id;`
+	recurringExpenseByIDStr     = `SELECT * FROM recurring_expenses WHERE id=:id;`
+	recurringExpensesByGroupStr = `SELECT * FROM recurring_expenses WHERE group_id=:id ORDER BY next_due;`
+	dueRecurringExpensesStr     = `SELECT * FROM recurring_expenses WHERE next_due <= :now ORDER BY next_due;`
+	advanceRecurringExpenseStr  = `
+UPDATE recurring_expenses SET next_due=:next_due
+	WHERE id=:id AND next_due=:previous_due;`
+)
+
+func (s *Store) InsertRecurringExpense(r *models.RecurringExpense) error {
+	if r.ID != 0 {
+		return models.ErrAlreadySaved
+	}
+
+	if r.Currency == "" {
+		r.Currency = models.DefaultCurrency
+	}
+
+	err := s.insertRecurringExpenseStmt.Get(r, r)
+	if err != nil {
+		return errors.Annotate(err, "Error inserting recurring expense")
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateRecurringExpense(r *models.RecurringExpense) error {
+	if r.Currency == "" {
+		r.Currency = models.DefaultCurrency
+	}
+
+	res, err := s.updateRecurringExpenseStmt.Exec(r)
+	if err != nil {
+		return errors.Annotate(err, "Error updating recurring expense")
+	}
+
+	n, _ := res.RowsAffected()
+	if n != 1 {
+		return errors.New("Invalid recurring expense ID")
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteRecurringExpense(r *models.RecurringExpense) error {
+	res, err := s.deleteRecurringExpenseStmt.Exec(r)
+	if err != nil {
+		return errors.Annotate(err, "Error deleting recurring expense")
+	}
+
+	n, _ := res.RowsAffected()
+	if n != 1 {
+		return errors.New("Recurring expense does not exist")
+	}
+
+	r.ID = 0
+	return nil
+}
+
+func (s *Store) RecurringExpenseByID(id int64) (*models.RecurringExpense, error) {
+	var r = models.RecurringExpense{ID: id}
+	err := s.recurringExpenseByIDStmt.Get(&r, r)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting recurring expense by ID")
+	}
+
+	return &r, nil
+}
+
+func (s *Store) RecurringExpensesByGroup(g *models.Group) ([]*models.RecurringExpense, error) {
+	var rs []*models.RecurringExpense
+	err := s.recurringExpensesByGroupStmt.Select(&rs, g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting group's recurring expenses")
+	}
+
+	return rs, nil
+}
+
+func (s *Store) DueRecurringExpenses(now time.Time) ([]*models.RecurringExpense, error) {
+	var rs []*models.RecurringExpense
+	err := s.dueRecurringExpensesStmt.Select(&rs, map[string]interface{}{"now": now.UTC()})
+	if err != nil {
+		return nil, errors.Annotate(err, "Error getting due recurring expenses")
+	}
+
+	return rs, nil
+}
+
+func (s *Store) InsertDueExpense(r *models.RecurringExpense, next time.Time, e *models.Expense) (bool, error) {
+	if e.ID != 0 {
+		return false, models.ErrAlreadySaved
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return false, errors.Annotate(err, "Could not create transaction")
+	}
+
+	stmt, err := tx.PrepareNamed(advanceRecurringExpenseStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, errors.Annotate(err, "Error preparing advance recurring expense statement")
+	}
+
+	res, err := stmt.Exec(map[string]interface{}{
+		"id":           r.ID,
+		"next_due":     next.UTC(),
+		"previous_due": r.NextDue.UTC(),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return false, errors.Annotate(err, "Error advancing recurring expense")
+	}
+
+	n, _ := res.RowsAffected()
+	if n != 1 {
+		// Another run has already inserted the expense
+		_ = tx.Rollback()
+		return false, nil
+	}
+
+	stmt, err = tx.PrepareNamed(insertDatedExpenseStr)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, errors.Annotate(err, "Error preparing insert expense statement")
+	}
+
+	eas, err := s.insertExpense(e, r.Split, stmt, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		e.ID = 0
+		return false, errors.Trace(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		e.ID = 0
+		return false, errors.Annotate(err, "Error committing due expense")
+	}
+
+	e.Assignments = eas
+	r.NextDue = next
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"time"
+)
+
+// spendingByPayerStr totals whole expenses by payer, selecting the expenses
+// whose column matches the id parameter. The month and times are converted by
+// the dialect, as databases store timestamps differently.
+func spendingByPayerStr(d Dialect, column string) string {
+	return `
+SELECT
+	` + d.Month("created_at") + ` AS month,
+	category_id,
+	payer_id AS user_id,
+	currency,
+	SUM(amount * exchange_rate) AS amount,
+	COUNT(*) AS count
+FROM expenses
+	WHERE ` + column + `=:id AND ` + d.Time("created_at") + ` >= ` + d.Time(":from") + ` AND ` + d.Time("created_at") + ` < ` + d.Time(":to") + `
+	GROUP BY month, category_id, payer_id, currency
+	ORDER BY month, category_id, user_id, currency;`
+}
+
+// spendingByAssigneeStr totals the assignments of expenses by the user they
+// are assigned to.
+func spendingByAssigneeStr(d Dialect) string {
+	return `
+SELECT
+	` + d.Month("expenses.created_at") + ` AS month,
+	expenses.category_id,
+	expense_assignments.user_id,
+	expenses.currency,
+	SUM(expense_assignments.amount * expenses.exchange_rate) AS amount,
+	COUNT(*) AS count
+FROM expense_assignments
+	INNER JOIN expenses
+		ON expenses.id=expense_assignments.expense_id
+	WHERE expenses.group_id=:id AND ` + d.Time("expenses.created_at") + ` >= ` + d.Time(":from") + ` AND ` + d.Time("expenses.created_at") + ` < ` + d.Time(":to") + `
+	GROUP BY month, expenses.category_id, expense_assignments.user_id, expenses.currency
+	ORDER BY month, expenses.category_id, expense_assignments.user_id, expenses.currency;`
+}
+
+func spendingArgs(id int64, from, to time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"from": from.UTC(),
+		"to":   to.UTC(),
+	}
+}
+
+func (s *Store) SpendingByPayer(g *models.Group, from, to time.Time) ([]*models.SpendingTotal, error) {
+	var ts []*models.SpendingTotal
+	err := s.spendingByPayerStmt.Select(&ts, spendingArgs(g.ID, from, to))
+	if err != nil {
+		return nil, errors.Annotate(err, "Error totalling spending by payer")
+	}
+
+	return ts, nil
+}
+
+func (s *Store) SpendingByAssignee(g *models.Group, from, to time.Time) ([]*models.SpendingTotal, error) {
+	var ts []*models.SpendingTotal
+	err := s.spendingByAssigneeStmt.Select(&ts, spendingArgs(g.ID, from, to))
+	if err != nil {
+		return nil, errors.Annotate(err, "Error totalling spending by assignee")
+	}
+
+	return ts, nil
+}
+
+func (s *Store) SpendingByCategory(c *models.Category, from, to time.Time) ([]*models.SpendingTotal, error) {
+	var ts []*models.SpendingTotal
+	err := s.spendingByCategoryStmt.Select(&ts, spendingArgs(c.ID, from, to))
+	if err != nil {
+		return nil, errors.Annotate(err, "Error totalling spending by category")
+	}
+
+	return ts, nil
+}
@@ -0,0 +1,72 @@
+package sqlstore
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+)
+
+// searchExpensesFilterStr selects the expenses of a group matching an
+// ExpenseSearch, where filters that are zero match everything. The text is
+// matched using the full text search of the dialect, if it has any.
+func searchExpensesFilterStr(d Dialect) string {
+	filter := `
+group_id=:group_id
+	AND (:min_amount = 0 OR amount >= :min_amount)
+	AND (:max_amount = 0 OR amount <= :max_amount)
+	AND (:category_id = 0 OR category_id = :category_id)
+	AND (:payer_id = 0 OR payer_id = :payer_id)`
+
+	if match := d.TextMatch("description", ":text"); match != "" {
+		filter += `
+	AND (:text = '' OR ` + match + `)`
+	}
+	return filter
+}
+
+func searchExpensesStr(d Dialect) string {
+	return `SELECT * FROM expenses WHERE ` + searchExpensesFilterStr(d) + `
+	ORDER BY ` + d.Time("created_at") + ` DESC, id DESC;`
+}
+
+func searchArgs(g *models.Group, q models.ExpenseSearch) map[string]interface{} {
+	return map[string]interface{}{
+		"group_id":    g.ID,
+		"text":        q.Text,
+		"min_amount":  q.MinAmount,
+		"max_amount":  q.MaxAmount,
+		"category_id": q.CategoryID,
+		"payer_id":    q.PayerID,
+	}
+}
+
+func (s *Store) SearchExpenses(g *models.Group, q models.ExpenseSearch) ([]*models.Expense, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, errors.Annotate(err, "could not create transaction")
+	}
+
+	es, err := selectExpenses(tx, searchExpensesStr(s.dialect), searchArgs(g, q))
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, errors.Annotate(err, "Error searching expenses")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// Without full text search, keep only the expenses matching the text
+	if s.dialect.TextMatch("description", ":text") == "" {
+		matched := es[:0]
+		for _, e := range es {
+			if q.MatchesText(e.Description) {
+				matched = append(matched, e)
+			}
+		}
+		es = matched
+	}
+
+	return es, nil
+}
@@ -0,0 +1,199 @@
+// Package sqlstore implements models.Storer and auth.Storer using sqlx. The
+// SQL is shared by every database, and what differs between them is given by
+// a Dialect, which postgrestore and sqlitestore provide along with their
+// schemas.
+package sqlstore
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"fmt"
+)
+
+// Dialect is what differs between the databases Store is used with. The
+// rest of the SQL must be understood by all of them, which needs named
+// parameters, INSERT ... RETURNING and row value comparisons.
+type Dialect interface {
+	// Migrations create and change the schema, in order of version. The
+	// schema is where databases differ most, e.g. Postgres used an enum for
+	// categories where SQLite used a check.
+	Migrations() []Migration
+
+	// LockSchemaVersion is executed at the start of migrating to stop anyone
+	// else migrating at the same time. It is empty when transactions already
+	// stop them.
+	LockSchemaVersion() string
+
+	// LockGroup is executed at the start of settling up, with the group as
+	// its parameter, to stop anything being added to the group until the
+	// payments are inserted. It is empty when transactions already stop it.
+	LockGroup() string
+
+	// DropTables drops everything created by the migrations, in reverse
+	// order.
+	DropTables() []string
+
+	// Time converts a timestamp column or parameter so that it compares and
+	// sorts in order of time.
+	Time(expr string) string
+
+	// Month formats a timestamp column as YYYY-MM.
+	Month(expr string) string
+
+	// TextMatch returns a condition that the words of the text parameter are
+	// found in the column using full text search, or "" if the database has
+	// none. Text is then matched with ExpenseSearch.MatchesText instead.
+	TextMatch(column, param string) string
+}
+
+// Store keeps the models and users in a database, using the SQL of its
+// dialect where databases differ.
+type Store struct {
+	db      *sqlx.DB
+	dialect Dialect
+	debug   bool
+
+	// User statements
+	insertUserStmt  *sqlx.NamedStmt
+	userByEmailStmt *sqlx.NamedStmt
+	userByIDStmt    *sqlx.NamedStmt
+	userByTokenStmt *sqlx.NamedStmt
+	updateUserStmt  *sqlx.NamedStmt
+	deleteUserStmt  *sqlx.NamedStmt
+
+	// Group statements
+	insertGroupStmt         *sqlx.NamedStmt
+	updateGroupStmt         *sqlx.NamedStmt
+	deleteGroupStmt         *sqlx.NamedStmt
+	groupByIDStmt           *sqlx.NamedStmt
+	addUserToGroupStmt      *sqlx.NamedStmt
+	removeUserFromGroupStmt *sqlx.NamedStmt
+	groupsByUserStmt        *sqlx.NamedStmt
+	membersByGroupStmt      *sqlx.NamedStmt
+
+	// Category statements
+	insertCategoryStmt    *sqlx.NamedStmt
+	updateCategoryStmt    *sqlx.NamedStmt
+	deleteCategoryStmt    *sqlx.NamedStmt
+	categoryByIDStmt      *sqlx.NamedStmt
+	categoriesByGroupStmt *sqlx.NamedStmt
+
+	// Payment statements
+	insertPaymentStmt   *sqlx.NamedStmt
+	updatePaymentStmt   *sqlx.NamedStmt
+	deletePaymentStmt   *sqlx.NamedStmt
+	paymentByIDStmt     *sqlx.NamedStmt
+	paymentsByGroupStmt *sqlx.NamedStmt
+
+	// Expense statements
+	deleteExpenseStmt *sqlx.NamedStmt
+
+	// Spending statements
+	spendingByPayerStmt    *sqlx.NamedStmt
+	spendingByAssigneeStmt *sqlx.NamedStmt
+	spendingByCategoryStmt *sqlx.NamedStmt
+
+	// Recurring expense statements
+	insertRecurringExpenseStmt   *sqlx.NamedStmt
+	updateRecurringExpenseStmt   *sqlx.NamedStmt
+	deleteRecurringExpenseStmt   *sqlx.NamedStmt
+	recurringExpenseByIDStmt     *sqlx.NamedStmt
+	recurringExpensesByGroupStmt *sqlx.NamedStmt
+	dueRecurringExpensesStmt     *sqlx.NamedStmt
+
+	// Attachment statements
+	insertAttachmentStmt     *sqlx.NamedStmt
+	deleteAttachmentStmt     *sqlx.NamedStmt
+	attachmentByIDStmt       *sqlx.NamedStmt
+	attachmentsByExpenseStmt *sqlx.NamedStmt
+	attachmentsByGroupStmt   *sqlx.NamedStmt
+	attachmentsByPayerStmt   *sqlx.NamedStmt
+}
+
+// New creates a store using the database, which must be of the dialect
+// given. MustPrepareStmts must be called once the schema has been migrated.
+func New(d *sqlx.DB, dialect Dialect) *Store {
+	return &Store{db: d, dialect: dialect}
+}
+
+func (s *Store) MustPrepareStmts() {
+	s.insertUserStmt = s.mustPrepareStmt(insertUserStr)
+	s.userByEmailStmt = s.mustPrepareStmt(userByEmailStr)
+	s.userByIDStmt = s.mustPrepareStmt(userByIDStr)
+	s.userByTokenStmt = s.mustPrepareStmt(userByTokenStr)
+	s.deleteUserStmt = s.mustPrepareStmt(deleteUserStr)
+	s.updateUserStmt = s.mustPrepareStmt(updateUserStr)
+
+	s.insertGroupStmt = s.mustPrepareStmt(insertGroupStr)
+	s.updateGroupStmt = s.mustPrepareStmt(updateGroupStr)
+	s.deleteGroupStmt = s.mustPrepareStmt(deleteGroupStr)
+	s.groupByIDStmt = s.mustPrepareStmt(groupByIDStr)
+	s.groupsByUserStmt = s.mustPrepareStmt(groupByUserStr)
+	s.membersByGroupStmt = s.mustPrepareStmt(membersByGroupStr)
+	s.addUserToGroupStmt = s.mustPrepareStmt(addUserToGroupStr)
+	s.removeUserFromGroupStmt = s.mustPrepareStmt(removeUserFromGroupStr)
+
+	s.insertCategoryStmt = s.mustPrepareStmt(insertCategoryStr)
+	s.updateCategoryStmt = s.mustPrepareStmt(updateCategoryStr)
+	s.deleteCategoryStmt = s.mustPrepareStmt(deleteCategoryStr)
+	s.categoryByIDStmt = s.mustPrepareStmt(categoryByIDStr)
+	s.categoriesByGroupStmt = s.mustPrepareStmt(categoriesByGroupStr)
+
+	s.insertPaymentStmt = s.mustPrepareStmt(insertPaymentStr)
+	s.updatePaymentStmt = s.mustPrepareStmt(updatePaymentStr)
+	s.deletePaymentStmt = s.mustPrepareStmt(deletePaymentStr)
+	s.paymentByIDStmt = s.mustPrepareStmt(paymentByIDStr)
+	s.paymentsByGroupStmt = s.mustPrepareStmt(paymentsByGroupStr)
+
+	s.deleteExpenseStmt = s.mustPrepareStmt(deleteExpenseStr)
+
+	s.spendingByPayerStmt = s.mustPrepareStmt(spendingByPayerStr(s.dialect, "group_id"))
+	s.spendingByAssigneeStmt = s.mustPrepareStmt(spendingByAssigneeStr(s.dialect))
+	s.spendingByCategoryStmt = s.mustPrepareStmt(spendingByPayerStr(s.dialect, "category_id"))
+
+	s.insertRecurringExpenseStmt = s.mustPrepareStmt(insertRecurringExpenseStr)
+	s.updateRecurringExpenseStmt = s.mustPrepareStmt(updateRecurringExpenseStr)
+	s.deleteRecurringExpenseStmt = s.mustPrepareStmt(deleteRecurringExpenseStr)
+	s.recurringExpenseByIDStmt = s.mustPrepareStmt(recurringExpenseByIDStr)
+	s.recurringExpensesByGroupStmt = s.mustPrepareStmt(recurringExpensesByGroupStr)
+	s.dueRecurringExpensesStmt = s.mustPrepareStmt(dueRecurringExpensesStr)
+
+	s.insertAttachmentStmt = s.mustPrepareStmt(insertAttachmentStr)
+	s.deleteAttachmentStmt = s.mustPrepareStmt(deleteAttachmentStr)
+	s.attachmentByIDStmt = s.mustPrepareStmt(attachmentByIDStr)
+	s.attachmentsByExpenseStmt = s.mustPrepareStmt(attachmentsByExpenseStr)
+	s.attachmentsByGroupStmt = s.mustPrepareStmt(attachmentsByGroupStr)
+	s.attachmentsByPayerStmt = s.mustPrepareStmt(attachmentsByPayerStr)
+}
+
+func (s *Store) mustPrepareStmt(stmt string) *sqlx.NamedStmt {
+	if s.debug {
+		fmt.Println("Preparing: " + stmt)
+	}
+	return s.mustPrepare(s.db.PrepareNamed(stmt))
+}
+
+func (s *Store) mustPrepare(stmt *sqlx.NamedStmt, err error) *sqlx.NamedStmt {
+	if err != nil {
+		panic("Error during prepare: " + err.Error())
+	}
+	return stmt
+}
+
+// MustDropTables drops everything created by the migrations, along with the
+// record of which have been applied.
+func (s *Store) MustDropTables() {
+	s.MustExecuteStatements([]string{dropSchemaVersionTableStr})
+	s.MustExecuteStatements(s.dialect.DropTables())
+}
+
+// MustExecuteStatements executes each of the statements in turn, panicking
+// on failure.
+func (s *Store) MustExecuteStatements(statements []string) {
+	for _, st := range statements {
+		if s.debug {
+			fmt.Println("Executing: " + st + "\n")
+		}
+		s.db.MustExec(st)
+	}
+}
@@ -0,0 +1,16 @@
+package sqlstore
+
+import (
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+)
+
+func TestMustPrepare(t *testing.T) {
+	s := New(sqlx.MustOpen("sqlite3", ":memory:"), nil)
+	Convey("Attempt to prepare an invalid SQL string", t, func() {
+		So(func() { s.mustPrepareStmt("INVALID SQL") }, ShouldPanic)
+	})
+}
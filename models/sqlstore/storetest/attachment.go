@@ -0,0 +1,114 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"testing"
+)
+
+func testAttachmentCrud(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Attachment group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "attachment@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Bills", Colour: models.DefaultColour}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	e := &models.Expense{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 1250, Description: "Dinner"}
+	err = st.InsertExpense(e, models.EqualSplit([]int64{u.ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+		return
+	}
+
+	a := &models.Attachment{
+		ExpenseID:   e.ID,
+		Filename:    "receipt.png",
+		ContentType: "image/png",
+		Size:        2048,
+		Key:         "receipts/1/abc",
+	}
+
+	err = st.InsertAttachment(a)
+	if err != nil {
+		t.Fatalf("Error inserting attachment: %v", err)
+		return
+	}
+
+	if a.ID == 0 || a.CreatedAt.IsZero() {
+		t.Fatalf("Expected attachment to be given an ID and creation time, got %+v", a)
+		return
+	}
+
+	err = st.InsertAttachment(&models.Attachment{ExpenseID: e.ID, Filename: "copy.png", ContentType: "image/png", Key: a.Key})
+	if err == nil {
+		t.Fatalf("Expected error inserting attachment with a duplicate key")
+		return
+	}
+
+	a2, err := st.AttachmentByID(a.ID)
+	if err != nil || a2.Key != a.Key || a2.Filename != a.Filename || a2.ContentType != a.ContentType || a2.Size != a.Size {
+		t.Fatalf("Expected attachment %+v, got %+v (err=%v)", a, a2, err)
+		return
+	}
+
+	as, err := st.AttachmentsByExpense(e)
+	if err != nil || len(as) != 1 {
+		t.Fatalf("Expected 1 attachment of expense, got %d (err=%v)", len(as), err)
+		return
+	}
+
+	as, err = st.AttachmentsByGroup(g)
+	if err != nil || len(as) != 1 || as[0].ID != a.ID {
+		t.Fatalf("Expected the attachment of the group, got %d (err=%v)", len(as), err)
+		return
+	}
+
+	as, err = st.AttachmentsByPayer(u)
+	if err != nil || len(as) != 1 || as[0].ID != a.ID {
+		t.Fatalf("Expected the attachment of the payer, got %d (err=%v)", len(as), err)
+		return
+	}
+
+	// Attachments are deleted along with their expense
+	err = st.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+		return
+	}
+
+	_, err = st.AttachmentByID(a.ID)
+	if err == nil {
+		t.Fatalf("Expected error getting attachment of deleted expense")
+		return
+	}
+
+	err = st.DeleteAttachment(a)
+	if err == nil {
+		t.Fatalf("Expected error deleting attachment twice")
+		return
+	}
+}
@@ -0,0 +1,779 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"testing"
+	"time"
+)
+
+func testPaymentCrud(st Store, t *testing.T) {
+	t.Log("Create necessary group and users")
+	u := &auth.User{
+		Email:  "hello@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err := st.Insert(u)
+
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+		return
+	}
+
+	u2 := &auth.User{
+		Email:  "hello2@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u2)
+	if err != nil {
+		t.Fatalf("Error creating user %v", err)
+		return
+	}
+
+	g := &models.Group{
+		Name: "test group",
+	}
+
+	err = st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error creating group %v", err)
+		return
+	}
+
+	t.Log("Creating payment")
+	p := &models.Payment{
+		GroupID:    g.ID,
+		GiverID:    u.ID,
+		ReceiverID: u2.ID,
+		Amount:     100,
+	}
+
+	err = st.InsertPayment(p)
+	if err != nil {
+		t.Fatalf("Error inserting payment: %v", err)
+		return
+	}
+
+	t.Log("Create and save a second payment with same information")
+	p.ID = 0
+	err = st.InsertPayment(p)
+	if err != nil {
+		t.Fatalf("Error inserting payment: %v", err)
+		return
+	}
+
+	ps, err := st.PaymentsByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group payments: %v", err)
+		return
+	}
+
+	if len(ps) != 2 {
+		t.Fatalf("Expected 2 payments in group, got %d", len(ps))
+		return
+	}
+
+	t.Log("Inserting multiple payments where one is invalid")
+	err = st.InsertPayments([]*models.Payment{
+		{GroupID: g.ID, GiverID: u.ID, ReceiverID: u2.ID, Amount: 100},
+		{GroupID: g.ID, GiverID: u.ID, ReceiverID: u.ID, Amount: 100},
+	})
+	if err == nil {
+		t.Fatalf("Expected error inserting payment to self")
+		return
+	}
+
+	ps, err = st.PaymentsByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group payments: %v", err)
+		return
+	}
+
+	if len(ps) != 2 {
+		t.Fatalf("Expected no payments saved after failure, got %d in group", len(ps))
+		return
+	}
+
+	t.Log("Settling the group with a plan that fails")
+	_, err = st.SettleGroup(g, func(es []*models.Expense, ps []*models.Payment) ([]*models.Payment, error) {
+		return nil, models.ErrPlanChanged
+	})
+	if errors.Cause(err) != models.ErrPlanChanged {
+		t.Fatalf("Expected the error of the plan, got %v", err)
+		return
+	}
+
+	t.Log("Settling the group")
+	settled, err := st.SettleGroup(g, func(es []*models.Expense, ps []*models.Payment) ([]*models.Payment, error) {
+		if len(ps) != 2 {
+			t.Errorf("Expected to plan with 2 payments, got %d", len(ps))
+		}
+		return []*models.Payment{{GroupID: g.ID, GiverID: u2.ID, ReceiverID: u.ID, Amount: 200}}, nil
+	})
+	if err != nil || len(settled) != 1 || settled[0].ID == 0 {
+		t.Fatalf("Expected 1 settle up payment to be saved, got %v, %v", settled, err)
+		return
+	}
+
+	err = st.DeletePayment(settled[0])
+	if err != nil {
+		t.Fatalf("Error deleting settle up payment: %v", err)
+		return
+	}
+
+	p.Amount = 200
+	err = st.UpdatePayment(p)
+	if err != nil {
+		t.Fatalf("Error updating payment: %v", err)
+		return
+	}
+
+	p2, err := st.PaymentByID(p.ID)
+	if err != nil {
+		t.Fatalf("Error getting payment by ID: %v", err)
+		return
+	}
+
+	if p.Amount != p2.Amount {
+		t.Fatalf("Expected payments to have the same amount")
+		return
+	}
+
+	err = st.DeletePayment(p)
+	if err != nil {
+		t.Fatalf("Error deleting payment: %v", err)
+		return
+	}
+
+	p, err = st.PaymentByID(p2.ID)
+	if err == nil {
+		t.Fatalf("Expected error when getting deleted payment")
+		return
+	}
+
+	err = st.UpdatePayment(p2)
+	if err == nil {
+		t.Fatalf("Expected error updating deleted payment")
+		return
+	}
+}
+
+func testExpenseCrud(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "TestGroup",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	c1 := &models.Category{GroupID: g.ID, Name: "Drugs", Colour: models.DefaultColour}
+	c2 := &models.Category{GroupID: g.ID, Name: "Presents", Colour: models.DefaultColour}
+	for _, c := range []*models.Category{c1, c2} {
+		err = st.InsertCategory(c)
+		if err != nil {
+			t.Fatalf("Error inserting category: %v", err)
+			return
+		}
+	}
+
+	u1 := &auth.User{
+		Email:  "u1@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	u2 := &auth.User{
+		Email:  "u2@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u1)
+
+	if err != nil {
+		t.Fatalf("Could not insert user: %v", err)
+		return
+	}
+
+	err = st.Insert(u2)
+	if err != nil {
+		t.Fatalf("Could not insert user: %v", err)
+		return
+	}
+
+	err = st.AddUserToGroup(g, u1, false)
+	if err != nil {
+		t.Fatalf("Error adding user to group: %v", err)
+		return
+	}
+
+	err = st.AddUserToGroup(g, u2, false)
+	if err != nil {
+		t.Fatalf("error adding user to group: %v", err)
+		return
+	}
+
+	e1 := &models.Expense{
+		CategoryID:  c1.ID,
+		Amount:      100,
+		GroupID:     g.ID,
+		Description: "Test Expense 1",
+		PayerID:     u1.ID,
+	}
+
+	var allIDs models.Split = models.EqualSplit([]int64{u1.ID, u2.ID})
+	t.Log(allIDs)
+	var oneID models.Split = models.EqualSplit([]int64{u1.ID})
+
+	err = st.InsertExpense(e1, allIDs)
+	if err != nil {
+		t.Fatalf("error inserting expense: %v", err)
+		return
+	}
+
+	if len(e1.Assignments) != 2 {
+		t.Fatalf("Expected 2 assignments, got %d", len(e1.Assignments))
+		return
+	}
+
+	if e1.Assignments[0].Amount != 50 && e1.Assignments[1].Amount != 50 {
+		t.Fatalf("Assigned amounts should be 50, got: %d and %d", e1.Assignments[0].Amount, e1.Assignments[0].Amount)
+		return
+	}
+
+	err = st.UpdateExpense(e1, oneID)
+	if err != nil {
+		t.Fatalf("Error updating expense: %v", err)
+		return
+	}
+
+	if len(e1.Assignments) != 1 {
+		t.Fatalf("Expected 1 assignment, got %d", len(e1.Assignments))
+	}
+
+	e2 := &models.Expense{
+		CategoryID:  c2.ID,
+		Amount:      100,
+		GroupID:     g.ID,
+		Description: "Test expense 2",
+		PayerID:     u2.ID,
+	}
+
+	err = st.InsertExpense(e2, allIDs)
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+		return
+	}
+
+	es, err := st.ExpensesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group expenses: %+v", err)
+		return
+	}
+
+	if len(es) != 2 {
+		t.Fatalf("Expected 2 expenses, got %d", len(es))
+		return
+	}
+
+	if es[0].Amount != 100 {
+		t.Fatalf("Expense should have Amount £1, got %s", es[0].Amount)
+		return
+	}
+
+	if es[1].Amount != 100 {
+		t.Fatalf("Expense should have Amount £1, got %s", es[1].Amount)
+		return
+	}
+	e1ID := e1.ID
+	err = st.DeleteExpense(e1)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+		return
+	}
+
+	err = st.DeleteExpense(&models.Expense{ID: e1ID})
+	if err == nil {
+		t.Fatalf("Should have error when deleting deleted expense")
+		return
+	}
+
+	e3, err := st.ExpenseByID(e2.ID)
+	if err != nil {
+		t.Fatalf("Error getting expense: %v", err)
+		return
+	}
+
+	if e3.GroupID != e2.GroupID {
+		t.Fatalf("Expense group IDs do not match: %d vs %d", e2.GroupID, e3.GroupID)
+		return
+	}
+
+	_, err = st.ExpenseByID(e1ID)
+	if err == nil {
+		t.Fatalf("Expected error getting deleted expense")
+		return
+	}
+
+	es, err = st.ExpensesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group expenses :%v", err)
+		return
+	}
+
+	if len(es) != 1 {
+		t.Fatalf("Should be 1 expense in group, got %v", err)
+		return
+	}
+}
+
+func testGroupCrud(st Store, t *testing.T) {
+	t.Log("Creating group")
+	g := &models.Group{
+		Name: "Test group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	if g.ID == 0 {
+		t.Fatalf("ID of saved group==0")
+		return
+	}
+
+	g2, err := st.GroupByID(g.ID)
+	if err != nil {
+		t.Fatalf("Could not get group by err=%v", err)
+		return
+	}
+
+	if g.ID != g2.ID || g.Name != g2.Name {
+		t.Fatalf("Group retrieved by ID does not match group inserted. g1=%+v, g2=%+v", g, g2)
+		return
+	}
+
+	g.Name = "Updated group name"
+	err = st.UpdateGroup(g)
+	if err != nil {
+		t.Fatalf("Error updating group: %v", err)
+		return
+	}
+
+	g2, err = st.GroupByID(g.ID)
+	if err != nil {
+		t.Fatalf("Error getting group with updated name: %v", err)
+		return
+	}
+
+	if g2.Name != g.Name {
+		t.Fatalf("Name different when getting updated group: g1=%+v, g2=%+v", g, g2)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "test@example.com",
+		PwHash: "hash",
+		Token:  "token",
+		Active: true,
+		Admin:  false,
+		Name:   "TEST",
+	}
+
+	err = st.AddUserToGroup(g, u, true)
+	if err == nil {
+		t.Fatalf("Expected error adding non saved user to group")
+		return
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error saving user: %v", err)
+		return
+	}
+
+	err = st.AddUserToGroup(g, u, true)
+	if err != nil {
+		t.Fatalf("Error adding user to group: %v", err)
+		return
+	}
+
+	err = st.AddUserToGroup(g, u, true)
+	if err == nil {
+		t.Fatalf("Expected error adding user to group twice")
+		return
+	}
+
+	groups, err := st.GroupsByUser(u)
+	if err != nil {
+		t.Fatalf("Error added to group %v", err)
+		return
+	}
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+		return
+	}
+
+	if groups[0].Name != "Updated group name" {
+		t.Fatalf("Expected group name as 'Updated group name', but got %s", groups[0].Name)
+		return
+	}
+
+	members, err := st.MembersByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group's members: %v", err)
+		return
+	}
+
+	if len(members) != 1 || members[0].ID != u.ID || !members[0].Admin {
+		t.Fatalf("Expected only admin %d in group, got %+v", u.ID, members)
+		return
+	}
+
+	err = st.RemoveUserFromGroup(g, u)
+	if err != nil {
+		t.Fatalf("Error removing user from group: %v", err)
+		return
+	}
+
+	err = st.RemoveUserFromGroup(g, u)
+	if err == nil {
+		t.Fatal("Expected error removing user from group twice", err)
+		return
+	}
+
+	err = st.DeleteGroup(g)
+	if err != nil {
+		t.Fatalf("Error deleting group: %v", err)
+		return
+	}
+
+	g.Name = ""
+	g.ID = 0
+	g, err = st.GroupByID(g2.ID)
+	if err == nil {
+		t.Errorf("No error getting deleted group")
+		return
+	}
+}
+
+func benchmarkExpenseCreation(st Store, b *testing.B) {
+	g := &models.Group{
+		Name: "Benchmark group",
+	}
+	st.InsertGroup(g)
+	c := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	st.InsertCategory(c)
+
+	u := &auth.User{
+		Email: "Benchmark User",
+	}
+
+	st.Insert(u)
+	st.AddUserToGroup(g, u, false)
+	b.ResetTimer()
+	uIDs := models.EqualSplit([]int64{u.ID})
+	for i := 0; i < b.N; i++ {
+		st.InsertExpense(&models.Expense{
+			PayerID:     u.ID,
+			Amount:      models.Pence(int64(b.N)),
+			GroupID:     g.ID,
+			CategoryID:  c.ID,
+			Description: "TEST EXPENSE",
+		}, uIDs)
+	}
+}
+
+func benchmarkExpenseRetrieval(st Store, b *testing.B) {
+	g := &models.Group{
+		Name: "Benchmark group",
+	}
+	st.InsertGroup(g)
+	c := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	st.InsertCategory(c)
+
+	u1 := &auth.User{
+		Email: "Benchmark User 1",
+		Name:  "TEST",
+	}
+
+	u2 := &auth.User{
+		Email: "Benchmark User 2",
+		Name:  "TEST",
+	}
+
+	u3 := &auth.User{
+		Email: "Benchmark User 3",
+		Name:  "TEST",
+	}
+
+	st.Insert(u1)
+	st.Insert(u2)
+	st.Insert(u3)
+	st.AddUserToGroup(g, u1, false)
+	st.AddUserToGroup(g, u2, false)
+	st.AddUserToGroup(g, u3, false)
+	uIDs := models.EqualSplit([]int64{u1.ID, u2.ID, u3.ID})
+	for i := 0; i < 1000; i++ {
+		st.InsertExpense(&models.Expense{
+			PayerID:     uIDs.Shares[i%3].UserID,
+			Amount:      5000,
+			GroupID:     g.ID,
+			CategoryID:  c.ID,
+			Description: "TEST EXPENSE",
+		}, uIDs)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st.ExpensesByGroup(g)
+	}
+}
+
+func testCategoryCrud(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Category group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "category@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Bills", Colour: "#2196f3", Icon: "file-text"}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	err = st.InsertCategory(&models.Category{GroupID: g.ID, Name: "Bills", Colour: models.DefaultColour})
+	if err == nil {
+		t.Fatalf("Expected error inserting category with duplicate name")
+		return
+	}
+
+	c.Name = "Utilities"
+	c.Budget = 40000
+	err = st.UpdateCategory(c)
+	if err != nil {
+		t.Fatalf("Error updating category: %v", err)
+		return
+	}
+
+	cs, err := st.CategoriesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting categories: %v", err)
+		return
+	}
+
+	if len(cs) != 1 || *cs[0] != *c {
+		t.Fatalf("Expected category %+v, got %+v", c, cs)
+		return
+	}
+
+	e := &models.Expense{
+		CategoryID: c.ID,
+		Amount:     100,
+		GroupID:    g.ID,
+		PayerID:    u.ID,
+	}
+
+	err = st.InsertExpense(e, models.EqualSplit([]int64{u.ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+		return
+	}
+
+	err = st.DeleteCategory(c)
+	if errors.Cause(err) != models.ErrCategoryInUse {
+		t.Fatalf("Expected ErrCategoryInUse deleting used category, got %v", err)
+		return
+	}
+
+	err = st.DeleteExpense(e)
+	if err != nil {
+		t.Fatalf("Error deleting expense: %v", err)
+		return
+	}
+
+	err = st.DeleteCategory(c)
+	if err != nil {
+		t.Fatalf("Error deleting category: %v", err)
+		return
+	}
+
+	_, err = st.CategoryByID(cs[0].ID)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected not found getting deleted category, got %v", err)
+	}
+}
+
+func testInsertHistory(st Store, t *testing.T) {
+	u := &auth.User{
+		Email:  "import@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err := st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	u2 := &auth.User{
+		Email:  "import2@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u2)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	g := &models.Group{
+		Name: "Import group",
+	}
+
+	err = st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Bills", Colour: models.DefaultColour}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	split := models.EqualSplit([]int64{u.ID})
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+	es := []*models.Expense{
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 100, Description: "First", CreatedAt: date},
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID + 1000, Amount: 200, Description: "Second", CreatedAt: date},
+	}
+
+	splits := []models.Split{split, split}
+	err = st.InsertHistory(es, splits, nil)
+	if err == nil {
+		t.Fatalf("Expected error inserting expense with unknown category")
+		return
+	}
+
+	saved, err := st.ExpensesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group expenses: %v", err)
+		return
+	}
+
+	if len(saved) != 0 || es[0].ID != 0 {
+		t.Fatalf("Expected no expenses saved after failure, got %d", len(saved))
+		return
+	}
+
+	es[1].CategoryID = c.ID
+	ps := []*models.Payment{{GroupID: g.ID, GiverID: u2.ID, ReceiverID: u.ID, Amount: 50, CreatedAt: date}}
+	err = st.InsertHistory(es, splits, ps)
+	if err != nil {
+		t.Fatalf("Error inserting history: %v", err)
+		return
+	}
+
+	payments, err := st.PaymentsByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group payments: %v", err)
+		return
+	}
+
+	if len(payments) != 1 || !payments[0].CreatedAt.Equal(date) {
+		t.Fatalf("Expected 1 payment created at %s, got %+v", date, payments)
+		return
+	}
+
+	saved, err = st.ExpensesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group expenses: %v", err)
+		return
+	}
+
+	if len(saved) != 2 {
+		t.Fatalf("Expected 2 expenses, got %d", len(saved))
+		return
+	}
+
+	for _, e := range saved {
+		if !e.CreatedAt.Equal(date) {
+			t.Fatalf("Expected expense created at %s, got %s", date, e.CreatedAt)
+			return
+		}
+
+		if len(e.Assignments) != 1 || e.Assignments[0].Amount != e.Amount {
+			t.Fatalf("Expected expense %d to be assigned in full, got %+v", e.ID, e.Assignments)
+			return
+		}
+	}
+
+	t.Log("Importing expenses with a plan that fails")
+	_, err = st.ImportExpenses(g, func(es []*models.Expense) ([]*models.Expense, []models.Split, error) {
+		return nil, nil, models.ErrPlanChanged
+	})
+	if errors.Cause(err) != models.ErrPlanChanged {
+		t.Fatalf("Expected the error of the plan, got %v", err)
+		return
+	}
+
+	t.Log("Importing expenses")
+	imported, err := st.ImportExpenses(g, func(es []*models.Expense) ([]*models.Expense, []models.Split, error) {
+		if len(es) != 2 {
+			t.Errorf("Expected to plan with 2 expenses, got %d", len(es))
+		}
+		e := &models.Expense{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 300, Description: "Third", CreatedAt: date, ImportID: "3"}
+		return []*models.Expense{e}, []models.Split{split}, nil
+	})
+	if err != nil || len(imported) != 1 || imported[0].ID == 0 || len(imported[0].Assignments) != 1 {
+		t.Fatalf("Expected 1 imported expense to be saved, got %v, %v", imported, err)
+		return
+	}
+
+	saved, err = st.ExpensesByGroup(g)
+	if err != nil {
+		t.Fatalf("Error getting group expenses: %v", err)
+		return
+	}
+
+	if len(saved) != 3 {
+		t.Fatalf("Expected 3 expenses, got %d", len(saved))
+		return
+	}
+}
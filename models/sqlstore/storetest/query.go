@@ -0,0 +1,123 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"reflect"
+	"testing"
+	"time"
+)
+
+func queryDescriptions(es []*models.Expense) []string {
+	ds := make([]string, 0, len(es))
+	for _, e := range es {
+		ds = append(ds, e.Description)
+	}
+	return ds
+}
+
+func testExpensesByQuery(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Query group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{Email: "query@example.com", PwHash: "hash", Name: "TEST"}
+	u2 := &auth.User{Email: "query2@example.com", PwHash: "hash", Name: "TEST2"}
+	for _, user := range []*auth.User{u, u2} {
+		err = st.Insert(user)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+			return
+		}
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	c2 := &models.Category{GroupID: g.ID, Name: "Alcohol", Colour: models.DefaultColour}
+	for _, cat := range []*models.Category{c, c2} {
+		err = st.InsertCategory(cat)
+		if err != nil {
+			t.Fatalf("Error inserting category: %v", err)
+			return
+		}
+	}
+
+	// Two of the expenses are created at the same time, so are ordered by ID
+	feb := time.Date(2016, time.February, 5, 9, 0, 0, 0, time.UTC)
+	es := []*models.Expense{
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 100, Description: "January", CreatedAt: time.Date(2016, time.January, 10, 12, 0, 0, 0, time.UTC)},
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 200, Description: "Early February", CreatedAt: feb},
+		{GroupID: g.ID, PayerID: u2.ID, CategoryID: c2.ID, Amount: 300, Description: "Also early February", CreatedAt: feb},
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 400, Description: "March", CreatedAt: time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	splits := []models.Split{
+		models.EqualSplit([]int64{u.ID}),
+		models.EqualSplit([]int64{u.ID, u2.ID}),
+		models.EqualSplit([]int64{u2.ID}),
+		models.EqualSplit([]int64{u.ID}),
+	}
+
+	err = st.InsertHistory(es, splits, nil)
+	if err != nil {
+		t.Fatalf("Error inserting history: %v", err)
+		return
+	}
+
+	// Created now by the database, which stores the time differently
+	today := &models.Expense{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 500, Description: "Today"}
+	err = st.InsertExpense(today, models.EqualSplit([]int64{u.ID, u2.ID}))
+	if err != nil {
+		t.Fatalf("Error inserting expense: %v", err)
+		return
+	}
+
+	tests := []struct {
+		query    models.ExpenseQuery
+		expected []string
+	}{
+		{models.ExpenseQuery{Limit: 10}, []string{"Today", "March", "Also early February", "Early February", "January"}},
+		{models.ExpenseQuery{Order: models.OrderOldest, Limit: 10}, []string{"January", "Early February", "Also early February", "March", "Today"}},
+		{models.ExpenseQuery{From: time.Date(2016, time.February, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC), Limit: 10}, []string{"Also early February", "Early February"}},
+		{models.ExpenseQuery{From: time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC), Limit: 10}, []string{"Today", "March"}},
+		{models.ExpenseQuery{CategoryIDs: []int64{c2.ID}, Limit: 10}, []string{"Also early February"}},
+		{models.ExpenseQuery{CategoryIDs: []int64{c.ID, c2.ID}, PayerID: u2.ID, Limit: 10}, []string{"Also early February"}},
+		{models.ExpenseQuery{ParticipantID: u2.ID, Limit: 10}, []string{"Today", "Also early February", "Early February"}},
+		{models.ExpenseQuery{Limit: 2}, []string{"Today", "March"}},
+		{models.ExpenseQuery{Cursor: &models.ExpenseCursor{CreatedAt: feb, ID: es[2].ID}, Limit: 2}, []string{"Early February", "January"}},
+		{models.ExpenseQuery{Order: models.OrderOldest, Cursor: &models.ExpenseCursor{CreatedAt: feb, ID: es[1].ID}, Limit: 2}, []string{"Also early February", "March"}},
+		{models.ExpenseQuery{Order: models.OrderOldest, Cursor: &models.ExpenseCursor{CreatedAt: es[3].CreatedAt, ID: es[3].ID}, Limit: 2}, []string{"Today"}},
+	}
+
+	for _, test := range tests {
+		found, err := st.ExpensesByQuery(g, test.query)
+		if err != nil {
+			t.Fatalf("Error querying expenses for %+v: %v", test.query, err)
+			return
+		}
+
+		if !reflect.DeepEqual(queryDescriptions(found), test.expected) {
+			t.Fatalf("Expected %v for %+v, got %v", test.expected, test.query, queryDescriptions(found))
+			return
+		}
+
+		for _, e := range found {
+			if len(e.Assignments) == 0 || e.Assignments[0].ExpenseID != e.ID {
+				t.Fatalf("Expected %q to have its assignments, got %+v", e.Description, e.Assignments)
+				return
+			}
+		}
+	}
+
+	// The cursor of an expense created by the database continues after it
+	found, err := st.ExpensesByQuery(g, models.ExpenseQuery{Cursor: &models.ExpenseCursor{CreatedAt: today.CreatedAt, ID: today.ID}, Limit: 1})
+	if err != nil || len(found) != 1 || found[0].Description != "March" {
+		t.Fatalf("Expected March after Today, got %v (err=%v)", queryDescriptions(found), err)
+		return
+	}
+}
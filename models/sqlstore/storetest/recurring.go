@@ -0,0 +1,119 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"testing"
+	"time"
+)
+
+func testRecurringExpenseCrud(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Recurring group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "recurring@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Bills", Colour: models.DefaultColour}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	due := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := &models.RecurringExpense{
+		GroupID:     g.ID,
+		PayerID:     u.ID,
+		Amount:      799,
+		CategoryID:  c.ID,
+		Description: "Netflix",
+		Split:       models.EqualSplit([]int64{u.ID}),
+		Frequency:   models.FrequencyMonthly,
+		Day:         1,
+		NextDue:     due,
+	}
+
+	err = st.InsertRecurringExpense(r)
+	if err != nil {
+		t.Fatalf("Error inserting recurring expense: %v", err)
+		return
+	}
+
+	rs, err := st.DueRecurringExpenses(due.AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatalf("Error getting due recurring expenses: %v", err)
+		return
+	}
+
+	if len(rs) != 0 {
+		t.Fatalf("Expected no recurring expenses due, got %d", len(rs))
+		return
+	}
+
+	rs, err = st.DueRecurringExpenses(due)
+	if err != nil {
+		t.Fatalf("Error getting due recurring expenses: %v", err)
+		return
+	}
+
+	if len(rs) != 1 || len(rs[0].Split.Shares) != 1 {
+		t.Fatalf("Expected 1 recurring expense due with its split, got %+v", rs)
+		return
+	}
+
+	stale := *rs[0]
+	e := &models.Expense{GroupID: g.ID, PayerID: u.ID, Amount: 799, CategoryID: c.ID, Description: "Netflix", CreatedAt: due}
+	ok, err := st.InsertDueExpense(rs[0], r.NextAfter(due), e)
+	if err != nil || !ok {
+		t.Fatalf("Expected to insert due expense, got %v (err=%v)", ok, err)
+		return
+	}
+
+	if e.ID == 0 || len(e.Assignments) != 1 || !rs[0].NextDue.Equal(r.NextAfter(due)) {
+		t.Fatalf("Expected expense to be assigned and recurring expense advanced, got %+v, %+v", e, rs[0])
+		return
+	}
+
+	saved, err := st.ExpenseByID(e.ID)
+	if err != nil || !saved.CreatedAt.Equal(due) {
+		t.Fatalf("Expected expense created when it was due, got %+v (err=%v)", saved, err)
+		return
+	}
+
+	again := &models.Expense{GroupID: g.ID, PayerID: u.ID, Amount: 799, CategoryID: c.ID, Description: "Netflix", CreatedAt: due}
+	ok, err = st.InsertDueExpense(&stale, r.NextAfter(due), again)
+	if err != nil || ok || again.ID != 0 {
+		t.Fatalf("Expected not to insert due expense twice, got %v (err=%v)", ok, err)
+		return
+	}
+
+	err = st.DeleteRecurringExpense(r)
+	if err != nil {
+		t.Fatalf("Error deleting recurring expense: %v", err)
+		return
+	}
+
+	_, err = st.RecurringExpenseByID(stale.ID)
+	if err == nil {
+		t.Fatalf("Expected error getting deleted recurring expense")
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"testing"
+	"time"
+)
+
+func testSpending(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Spending group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	var us []*auth.User
+	for _, email := range []string{"spender1@example.com", "spender2@example.com"} {
+		u := &auth.User{Email: email, PwHash: "hash", Name: "TEST"}
+		err = st.Insert(u)
+		if err != nil {
+			t.Fatalf("Error inserting user: %v", err)
+			return
+		}
+		us = append(us, u)
+	}
+	u1, u2 := us[0], us[1]
+
+	c1 := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	c2 := &models.Category{GroupID: g.ID, Name: "Bills", Colour: models.DefaultColour}
+	for _, c := range []*models.Category{c1, c2} {
+		err = st.InsertCategory(c)
+		if err != nil {
+			t.Fatalf("Error inserting category: %v", err)
+			return
+		}
+	}
+
+	march := time.Date(2016, 3, 10, 12, 0, 0, 0, time.UTC)
+	february := time.Date(2016, 2, 15, 12, 0, 0, 0, time.UTC)
+	expenses := []struct {
+		e       *models.Expense
+		split   models.Split
+		created time.Time
+	}{
+		{&models.Expense{Amount: 1000, Currency: models.GBP, ExchangeRate: 1, PayerID: u1.ID, CategoryID: c1.ID},
+			models.EqualSplit([]int64{u1.ID, u2.ID}), march},
+		{&models.Expense{Amount: 3000, Currency: models.GBP, ExchangeRate: 1, PayerID: u2.ID, CategoryID: c2.ID},
+			models.EqualSplit([]int64{u1.ID, u2.ID}), february},
+		{&models.Expense{Amount: 2000, Currency: "EUR", ExchangeRate: 0.5, PayerID: u1.ID, CategoryID: c1.ID},
+			models.EqualSplit([]int64{u1.ID}), march},
+	}
+
+	var es []*models.Expense
+	var splits []models.Split
+	for _, test := range expenses {
+		test.e.GroupID = g.ID
+		test.e.CreatedAt = test.created
+		es = append(es, test.e)
+		splits = append(splits, test.split)
+	}
+
+	err = st.InsertHistory(es, splits, nil)
+	if err != nil {
+		t.Fatalf("Error inserting expenses: %v", err)
+		return
+	}
+
+	checkTotals := func(name string, ts []*models.SpendingTotal, expected []models.SpendingTotal) {
+		if len(ts) != len(expected) {
+			t.Fatalf("Expected %d totals %s, got %d", len(expected), name, len(ts))
+			return
+		}
+
+		for i, total := range ts {
+			if *total != expected[i] {
+				t.Fatalf("Expected total %d %s to be %+v, got %+v", i, name, expected[i], *total)
+				return
+			}
+		}
+	}
+
+	ts, err := st.SpendingByPayer(g, time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Error totalling spending by payer: %v", err)
+		return
+	}
+
+	checkTotals("by payer", ts, []models.SpendingTotal{
+		{Month: "2016-02", CategoryID: c2.ID, UserID: u2.ID, Currency: models.GBP, Amount: 3000, Count: 1},
+		{Month: "2016-03", CategoryID: c1.ID, UserID: u1.ID, Currency: "EUR", Amount: 1000, Count: 1},
+		{Month: "2016-03", CategoryID: c1.ID, UserID: u1.ID, Currency: models.GBP, Amount: 1000, Count: 1},
+	})
+
+	ts, err = st.SpendingByAssignee(g, time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Error totalling spending by assignee: %v", err)
+		return
+	}
+
+	checkTotals("by assignee", ts, []models.SpendingTotal{
+		{Month: "2016-03", CategoryID: c1.ID, UserID: u1.ID, Currency: "EUR", Amount: 1000, Count: 1},
+		{Month: "2016-03", CategoryID: c1.ID, UserID: u1.ID, Currency: models.GBP, Amount: 500, Count: 1},
+		{Month: "2016-03", CategoryID: c1.ID, UserID: u2.ID, Currency: models.GBP, Amount: 500, Count: 1},
+	})
+
+	ts, err = st.SpendingByCategory(c2, time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Error totalling spending by category: %v", err)
+		return
+	}
+
+	checkTotals("by category", ts, []models.SpendingTotal{
+		{Month: "2016-02", CategoryID: c2.ID, UserID: u2.ID, Currency: models.GBP, Amount: 3000, Count: 1},
+	})
+}
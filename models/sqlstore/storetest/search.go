@@ -0,0 +1,87 @@
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"testing"
+)
+
+func testSearchExpenses(st Store, t *testing.T) {
+	g := &models.Group{
+		Name: "Search group",
+	}
+
+	err := st.InsertGroup(g)
+	if err != nil {
+		t.Fatalf("Error inserting group: %v", err)
+		return
+	}
+
+	u := &auth.User{
+		Email:  "search@example.com",
+		PwHash: "hash",
+		Name:   "TEST",
+	}
+
+	err = st.Insert(u)
+	if err != nil {
+		t.Fatalf("Error inserting user: %v", err)
+		return
+	}
+
+	c := &models.Category{GroupID: g.ID, Name: "Groceries", Colour: models.DefaultColour}
+	err = st.InsertCategory(c)
+	if err != nil {
+		t.Fatalf("Error inserting category: %v", err)
+		return
+	}
+
+	for _, e := range []*models.Expense{
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 450, Description: "Boursin cheese"},
+		{GroupID: g.ID, PayerID: u.ID, CategoryID: c.ID, Amount: 300, Description: "Milk"},
+	} {
+		err = st.InsertExpense(e, models.EqualSplit([]int64{u.ID}))
+		if err != nil {
+			t.Fatalf("Error inserting expense: %v", err)
+			return
+		}
+	}
+
+	es, err := st.SearchExpenses(g, models.ExpenseSearch{})
+	if err != nil || len(es) != 2 || es[0].Description != "Milk" {
+		t.Fatalf("Expected every expense, most recent first, got %+v (err=%v)", es, err)
+		return
+	}
+
+	// How closely words must match depends on the store, but every store
+	// finds a whole word whatever its case
+	es, err = st.SearchExpenses(g, models.ExpenseSearch{Text: "BOURSIN"})
+	if err != nil || len(es) != 1 || es[0].Description != "Boursin cheese" {
+		t.Fatalf("Expected to find Boursin cheese, got %+v (err=%v)", es, err)
+		return
+	}
+
+	if len(es[0].Assignments) != 1 || es[0].Assignments[0].Amount != 450 {
+		t.Fatalf("Expected expense found to have its assignment, got %+v", es[0].Assignments)
+		return
+	}
+
+	es, err = st.SearchExpenses(g, models.ExpenseSearch{Text: "BOURSIN", MaxAmount: 400})
+	if err != nil || len(es) != 0 {
+		t.Fatalf("Expected nothing found below 400, got %+v (err=%v)", es, err)
+		return
+	}
+
+	es, err = st.SearchExpenses(g, models.ExpenseSearch{MinAmount: 300, MaxAmount: 300, CategoryID: c.ID, PayerID: u.ID})
+	if err != nil || len(es) != 1 || es[0].Description != "Milk" {
+		t.Fatalf("Expected to find Milk, got %+v (err=%v)", es, err)
+		return
+	}
+
+	es, err = st.SearchExpenses(g, models.ExpenseSearch{PayerID: u.ID + 1000})
+	if err != nil || len(es) != 0 {
+		t.Fatalf("Expected nothing paid by another user, got %+v (err=%v)", es, err)
+		return
+	}
+}
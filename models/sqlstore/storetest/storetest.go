@@ -0,0 +1,113 @@
+// Package storetest holds the tests shared by the stores built on sqlstore,
+// so that each database runs the same tests against its own schema.
+package storetest
+
+import (
+	"git.ianfross.com/ifross/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"testing"
+)
+
+// Store is a store under test. Its schema is migrated before each test and
+// dropped afterwards.
+type Store interface {
+	models.Storer
+	auth.Storer
+	MustMigrate()
+	MustPrepareStmts()
+	MustDropTables()
+}
+
+var tests = []struct {
+	name string
+	test func(Store, *testing.T)
+}{
+	{"UserCrud", testUserCrud},
+	{"GroupCrud", testGroupCrud},
+	{"CategoryCrud", testCategoryCrud},
+	{"PaymentCrud", testPaymentCrud},
+	{"ExpenseCrud", testExpenseCrud},
+	{"InsertHistory", testInsertHistory},
+	{"RecurringExpenseCrud", testRecurringExpenseCrud},
+	{"AttachmentCrud", testAttachmentCrud},
+	{"Spending", testSpending},
+	{"SearchExpenses", testSearchExpenses},
+	{"ExpensesByQuery", testExpensesByQuery},
+}
+
+var benchmarks = []struct {
+	name  string
+	bench func(Store, *testing.B)
+}{
+	{"ExpenseCreation", benchmarkExpenseCreation},
+	{"ExpenseRetrieval", benchmarkExpenseRetrieval},
+}
+
+// Test runs every shared test against the store. drop is called after each
+// test to remove anything the store creates besides tables, and may be nil.
+func Test(t *testing.T, st Store, drop func()) {
+	for _, test := range tests {
+		t.Run(test.name, WrapDbTest(st, drop, test.test))
+	}
+}
+
+// Benchmark runs every shared benchmark against the store, as Test does.
+func Benchmark(b *testing.B, st Store, drop func()) {
+	for _, bench := range benchmarks {
+		b.Run(bench.name, wrapDbBenchmark(st, drop, bench.bench))
+	}
+}
+
+// WrapDbTest wraps a test to ensure the database is created before the test
+// and destroyed after the end of the test. This means there is no data left
+// in the database between test runs.
+func WrapDbTest(st Store, drop func(), test func(Store, *testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != nil {
+				st.MustDropTables()
+				if drop != nil {
+					drop()
+				}
+
+				t.Fatalf("Test panicked: %v", r)
+			}
+		}()
+
+		// Create database schema
+		if drop != nil {
+			defer drop()
+		}
+		st.MustMigrate()
+		st.MustPrepareStmts()
+		defer st.MustDropTables()
+
+		// perform the test
+		test(st, t)
+	}
+}
+
+func wrapDbBenchmark(st Store, drop func(), bench func(Store, *testing.B)) func(*testing.B) {
+	return func(b *testing.B) {
+		defer func() {
+			if r := recover(); r != nil {
+				st.MustDropTables()
+				if drop != nil {
+					drop()
+				}
+
+				b.Fatalf("Benchmark panicked: %v", r)
+			}
+		}()
+
+		if drop != nil {
+			defer drop()
+		}
+		st.MustMigrate()
+		st.MustPrepareStmts()
+		defer st.MustDropTables()
+
+		bench(st, b)
+	}
+}
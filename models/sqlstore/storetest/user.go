@@ -1,7 +1,7 @@
-package postgrestore
+package storetest
 
 import (
-	"git.ianfross.com/expensetracker/auth"
+	"git.ianfross.com/ifross/expensetracker/auth"
 
 	_ "github.com/juju/errors"
 
@@ -9,56 +9,7 @@ import (
 	"time"
 )
 
-// wrapDbTest wraps a test to ensure the database is created before the test
-// and destroyed after the end of the test. This means there is no data left
-// in the database between test runs.runs
-func wrapDbTest(st *postgresStore, test func(*postgresStore, *testing.T)) func(*testing.T) {
-	return func(t *testing.T) {
-		defer func() {
-			if r := recover(); r != nil {
-				st.MustDropTables()
-				st.MustDropTypes()
-
-				t.Failed()
-			}
-		}()
-
-		st.debug = false
-		defer func() { st.debug = false }()
-		// Create database schema
-		st.MustCreateTypes()
-		defer st.MustDropTypes()
-		st.MustCreateTables()
-		st.MustPrepareStmts()
-		defer st.MustDropTables()
-
-		// perform the test
-		test(st, t)
-	}
-}
-
-func wrapDbBenchmark(st *postgresStore, bench func(*postgresStore, *testing.B)) func(*testing.B) {
-	return func(b *testing.B) {
-		defer func() {
-			if r := recover(); r != nil {
-				st.MustDropTables()
-				st.MustDropTypes()
-
-				b.Failed()
-			}
-		}()
-
-		st.MustCreateTypes()
-		defer st.MustDropTypes()
-		st.MustCreateTables()
-		st.MustPrepareStmts()
-		defer st.MustDropTables()
-
-		bench(st, b)
-	}
-}
-
-func testUserCrud(st *postgresStore, t *testing.T) {
+func testUserCrud(st Store, t *testing.T) {
 	t.Log("Creating user")
 	u := &auth.User{
 		Email:  "hello@example.com",
@@ -192,7 +143,3 @@ func isMatchingUser(t *testing.T, u1, u2 *auth.User) bool {
 
 	return true
 }
-
-func TestUserCrud(t *testing.T) {
-	wrapDbTest(s, testUserCrud)(t)
-}
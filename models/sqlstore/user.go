@@ -1,11 +1,9 @@
-package postgrestore
+package sqlstore
 
 import (
 	"git.ianfross.com/ifross/expensetracker/auth"
 
 	"github.com/juju/errors"
-
-	"fmt"
 )
 
 const (
@@ -30,7 +28,7 @@ UPDATE users SET
 	usersStr      = `SELECT * FROM users;`
 )
 
-func (s *postgresStore) Users() ([]*auth.User, error) {
+func (s *Store) Users() ([]*auth.User, error) {
 	var us []*auth.User = make([]*auth.User, 0, 0)
 	err := s.db.Select(&us, usersStr)
 	if err != nil {
@@ -41,8 +39,7 @@ func (s *postgresStore) Users() ([]*auth.User, error) {
 }
 
 // Insert saves a new user to the database
-func (s *postgresStore) Insert(u *auth.User) error {
-	fmt.Println("USER:", u)
+func (s *Store) Insert(u *auth.User) error {
 	if u.ID != 0 {
 		return auth.ErrAlreadySaved
 	}
@@ -54,7 +51,7 @@ func (s *postgresStore) Insert(u *auth.User) error {
 }
 
 // Update updated a user in the database
-func (s *postgresStore) Update(u *auth.User) error {
+func (s *Store) Update(u *auth.User) error {
 	_, err := s.updateUserStmt.Exec(u)
 	if err != nil {
 		return errors.Annotate(err, "Error updating user")
@@ -64,7 +61,7 @@ func (s *postgresStore) Update(u *auth.User) error {
 }
 
 // UserByToken retrieves a user by their unique token
-func (s *postgresStore) UserByToken(tok string) (*auth.User, error) {
+func (s *Store) UserByToken(tok string) (*auth.User, error) {
 	var u = auth.User{Token: tok}
 	err := s.userByTokenStmt.Get(&u, u)
 	if err != nil {
@@ -75,7 +72,7 @@ func (s *postgresStore) UserByToken(tok string) (*auth.User, error) {
 }
 
 // UserByID retrieves a user by their ID
-func (s *postgresStore) UserByID(id int64) (*auth.User, error) {
+func (s *Store) UserByID(id int64) (*auth.User, error) {
 	var u = auth.User{ID: id}
 	err := s.userByIDStmt.Get(&u, u)
 	if err != nil {
@@ -87,13 +84,12 @@ func (s *postgresStore) UserByID(id int64) (*auth.User, error) {
 
 // Delete removes a user from the database. If the user does not exist, then
 // an error is returned
-func (s *postgresStore) Delete(u *auth.User) error {
+func (s *Store) Delete(u *auth.User) error {
 	result, err := s.deleteUserStmt.Exec(u)
 	if err != nil {
 		return errors.Annotatef(err, "Could not delete user with id %d", u.ID)
 	}
 	n, _ := result.RowsAffected()
-	fmt.Println("Rows affected", n)
 	if n != 1 {
 		return errors.New("No user deleted")
 	}
@@ -102,7 +98,7 @@ func (s *postgresStore) Delete(u *auth.User) error {
 }
 
 // UserByEmail obtains a user by their email address
-func (s *postgresStore) UserByEmail(e string) (*auth.User, error) {
+func (s *Store) UserByEmail(e string) (*auth.User, error) {
 	var u = auth.User{Email: e}
 	err := s.userByEmailStmt.Get(&u, u)
 	if err != nil {
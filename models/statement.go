@@ -0,0 +1,408 @@
+package models
+
+import (
+	"github.com/juju/errors"
+
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidMapping is returned when a statement mapping does not give
+	// three different columns for the date, description and amount
+	ErrInvalidMapping = errors.New("The date, description and amount must be different columns")
+
+	// ErrUnknownStatementFormat is returned when importing a statement in a
+	// format that cannot be read
+	ErrUnknownStatementFormat = errors.New("Statements must be CSV, OFX or QIF")
+
+	// ErrInvalidStatement is returned when a statement is not in the format
+	// it was said to be
+	ErrInvalidStatement = errors.New("The statement could not be read")
+
+	// ErrStatementCurrency is returned when a statement is in a different
+	// currency to the group it is imported into
+	ErrStatementCurrency = errors.New("The statement is not in the currency of the group")
+
+	// ErrAmbiguousAmount is returned for a statement amount whose separators
+	// do not match the decimal separator of the mapping, e.g. "12,40" when
+	// the decimal separator is a point
+	ErrAmbiguousAmount = errors.New("The amount does not use the decimal separator of the mapping")
+)
+
+// StatementFormat is the file format of a bank statement.
+type StatementFormat int
+
+const (
+	// StatementCSV statements are read using a StatementMapping.
+	StatementCSV StatementFormat = iota
+	// StatementOFX statements are Open Financial Exchange files, either
+	// the SGML of version 1 or the XML of version 2.
+	StatementOFX
+	// StatementQIF statements are Quicken Interchange Format files.
+	StatementQIF
+)
+
+var statementFormatStrings = map[StatementFormat]string{
+	StatementCSV: "csv",
+	StatementOFX: "ofx",
+	StatementQIF: "qif",
+}
+
+func (f StatementFormat) String() string {
+	s, ok := statementFormatStrings[f]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+func (f StatementFormat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.String())
+}
+
+// ParseStatementFormat converts "csv", "ofx" or "qif" into a StatementFormat.
+// It is not case sensitive.
+func ParseStatementFormat(s string) (StatementFormat, error) {
+	for f, str := range statementFormatStrings {
+		if strings.EqualFold(str, s) {
+			return f, nil
+		}
+	}
+	return 0, errors.Annotatef(ErrUnknownStatementFormat, "%q", s)
+}
+
+// DefaultStatementDateFormat is the format of the dates in a bank statement
+// when the mapping does not give one.
+const DefaultStatementDateFormat = "02/01/2006"
+
+// StatementMapping describes the layout of a CSV bank statement. The columns
+// are numbered from 0 and the date format is a Go time layout. If Header is
+// set then the first row is skipped. If Negate is set then spending is
+// negative on the statement, otherwise it is positive. Amounts are in the
+// major units of the group's currency e.g. "1,234.40", or "1.234,40" if
+// DecimalComma is set. Only the date format is used for QIF statements, and
+// none of it for OFX.
+type StatementMapping struct {
+	Date         int    `json:"date"`
+	Description  int    `json:"description"`
+	Amount       int    `json:"amount"`
+	DateFormat   string `json:"dateFormat"`
+	Header       bool   `json:"header"`
+	Negate       bool   `json:"negate"`
+	DecimalComma bool   `json:"decimalComma"`
+}
+
+func (m StatementMapping) validate() error {
+	if m.Date < 0 || m.Description < 0 || m.Amount < 0 {
+		return ErrInvalidMapping
+	}
+
+	if m.Date == m.Description || m.Date == m.Amount || m.Description == m.Amount {
+		return ErrInvalidMapping
+	}
+
+	return nil
+}
+
+// StatementRow is a transaction read from a bank statement. Line is the line
+// of the file that the transaction starts on, counting from 1. The import ID
+// is the ID the bank gave the transaction, which only OFX statements have.
+// Rows that could not be read, or that are not spending, have an Error.
+// Duplicate rows have already been imported into the group. Neither are
+// imported.
+type StatementRow struct {
+	Line        int       `json:"line"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Amount      Pence     `json:"amount"`
+	ImportID    string    `json:"importId,omitempty"`
+	Duplicate   bool      `json:"duplicate"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// importable reports whether an expense should be created for the row.
+func (r StatementRow) importable() bool {
+	return r.Error == "" && !r.Duplicate
+}
+
+// parseRecord fills in the row from a record of the statement, setting the
+// error if the record cannot be read.
+func (r *StatementRow) parseRecord(record []string, m StatementMapping, currency string) {
+	for _, col := range []int{m.Date, m.Description, m.Amount} {
+		if col >= len(record) {
+			r.Error = fmt.Sprintf("Row has no column %d", col)
+			return
+		}
+	}
+
+	date, err := time.Parse(m.DateFormat, strings.TrimSpace(record[m.Date]))
+	if err != nil {
+		r.Error = fmt.Sprintf("Date must be of the form %s", m.DateFormat)
+		return
+	}
+	r.Date = date.UTC()
+	r.Description = strings.TrimSpace(record[m.Description])
+	r.setAmount(record[m.Amount], currency, m.Negate, m.DecimalComma)
+}
+
+// setAmount parses the amount of the transaction in the currency given,
+// setting the error if it cannot be read or is not spending. If negate is set
+// then spending is negative in the string, and if decimalComma is set then
+// its decimal separator is a comma.
+func (r *StatementRow) setAmount(s, currency string, negate, decimalComma bool) {
+	s, err := decimalAmount(strings.TrimSpace(s), decimalComma)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+
+	amount, err := MoneyFromString(s, currency)
+	if err != nil {
+		r.Error = ErrInvalidMoneyStr.Error()
+		return
+	}
+
+	r.Amount = Pence(amount.Amount)
+	if negate {
+		r.Amount = -r.Amount
+	}
+
+	if r.Amount <= 0 {
+		r.Error = "Not spending"
+	}
+}
+
+// decimalAmount converts an amount from a statement into the form understood
+// by MoneyFromString, removing the thousands separators. If decimalComma is
+// set then the amount is written like "1.234,50", otherwise like "1,234.50".
+// Thousands separators must be followed by groups of three digits, so an
+// amount written with the other decimal separator is rejected rather than
+// being read as a much larger amount.
+func decimalAmount(s string, decimalComma bool) (string, error) {
+	thousands, point := ",", "."
+	if decimalComma {
+		thousands, point = ".", ","
+	}
+
+	whole, fraction := s, ""
+	i := strings.Index(s, point)
+	if i >= 0 {
+		whole, fraction = s[:i], s[i+len(point):]
+	}
+
+	if strings.Contains(fraction, thousands) {
+		return "", ErrAmbiguousAmount
+	}
+
+	groups := strings.Split(whole, thousands)
+	if len(groups) > 1 && strings.TrimLeft(groups[0], "+-") == "" {
+		return "", ErrAmbiguousAmount
+	}
+
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return "", ErrAmbiguousAmount
+		}
+	}
+
+	s = strings.Join(groups, "")
+	if i >= 0 {
+		s += "." + fraction
+	}
+	return s, nil
+}
+
+// ReadStatement reads every transaction of a bank statement in the format
+// given, as described by ParseStatement, ParseOFX and ParseQIF.
+func ReadStatement(r io.Reader, f StatementFormat, m StatementMapping, currency string) ([]*StatementRow, error) {
+	switch f {
+	case StatementCSV:
+		return ParseStatement(r, m, currency)
+	case StatementOFX:
+		return ParseOFX(r, currency)
+	case StatementQIF:
+		return ParseQIF(r, m.DateFormat, currency)
+	}
+	return nil, errors.Annotatef(ErrUnknownStatementFormat, "%d", f)
+}
+
+// ParseStatement reads every row of a CSV bank statement using the mapping
+// given. Amounts are parsed in the currency given. Rows that cannot be read
+// are returned with an Error, so that they can be shown to the user, while an
+// error is only returned if the file itself is not valid CSV.
+func ParseStatement(r io.Reader, m StatementMapping, currency string) ([]*StatementRow, error) {
+	err := m.validate()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if m.DateFormat == "" {
+		m.DateFormat = DefaultStatementDateFormat
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not read statement")
+	}
+
+	var rows []*StatementRow
+	for i, record := range records {
+		if i == 0 && m.Header {
+			continue
+		}
+
+		row := &StatementRow{Line: i + 1}
+		row.parseRecord(record, m, currency)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// statementKey identifies the transactions that are considered the same when
+// importing a statement.
+type statementKey struct {
+	date        string
+	amount      Pence
+	description string
+}
+
+func newStatementKey(date time.Time, amount Pence, description string) statementKey {
+	return statementKey{date.UTC().Format("2006-01-02"), amount, strings.TrimSpace(description)}
+}
+
+// markDuplicates marks the rows that have already been imported as one of the
+// expenses given, which must be in the currency given. Rows with an import ID
+// are duplicates of the expense with the same import ID, and of any row
+// before them with the same import ID. Otherwise rows are duplicates of an
+// expense with the same date, amount and description, preferring expenses
+// that were not imported with an ID. Each expense only matches one row, so a
+// statement with two identical transactions can still be imported in full
+// once.
+func markDuplicates(rows []*StatementRow, es []*Expense, currency string) {
+	ids := make(map[string]bool)
+	plain := make(map[statementKey]int)
+	imported := make(map[statementKey]int)
+	for _, e := range es {
+		if e.ImportID != "" {
+			ids[e.ImportID] = true
+		}
+
+		if e.Money().Currency != currency {
+			continue
+		}
+
+		k := newStatementKey(e.CreatedAt, e.Amount, e.Description)
+		if e.ImportID == "" {
+			plain[k]++
+		} else {
+			imported[k]++
+		}
+	}
+
+	for _, row := range rows {
+		if row.Error != "" {
+			continue
+		}
+
+		k := newStatementKey(row.Date, row.Amount, row.Description)
+		switch {
+		case row.ImportID != "" && ids[row.ImportID]:
+			row.Duplicate = true
+		case plain[k] > 0:
+			plain[k]--
+			row.Duplicate = true
+		case row.ImportID == "" && imported[k] > 0:
+			imported[k]--
+			row.Duplicate = true
+		}
+
+		if row.ImportID != "" {
+			ids[row.ImportID] = true
+		}
+	}
+}
+
+// PreviewStatement reads a bank statement and marks the rows that have
+// already been imported into the group. Nothing is saved.
+func (m Manager) PreviewStatement(g *Group, r io.Reader, f StatementFormat, mapping StatementMapping) ([]*StatementRow, error) {
+	rows, err := ReadStatement(r, f, mapping, g.BaseCurrency())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	es, err := m.store.ExpensesByGroup(g)
+	if err != nil {
+		return nil, errors.Annotate(err, "Could not retrieve group expenses")
+	}
+
+	markDuplicates(rows, es, g.BaseCurrency())
+	return rows, nil
+}
+
+// ImportStatement creates an expense in the group for every row of a bank
+// statement that can be read and has not already been imported. Each expense
+// is dated the day of its transaction, paid by the payer given and divided
+// using the split. Duplicates are checked for and the expenses saved in a
+// single transaction, so either all are imported or none are, and importing
+// the same statement twice at once cannot create duplicates. The rows are
+// returned, as from PreviewStatement, along with the expenses created. Budget
+// alerts are not sent for imported expenses, as they are usually from past
+// months.
+func (m Manager) ImportStatement(g *Group, r io.Reader, f StatementFormat, mapping StatementMapping, payer, category int64, split Split) ([]*StatementRow, []*Expense, error) {
+	err := m.checkMembers(g, append(split.UserIDs(), payer)...)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	_, err = m.checkCategory(g, category)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	rows, err := ReadStatement(r, f, mapping, g.BaseCurrency())
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	es, err := m.store.ImportExpenses(g, func(existing []*Expense) ([]*Expense, []Split, error) {
+		markDuplicates(rows, existing, g.BaseCurrency())
+
+		es := []*Expense{}
+		var splits []Split
+		for _, row := range rows {
+			if !row.importable() {
+				continue
+			}
+
+			es = append(es, &Expense{
+				Amount:       row.Amount,
+				Currency:     g.BaseCurrency(),
+				ExchangeRate: 1,
+				PayerID:      payer,
+				CategoryID:   category,
+				Description:  row.Description,
+				GroupID:      g.ID,
+				CreatedAt:    row.Date,
+				ImportID:     row.ImportID,
+			})
+			splits = append(splits, split)
+		}
+
+		return es, splits, nil
+	})
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "Unable to import expenses")
+	}
+
+	return rows, es, nil
+}
@@ -0,0 +1,296 @@
+package models_test
+
+import (
+	"git.ianfross.com/ifross/expensetracker/models"
+
+	"github.com/juju/errors"
+
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStatement = `Date,Type,Description,Amount
+04/03/2016,DEB,TESCO STORES,-12.40
+04/03/2016,DEB,TESCO STORES,-12.40
+05/03/2016,CR,SALARY,"1,500.00"
+06/03/2016,DEB,CORNER SHOP,-0.99
+07-03-2016,DEB,BAD DATE,-1.00
+08/03/2016,DEB,BAD AMOUNT,-£1.00
+`
+
+var testMapping = models.StatementMapping{Date: 0, Description: 2, Amount: 3, Header: true, Negate: true}
+
+func TestParseStatement(t *testing.T) {
+	rows, err := models.ParseStatement(strings.NewReader(testStatement), testMapping, models.GBP)
+	if err != nil {
+		t.Fatalf("Error parsing statement: %v", err)
+	}
+
+	if len(rows) != 6 {
+		t.Fatalf("Expected 6 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+	if first.Line != 2 || !first.Date.Equal(date) || first.Description != "TESCO STORES" || first.Amount != 1240 || first.Error != "" {
+		t.Fatalf("Unexpected first row %+v", first)
+	}
+
+	for _, line := range []int{4, 6, 7} {
+		if row := rows[line-2]; row.Error == "" {
+			t.Fatalf("Expected error for line %d, got %+v", line, row)
+		}
+	}
+
+	if rows[3].Error != "" || rows[3].Amount != 99 {
+		t.Fatalf("Expected 99p for corner shop, got %+v", rows[3])
+	}
+
+	_, err = models.ParseStatement(strings.NewReader(testStatement), models.StatementMapping{Date: 0, Description: 0, Amount: 3}, models.GBP)
+	if errors.Cause(err) != models.ErrInvalidMapping {
+		t.Fatalf("Expected ErrInvalidMapping, got %v", err)
+	}
+}
+
+func TestParseStatementDecimalSeparator(t *testing.T) {
+	const statement = `04/03/2016,COMMA,"12,40"
+04/03/2016,THOUSANDS,"1,240"
+04/03/2016,BOTH,"1.234,50"
+04/03/2016,POINT,12.40
+`
+	tests := []struct {
+		decimalComma bool
+		amounts      []models.Pence
+	}{
+		{false, []models.Pence{0, 124000, 0, 1240}},
+		{true, []models.Pence{1240, 124, 123450, 0}},
+	}
+
+	for _, test := range tests {
+		m := models.StatementMapping{Date: 0, Description: 1, Amount: 2, DecimalComma: test.decimalComma}
+		rows, err := models.ParseStatement(strings.NewReader(statement), m, models.GBP)
+		if err != nil {
+			t.Fatalf("Error parsing statement: %v", err)
+		}
+
+		for i, row := range rows {
+			expected := test.amounts[i]
+			if expected == 0 && row.Error != models.ErrAmbiguousAmount.Error() {
+				t.Fatalf("Expected %s to be ambiguous with decimal comma %t, got %+v", row.Description, test.decimalComma, row)
+			}
+
+			if expected != 0 && (row.Error != "" || row.Amount != expected) {
+				t.Fatalf("Expected %s to be %d with decimal comma %t, got %+v", row.Description, expected, test.decimalComma, row)
+			}
+		}
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	groceries := mustCategory(t, m, g, "Groceries")
+
+	rows, err := m.PreviewStatement(g, strings.NewReader(testStatement), models.StatementCSV, testMapping)
+	if err != nil {
+		t.Fatalf("Error previewing statement: %v", err)
+	}
+
+	for _, row := range rows {
+		if row.Duplicate {
+			t.Fatalf("Expected no duplicates before importing, got %+v", row)
+		}
+	}
+
+	_, es, err := m.ImportStatement(g, strings.NewReader(testStatement), models.StatementCSV, testMapping, us[0].ID, groceries, split)
+	if err != nil {
+		t.Fatalf("Error importing statement: %v", err)
+	}
+
+	if len(es) != 3 {
+		t.Fatalf("Expected 3 expenses imported, got %d", len(es))
+	}
+
+	if date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC); !es[0].CreatedAt.Equal(date) {
+		t.Fatalf("Expected expense dated %s, got %s", date, es[0].CreatedAt)
+	}
+
+	// A later statement overlapping the first only adds the new transaction
+	later := testStatement + "09/03/2016,DEB,CORNER SHOP,-2.50\n"
+	rows, es, err = m.ImportStatement(g, strings.NewReader(later), models.StatementCSV, testMapping, us[0].ID, groceries, split)
+	if err != nil {
+		t.Fatalf("Error importing statement again: %v", err)
+	}
+
+	if len(es) != 1 || es[0].Amount != 250 {
+		t.Fatalf("Expected only the new expense to be imported, got %d", len(es))
+	}
+
+	duplicates := 0
+	for _, row := range rows {
+		if row.Duplicate {
+			duplicates++
+		}
+	}
+
+	if duplicates != 3 {
+		t.Fatalf("Expected 3 duplicate rows, got %d", duplicates)
+	}
+
+	_, _, err = m.ImportStatement(g, strings.NewReader(later), models.StatementCSV, testMapping, us[0].ID+1000, groceries, split)
+	if errors.Cause(err) != models.ErrNotMember {
+		t.Fatalf("Expected ErrNotMember importing with an unknown payer, got %v", err)
+	}
+}
+
+const testOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<CURDEF>GBP
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20160304120000.000[0:GMT]
+<TRNAMT>-12.40
+<FITID>A1
+<NAME>TESCO STORES
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20160304
+<TRNAMT>-12.40
+<FITID>A2
+<NAME>TESCO STORES
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20160305
+<TRNAMT>1500.00
+<FITID>A3
+<NAME>SALARY
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20160306
+<TRNAMT>-3,50
+<FITID>A4
+<MEMO>Fish &amp; chips
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	rows, err := models.ParseOFX(strings.NewReader(testOFX), models.GBP)
+	if err != nil {
+		t.Fatalf("Error parsing OFX: %v", err)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+	if first.Line != 11 || !first.Date.Equal(date) || first.Description != "TESCO STORES" || first.Amount != 1240 || first.ImportID != "A1" || first.Error != "" {
+		t.Fatalf("Unexpected first row %+v", first)
+	}
+
+	if rows[2].Error == "" {
+		t.Fatalf("Expected error for credit, got %+v", rows[2])
+	}
+
+	if last := rows[3]; last.Description != "Fish & chips" || last.Amount != 350 || last.Error != "" {
+		t.Fatalf("Unexpected last row %+v", last)
+	}
+
+	_, err = models.ParseOFX(strings.NewReader(testOFX), "EUR")
+	if errors.Cause(err) != models.ErrStatementCurrency {
+		t.Fatalf("Expected ErrStatementCurrency, got %v", err)
+	}
+}
+
+const testQIF = `!Type:Bank
+D04/03/2016
+T-12.40
+PTESCO STORES
+^
+D5/3'16
+T1,500.00
+PSALARY
+^
+D06/03/2016
+T-3.50
+MFish and chips
+^
+`
+
+func TestParseQIF(t *testing.T) {
+	rows, err := models.ParseQIF(strings.NewReader(testQIF), "", models.GBP)
+	if err != nil {
+		t.Fatalf("Error parsing QIF: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	date := time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC)
+	if first.Line != 2 || !first.Date.Equal(date) || first.Description != "TESCO STORES" || first.Amount != 1240 || first.Error != "" {
+		t.Fatalf("Unexpected first row %+v", first)
+	}
+
+	if second := rows[1]; second.Error == "" || !second.Date.Equal(date.AddDate(0, 0, 1)) {
+		t.Fatalf("Expected credit dated 5 March 2016 with an error, got %+v", second)
+	}
+
+	if last := rows[2]; last.Description != "Fish and chips" || last.Amount != 350 || last.Error != "" {
+		t.Fatalf("Unexpected last row %+v", last)
+	}
+
+	_, err = models.ParseQIF(strings.NewReader("D04/03/2016\nT-1.00\n"), "", models.GBP)
+	if err == nil {
+		t.Fatalf("Expected error parsing unterminated transaction")
+	}
+}
+
+func TestImportOFX(t *testing.T) {
+	m, g, us := newTestGroup(t, 2)
+	split := models.EqualSplit([]int64{us[0].ID, us[1].ID})
+	groceries := mustCategory(t, m, g, "Groceries")
+
+	// Both Tesco transactions are imported, as they have different FITIDs
+	_, es, err := m.ImportStatement(g, strings.NewReader(testOFX), models.StatementOFX, models.StatementMapping{}, us[0].ID, groceries, split)
+	if err != nil {
+		t.Fatalf("Error importing OFX: %v", err)
+	}
+
+	if len(es) != 3 || es[0].ImportID != "A1" || es[1].ImportID != "A2" {
+		t.Fatalf("Expected 3 expenses with their FITIDs, got %d", len(es))
+	}
+
+	rows, es, err := m.ImportStatement(g, strings.NewReader(testOFX), models.StatementOFX, models.StatementMapping{}, us[0].ID, groceries, split)
+	if err != nil {
+		t.Fatalf("Error importing OFX again: %v", err)
+	}
+
+	if len(es) != 0 {
+		t.Fatalf("Expected nothing imported twice, got %d expenses", len(es))
+	}
+
+	for _, row := range rows {
+		if row.Error == "" && !row.Duplicate {
+			t.Fatalf("Expected row to be a duplicate, got %+v", row)
+		}
+	}
+}